@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Actor is who an entry is attributed to. It travels in the context so code
+// below the handlers can record changes without seeing the request.
+type Actor struct {
+	RequestID        string
+	UserID           *int
+	ServiceAccountID *int
+	ImpersonatorID   *int
+	Role             string
+	IP               string
+}
+
+type actorKey struct{}
+
+// ActorFrom reads the principal AuthMiddleware stored on the request.
+func ActorFrom(c *fiber.Ctx) Actor {
+	actor := Actor{
+		RequestID: truncate(requestID(c), 64),
+		Role:      localString(c, "role"),
+		IP:        c.IP(),
+	}
+	if userID, _ := c.Locals("userID").(int); userID != 0 {
+		actor.UserID = &userID
+	}
+	if accountID, ok := c.Locals("serviceAccountID").(int); ok {
+		actor.ServiceAccountID = &accountID
+	}
+	if impersonatorID, ok := c.Locals("impersonatorID").(int); ok {
+		actor.ImpersonatorID = &impersonatorID
+	}
+	return actor
+}
+
+// WithActor returns a context whose changes are attributed to actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor. Changes made without
+// one, such as seeding, are recorded with an empty actor.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+func requestID(c *fiber.Ctx) string {
+	if id := localString(c, "requestid"); id != "" {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
+
+func localString(c *fiber.Ctx, key string) string {
+	s, _ := c.Locals(key).(string)
+	return s
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+// Package audit records who changed what. Entries are written in the same
+// transaction as the change they describe and form a hash chain, so a
+// committed change always has its entry and tampering with history is
+// detectable by Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mbkm-api/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// chainLockKey serialises writers so every entry links to the one before it.
+const chainLockKey = 7414151
+
+// genesisHash is the PrevHash of the first entry.
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Snapshot returns the row as JSON and locks it until the transaction ends.
+// table must be a trusted table name, never user input.
+func Snapshot(ctx context.Context, tx pgx.Tx, table string, id int) (json.RawMessage, error) {
+	var data json.RawMessage
+	query := `SELECT row_to_json(t) FROM "` + table + `" t WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, id).Scan(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Record appends an entry for a change to table row id made within tx,
+// attributed to the actor in ctx. before is the Snapshot taken ahead of the
+// change (nil for creates); the state after the change is read here, except
+// for deletes.
+func Record(ctx context.Context, tx pgx.Tx, action, table string, id int, before json.RawMessage) error {
+	var after json.RawMessage
+	if action != models.AuditActionDelete {
+		var err error
+		if after, err = Snapshot(ctx, tx, table, id); err != nil {
+			return err
+		}
+	}
+
+	return write(ctx, tx, action, table, id, before, after)
+}
+
+// RecordEvent appends an entry for an action that changes no row, such as
+// starting an impersonation. data describes the event and is stored as
+// new_data.
+func RecordEvent(ctx context.Context, tx pgx.Tx, action, table string, id int, data any) error {
+	after, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return write(ctx, tx, action, table, id, nil, after)
+}
+
+func write(ctx context.Context, tx pgx.Tx, action, table string, id int, before, after json.RawMessage) error {
+	actor := ActorFromContext(ctx)
+	entry := models.AuditLog{
+		RequestID:        actor.RequestID,
+		ActorUserID:      actor.UserID,
+		ServiceAccountID: actor.ServiceAccountID,
+		ImpersonatorID:   actor.ImpersonatorID,
+		ActorRole:        actor.Role,
+		IPAddress:        actor.IP,
+		Action:           action,
+		EntityType:       table,
+		EntityID:         id,
+		OldData:          before,
+		NewData:          after,
+		CreatedAt:        time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	entry.Changes = changes
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, chainLockKey); err != nil {
+		return err
+	}
+
+	entry.PrevHash = genesisHash
+	err = tx.QueryRow(ctx, `SELECT hash FROM "audit_log" ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if entry.Hash, err = Hash(entry); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO "audit_log" (request_id, actor_user_id, service_account_id, impersonator_id, actor_role, ip_address, action, entity_type, entity_id, old_data, new_data, changes, prev_hash, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+	_, err = tx.Exec(ctx, query, entry.RequestID, entry.ActorUserID, entry.ServiceAccountID, entry.ImpersonatorID, entry.ActorRole, entry.IPAddress,
+		entry.Action, entry.EntityType, entry.EntityID, entry.OldData, entry.NewData, entry.Changes, entry.PrevHash, entry.Hash, entry.CreatedAt)
+	return err
+}
+
+// Diff maps every top-level field whose value differs between before and
+// after to {"old": ..., "new": ...}.
+func Diff(before, after json.RawMessage) (json.RawMessage, error) {
+	var oldFields, newFields map[string]any
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &oldFields); err != nil {
+			return nil, err
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &newFields); err != nil {
+			return nil, err
+		}
+	}
+
+	type change struct {
+		Old any `json:"old"`
+		New any `json:"new"`
+	}
+	changes := map[string]change{}
+	for key, newValue := range newFields {
+		oldValue, existed := oldFields[key]
+		if !existed || !sameJSON(oldValue, newValue) {
+			changes[key] = change{Old: oldValue, New: newValue}
+		}
+	}
+	for key, oldValue := range oldFields {
+		if _, exists := newFields[key]; !exists {
+			changes[key] = change{Old: oldValue}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+// Hash returns the chain hash of an entry. JSON columns are re-encoded with
+// sorted keys first, so the result does not depend on how jsonb stored them.
+func Hash(entry models.AuditLog) (string, error) {
+	oldData, err := canonical(entry.OldData)
+	if err != nil {
+		return "", err
+	}
+	newData, err := canonical(entry.NewData)
+	if err != nil {
+		return "", err
+	}
+
+	fields := []any{
+		entry.PrevHash,
+		entry.RequestID,
+		entry.ActorUserID,
+		entry.ServiceAccountID,
+		entry.ActorRole,
+		entry.IPAddress,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		oldData,
+		newData,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// Appended only when set so entries written before impersonation
+	// existed keep their hash.
+	if entry.ImpersonatorID != nil {
+		fields = append(fields, *entry.ImpersonatorID)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func canonical(data json.RawMessage) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func sameJSON(a, b any) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+	return errX == nil && errY == nil && string(x) == string(y)
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+)
+
+// Verify re-hashes the whole log in order and reports the first entry whose
+// link or content no longer matches.
+func Verify(ctx context.Context, db *database.Database) (models.AuditChainStatus, error) {
+	status := models.AuditChainStatus{Valid: true, LastHash: genesisHash}
+
+	query := `
+		SELECT id, COALESCE(request_id, ''), actor_user_id, service_account_id, impersonator_id, COALESCE(actor_role, ''), COALESCE(ip_address, ''),
+			action, entity_type, entity_id, old_data, new_data, prev_hash, hash, created_at
+		FROM "audit_log" ORDER BY id ASC
+	`
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return status, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditLog
+		err := rows.Scan(&e.ID, &e.RequestID, &e.ActorUserID, &e.ServiceAccountID, &e.ImpersonatorID, &e.ActorRole, &e.IPAddress,
+			&e.Action, &e.EntityType, &e.EntityID, &e.OldData, &e.NewData, &e.PrevHash, &e.Hash, &e.CreatedAt)
+		if err != nil {
+			return status, err
+		}
+
+		hash, err := Hash(e)
+		if err != nil {
+			return status, err
+		}
+		if e.PrevHash != status.LastHash || hash != e.Hash {
+			status.Valid = false
+			status.BrokenAt = &e.ID
+			return status, nil
+		}
+
+		status.Checked++
+		status.LastHash = e.Hash
+	}
+
+	return status, rows.Err()
+}
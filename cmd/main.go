@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"mbkm-api/config"
 	"mbkm-api/database"
 	_ "mbkm-api/docs"
-	"mbkm-api/models"
+	"mbkm-api/middleware"
+	"mbkm-api/repository"
 	"mbkm-api/routes"
+	"mbkm-api/utils"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 // @title MBKM API
@@ -40,28 +47,70 @@ func main() {
 		log.Fatal("❌ Failed to load config:", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "keys:generate" {
+		algorithm := "ed25519"
+		if len(os.Args) > 2 {
+			algorithm = os.Args[2]
+		}
+		if cfg.JWTKeysDir == "" {
+			log.Fatal("JWT_KEYS_DIR must be set to generate a signing key")
+		}
+		kid, err := utils.GenerateSigningKey(cfg.JWTKeysDir, algorithm)
+		if err != nil {
+			log.Fatal("Key generation failed:", err)
+		}
+		log.Printf("🔑 Generated %s key %s in %s", algorithm, kid, cfg.JWTKeysDir)
+		log.Printf("Set JWT_SIGNING_KEY_ID=%s once every instance has loaded it", kid)
+		return
+	}
+
+	tokens, err := utils.NewTokenManager(utils.TokenManagerConfig{
+		KeysDir:      cfg.JWTKeysDir,
+		SigningKeyID: cfg.JWTSigningKeyID,
+		Secret:       cfg.JWTSecret,
+		Issuer:       cfg.JWTIssuer,
+		Audience:     cfg.JWTAudience,
+	})
+	if err != nil {
+		log.Fatal("❌ Failed to load JWT keys:", err)
+	}
+
 	db, err := database.NewDatabase(cfg)
 	if err != nil {
 		log.Fatal("❌ Failed to connect to database:", err)
 	}
 	defer db.Close()
 
-	// Auto-migrate menggunakan GORM
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Lecturer{},
-		&models.Program{},
-		&models.Enrollment{},
-		&models.Assessment{},
-	); err != nil {
-		log.Fatal("Auto-migration failed:", err)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatal("❌ Failed to load migrations:", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, migrator, os.Args[2:])
+		return
+	}
+
+	// Schema changes are applied by "migrate", never implicitly on start.
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		log.Fatal("❌ Failed to read migration status:", err)
+	}
+	if pending > 0 {
+		log.Fatalf("❌ %d pending migration(s), run \"go run cmd/main.go migrate up\" first", pending)
+	}
+
+	// So is the permission catalog: "migrate up" syncs it.
+	missing, err := database.NewSeeder(db).MissingPermissions(context.Background())
+	if err != nil {
+		log.Fatal("❌ Failed to read permissions:", err)
+	}
+	if missing > 0 {
+		log.Fatalf("❌ %d permission(s) not in the database, run \"go run cmd/main.go migrate up\" first", missing)
 	}
 
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "migrate":
-			log.Println("Migration complete, exiting...")
-			return
 		case "seed":
 			log.Println("🌱 Running seeder...")
 			seeder := database.NewSeeder(db)
@@ -98,30 +147,25 @@ func main() {
 	}
 
 	app := fiber.New(fiber.Config{
-		AppName: "MBKM API v1.0",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"success": false,
-				"message": err.Error(),
-			})
-		},
+		AppName:      "MBKM API v1.0",
+		ErrorHandler: utils.ErrorHandler,
 	})
 
 	app.Use(recover.New())
+	app.Use(requestid.New())
 	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
+		Format: "[${time}] ${locals:requestid} ${status} - ${method} ${path} (${latency})\n",
 	}))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:  "*",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-Request-ID, If-Match, If-None-Match",
+		ExposeHeaders: "X-Request-ID, ETag",
+		AllowMethods:  "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	}))
 
-	routes.SetupRoutes(app, db, cfg)
+	app.Use(middleware.TimeoutMiddleware(time.Duration(cfg.RequestTimeout) * time.Second))
+
+	routes.SetupRoutes(app, db, repository.NewPostgres(db), cfg, tokens)
 
 	log.Printf("Server running on port %s\n", cfg.ServerPort)
 	log.Printf("Health check: http://localhost:%s/health\n", cfg.ServerPort)
@@ -130,3 +174,63 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// runMigrate handles "migrate [up|down|status|to <version>]"; plain
+// "migrate" means up.
+func runMigrate(db *database.Database, migrator *database.Migrator, args []string) {
+	ctx := context.Background()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		if err := migrator.Up(ctx); err != nil {
+			log.Fatal("❌ Migration failed:", err)
+		}
+		if err := database.NewSeeder(db).SeedPermissions(); err != nil {
+			log.Fatal("Permission seeding failed:", err)
+		}
+		log.Println("✅ Migration complete")
+	case "down":
+		if err := migrator.Down(ctx); err != nil {
+			log.Fatal("❌ Migration failed:", err)
+		}
+		log.Println("✅ Migration reverted")
+	case "to":
+		if len(args) < 2 {
+			log.Fatal("Usage: migrate to <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatal("Invalid version:", args[1])
+		}
+		if err := migrator.To(ctx, version); err != nil {
+			log.Fatal("❌ Migration failed:", err)
+		}
+		log.Printf("✅ Schema is at version %d", version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal("❌ Failed to read migration status:", err)
+		}
+		fmt.Printf("%-8s %-30s %s\n", "VERSION", "NAME", "APPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				applied += " (modified since applied)"
+			}
+			if s.Unknown {
+				applied += " (not in this build)"
+			}
+			fmt.Printf("%04d     %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, use up, down, status or to <version>", command)
+	}
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"mbkm-api/models"
+	"mbkm-api/utils"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -16,8 +19,76 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 
-	JWTSecret     string
-	JWTExpiration int
+	JWTSecret       string
+	JWTExpiration   int
+	JWTKeysDir      string
+	JWTSigningKeyID string
+	JWTIssuer       string
+	JWTAudience     string
+
+	RefreshTokenExpiration int
+	InvitationExpiration   int
+
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+
+	PasswordResetExpiration int
+	AppBaseURL              string
+
+	MailDriver   string
+	MailFrom     string
+	MailLogDir   string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// Login throttling. Window, base and max are in seconds; the rate limit is
+	// requests per minute per IP on the public auth endpoints.
+	LoginMaxAttempts   int
+	LoginIPMaxAttempts int
+	LoginAttemptWindow int
+	LoginLockoutBase   int
+	LoginLockoutMax    int
+	LoginRateLimit     int
+
+	MFAIssuer        string
+	MFARequiredRoles []string
+
+	// Seconds the role to permission mapping is cached per instance.
+	PermissionCacheTTL int
+
+	// Minutes an admin impersonation token stays valid.
+	ImpersonationTTL int
+
+	// Seconds. RequestTimeout bounds the database work of one request;
+	// DBStatementTimeout is enforced by Postgres on every statement, so a
+	// runaway query stops even when nobody waits for it anymore.
+	RequestTimeout     int
+	DBStatementTimeout int
+
+	// RequireIfMatch rejects updates and deletes of academic records that
+	// do not name the version they were based on.
+	RequireIfMatch bool
+
+	// OpenID Connect single sign-on, enabled when issuer and client id are
+	// set. OIDCRoleMapping is evaluated in order; the first IdP value found
+	// in OIDCRoleClaim decides the role.
+	OIDCIssuerURL          string
+	OIDCClientID           string
+	OIDCClientSecret       string
+	OIDCRedirectURL        string
+	OIDCScopes             []string
+	OIDCRoleClaim          string
+	OIDCRoleMapping        []OIDCRoleRule
+	OIDCDefaultRole        string
+	OIDCAutoProvision      bool
+	OIDCSyncRole           bool
+	OIDCPostLoginRedirect  string
+	OIDCAuthRequestTimeout int
 
 	ServerPort string
 }
@@ -26,6 +97,21 @@ func LoadConfig() (*Config, error) {
 	godotenv.Load()
 
 	jwtExp, _ := strconv.Atoi(os.Getenv("JWT_EXPIRATION"))
+	requireUpper, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_UPPER"))
+	requireLower, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_LOWER"))
+	requireDigit, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT"))
+	requireSymbol, _ := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL"))
+	oidcSyncRole, _ := strconv.ParseBool(os.Getenv("OIDC_SYNC_ROLE"))
+	requireIfMatch, _ := strconv.ParseBool(os.Getenv("REQUIRE_IF_MATCH"))
+	oidcAutoProvision, err := strconv.ParseBool(getEnv("OIDC_AUTO_PROVISION", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_AUTO_PROVISION: %w", err)
+	}
+
+	oidcRoleMapping, err := parseOIDCRoleMapping(getEnvList("OIDC_ROLE_MAPPING"))
+	if err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
 		DBHost:        os.Getenv("DB_HOST"),
@@ -37,16 +123,167 @@ func LoadConfig() (*Config, error) {
 		JWTSecret:     os.Getenv("JWT_SECRET"),
 		JWTExpiration: jwtExp,
 		ServerPort:    os.Getenv("SERVER_PORT"),
+
+		JWTKeysDir:      os.Getenv("JWT_KEYS_DIR"),
+		JWTSigningKeyID: os.Getenv("JWT_SIGNING_KEY_ID"),
+		JWTIssuer:       getEnv("JWT_ISSUER", "mbkm-api"),
+		JWTAudience:     getEnv("JWT_AUDIENCE", "mbkm-api"),
+
+		RefreshTokenExpiration: getEnvInt("REFRESH_TOKEN_EXPIRATION", 24*30),
+		InvitationExpiration:   getEnvInt("INVITATION_EXPIRATION", 72),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequireUpper:  requireUpper,
+		PasswordRequireLower:  requireLower,
+		PasswordRequireDigit:  requireDigit,
+		PasswordRequireSymbol: requireSymbol,
+
+		PasswordResetExpiration: getEnvInt("PASSWORD_RESET_EXPIRATION", 30),
+		AppBaseURL:              getEnv("APP_BASE_URL", "http://localhost:3000"),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "MBKM <no-reply@mbkm.ac.id>"),
+		MailLogDir:   os.Getenv("MAIL_LOG_DIR"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "1025"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+		LoginMaxAttempts:   getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts: getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginAttemptWindow: getEnvInt("LOGIN_ATTEMPT_WINDOW", 900),
+		LoginLockoutBase:   getEnvInt("LOGIN_LOCKOUT_BASE", 60),
+		LoginLockoutMax:    getEnvInt("LOGIN_LOCKOUT_MAX", 3600),
+		LoginRateLimit:     getEnvInt("LOGIN_RATE_LIMIT", 10),
+
+		MFAIssuer:        getEnv("MFA_ISSUER", "MBKM"),
+		MFARequiredRoles: getEnvList("MFA_REQUIRED_ROLES"),
+
+		PermissionCacheTTL: getEnvInt("PERMISSION_CACHE_TTL", 60),
+
+		ImpersonationTTL: getEnvInt("IMPERSONATION_TTL", 15),
+
+		RequestTimeout:     getEnvInt("REQUEST_TIMEOUT", 15),
+		DBStatementTimeout: getEnvInt("DB_STATEMENT_TIMEOUT", 10),
+
+		RequireIfMatch: requireIfMatch,
+
+		OIDCIssuerURL:          os.Getenv("OIDC_ISSUER_URL"),
+		OIDCClientID:           os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:       os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:        os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:             getEnvList("OIDC_SCOPES"),
+		OIDCRoleClaim:          getEnv("OIDC_ROLE_CLAIM", "groups"),
+		OIDCRoleMapping:        oidcRoleMapping,
+		OIDCDefaultRole:        getEnv("OIDC_DEFAULT_ROLE", models.RoleStudent),
+		OIDCAutoProvision:      oidcAutoProvision,
+		OIDCSyncRole:           oidcSyncRole,
+		OIDCPostLoginRedirect:  os.Getenv("OIDC_POST_LOGIN_REDIRECT"),
+		OIDCAuthRequestTimeout: getEnvInt("OIDC_AUTH_REQUEST_TIMEOUT", 600),
 	}
 
 	if cfg.DBHost == "" || cfg.DBName == "" {
 		return nil, fmt.Errorf("database configuration is missing")
 	}
 
+	if cfg.OIDCEnabled() {
+		if cfg.OIDCRedirectURL == "" {
+			return nil, fmt.Errorf("OIDC_REDIRECT_URL is required when OIDC is enabled")
+		}
+		if !models.IsValidRole(cfg.OIDCDefaultRole) {
+			return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", cfg.OIDCDefaultRole)
+		}
+	}
+
 	return cfg, nil
 }
 
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvList splits a comma separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// getEnvInt returns the integer value of key, or fallback when it is unset,
+// malformed or not positive.
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+// OIDCRoleRule maps one IdP claim value (a group or role name) to a local role.
+type OIDCRoleRule struct {
+	Value string
+	Role  string
+}
+
+// parseOIDCRoleMapping parses entries of the form "idp-value=role".
+func parseOIDCRoleMapping(entries []string) ([]OIDCRoleRule, error) {
+	rules := make([]OIDCRoleRule, 0, len(entries))
+	for _, entry := range entries {
+		value, role, ok := strings.Cut(entry, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || !models.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q", entry)
+		}
+		rules = append(rules, OIDCRoleRule{Value: value, Role: role})
+	}
+	return rules, nil
+}
+
 func (c *Config) DatabaseURL() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
 }
+
+func (c *Config) PasswordPolicy() utils.PasswordPolicy {
+	return utils.PasswordPolicy{
+		MinLength:     c.PasswordMinLength,
+		RequireUpper:  c.PasswordRequireUpper,
+		RequireLower:  c.PasswordRequireLower,
+		RequireDigit:  c.PasswordRequireDigit,
+		RequireSymbol: c.PasswordRequireSymbol,
+	}
+}
+
+// MFARequiredFor reports whether users with role must enroll in 2FA.
+func (c *Config) MFARequiredFor(role string) bool {
+	for _, r := range c.MFARequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != "" && c.OIDCClientID != ""
+}
+
+// OIDCRoleFor returns the role for the first mapping rule whose value is
+// present in values, and false when none matches.
+func (c *Config) OIDCRoleFor(values []string) (string, bool) {
+	for _, rule := range c.OIDCRoleMapping {
+		for _, v := range values {
+			if v == rule.Value {
+				return rule.Role, true
+			}
+		}
+	}
+	return "", false
+}
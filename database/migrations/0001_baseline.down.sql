@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS "audit_log" CASCADE;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+DROP TABLE IF EXISTS "role_permission" CASCADE;
+DROP TABLE IF EXISTS "permission" CASCADE;
+DROP TABLE IF EXISTS "api_key" CASCADE;
+DROP TABLE IF EXISTS "service_account" CASCADE;
+DROP TABLE IF EXISTS "oidc_auth_request" CASCADE;
+DROP TABLE IF EXISTS "user_recovery_code" CASCADE;
+DROP TABLE IF EXISTS "login_throttle" CASCADE;
+DROP TABLE IF EXISTS "password_reset_token" CASCADE;
+DROP TABLE IF EXISTS "user_invitation" CASCADE;
+DROP TABLE IF EXISTS "user_session" CASCADE;
+DROP TABLE IF EXISTS "assessment" CASCADE;
+DROP TABLE IF EXISTS "enrollment" CASCADE;
+DROP TABLE IF EXISTS "program" CASCADE;
+DROP TABLE IF EXISTS "lecturer" CASCADE;
+DROP TABLE IF EXISTS "user" CASCADE;
@@ -0,0 +1,259 @@
+-- Baseline: the schema previously created by GORM AutoMigrate. Every
+-- statement is idempotent so databases created by AutoMigrate adopt it
+-- without changes.
+
+CREATE TABLE IF NOT EXISTS "user" (
+    id                   BIGSERIAL PRIMARY KEY,
+    username             VARCHAR(100) NOT NULL,
+    email                VARCHAR(100) NOT NULL,
+    password_hash        VARCHAR(255) NOT NULL,
+    full_name            VARCHAR(100),
+    phone                VARCHAR(20),
+    role                 VARCHAR(20) NOT NULL,
+    is_active            BOOLEAN DEFAULT true,
+    must_change_password BOOLEAN DEFAULT false,
+    totp_secret          VARCHAR(64),
+    totp_enabled         BOOLEAN DEFAULT false,
+    totp_last_step       BIGINT DEFAULT 0,
+    oidc_issuer          VARCHAR(255),
+    oidc_subject         VARCHAR(255),
+    created_at           TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_username ON "user" (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email ON "user" (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_oidc_identity ON "user" (oidc_issuer, oidc_subject);
+
+CREATE TABLE IF NOT EXISTS "lecturer" (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    nidn       VARCHAR(20) NOT NULL,
+    full_name  VARCHAR(100) NOT NULL,
+    phone      VARCHAR(20),
+    department VARCHAR(100),
+    is_active  BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lecturer_user_id ON "lecturer" (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lecturer_nidn ON "lecturer" (nidn);
+
+CREATE TABLE IF NOT EXISTS "program" (
+    id          BIGSERIAL PRIMARY KEY,
+    code        VARCHAR(20) NOT NULL,
+    name        VARCHAR(100) NOT NULL,
+    description TEXT,
+    credits     BIGINT DEFAULT 3,
+    semester    BIGINT NOT NULL,
+    lecturer_id BIGINT NOT NULL,
+    is_active   BOOLEAN DEFAULT true,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_program_code ON "program" (code);
+
+CREATE TABLE IF NOT EXISTS "enrollment" (
+    id          BIGSERIAL PRIMARY KEY,
+    student_id  BIGINT NOT NULL,
+    program_id  BIGINT NOT NULL,
+    status      VARCHAR(20) DEFAULT 'enrolled',
+    enrolled_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_student_program ON "enrollment" (student_id, program_id);
+
+CREATE TABLE IF NOT EXISTS "assessment" (
+    id            BIGSERIAL PRIMARY KEY,
+    enrollment_id BIGINT NOT NULL,
+    student_id    BIGINT NOT NULL,
+    program_id    BIGINT NOT NULL,
+    category      VARCHAR(50) NOT NULL,
+    score         DECIMAL(5,2) DEFAULT 0,
+    max_score     DECIMAL(5,2),
+    weight        DECIMAL(5,2) DEFAULT 0,
+    notes         TEXT,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS "user_session" (
+    id                 BIGSERIAL PRIMARY KEY,
+    user_id            BIGINT NOT NULL,
+    refresh_token_hash VARCHAR(64) NOT NULL,
+    user_agent         VARCHAR(255),
+    ip_address         VARCHAR(45),
+    expires_at         TIMESTAMPTZ NOT NULL,
+    last_used_at       TIMESTAMPTZ,
+    revoked_at         TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_session_user_id ON "user_session" (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_session_refresh_token_hash ON "user_session" (refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_session_revoked_at ON "user_session" (revoked_at);
+
+CREATE TABLE IF NOT EXISTS "user_invitation" (
+    id               BIGSERIAL PRIMARY KEY,
+    email            VARCHAR(100) NOT NULL,
+    role             VARCHAR(20) NOT NULL,
+    token_hash       VARCHAR(64) NOT NULL,
+    invited_by       BIGINT NOT NULL,
+    expires_at       TIMESTAMPTZ NOT NULL,
+    accepted_at      TIMESTAMPTZ,
+    accepted_user_id BIGINT,
+    revoked_at       TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_invitation_email ON "user_invitation" (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_invitation_token_hash ON "user_invitation" (token_hash);
+
+CREATE TABLE IF NOT EXISTS "password_reset_token" (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_password_reset_token_user_id ON "password_reset_token" (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_token_token_hash ON "password_reset_token" (token_hash);
+
+CREATE TABLE IF NOT EXISTS "login_throttle" (
+    id              BIGSERIAL PRIMARY KEY,
+    kind            VARCHAR(10) NOT NULL,
+    value           VARCHAR(150) NOT NULL,
+    failed_attempts BIGINT NOT NULL DEFAULT 0,
+    last_failed_at  TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_throttle_kind_value ON "login_throttle" (kind, value);
+CREATE INDEX IF NOT EXISTS idx_login_throttle_locked_until ON "login_throttle" (locked_until);
+
+CREATE TABLE IF NOT EXISTS "user_recovery_code" (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_recovery_code_user_id ON "user_recovery_code" (user_id);
+
+CREATE TABLE IF NOT EXISTS "oidc_auth_request" (
+    id            BIGSERIAL PRIMARY KEY,
+    state_hash    VARCHAR(64) NOT NULL,
+    nonce         VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    ip_address    VARCHAR(45),
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oidc_auth_request_state_hash ON "oidc_auth_request" (state_hash);
+CREATE INDEX IF NOT EXISTS idx_oidc_auth_request_expires_at ON "oidc_auth_request" (expires_at);
+
+CREATE TABLE IF NOT EXISTS "service_account" (
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    description TEXT,
+    role        VARCHAR(20) NOT NULL,
+    is_active   BOOLEAN DEFAULT true,
+    created_by  BIGINT NOT NULL,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_account_name ON "service_account" (name);
+
+CREATE TABLE IF NOT EXISTS "api_key" (
+    id                 BIGSERIAL PRIMARY KEY,
+    service_account_id BIGINT NOT NULL,
+    name               VARCHAR(100) NOT NULL,
+    prefix             VARCHAR(16) NOT NULL,
+    key_hash           VARCHAR(64) NOT NULL,
+    scopes             TEXT[] NOT NULL,
+    expires_at         TIMESTAMPTZ,
+    last_used_at       TIMESTAMPTZ,
+    last_used_ip       VARCHAR(45),
+    revoked_at         TIMESTAMPTZ,
+    created_by         BIGINT NOT NULL,
+    created_at         TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_api_key_service_account_id ON "api_key" (service_account_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_key_prefix ON "api_key" (prefix);
+
+CREATE TABLE IF NOT EXISTS "permission" (
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(50) NOT NULL,
+    description VARCHAR(255),
+    created_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_name ON "permission" (name);
+
+CREATE TABLE IF NOT EXISTS "role_permission" (
+    id         BIGSERIAL PRIMARY KEY,
+    role       VARCHAR(30) NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_permission ON "role_permission" (role, permission);
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    id                 BIGSERIAL PRIMARY KEY,
+    request_id         VARCHAR(64),
+    actor_user_id      BIGINT,
+    service_account_id BIGINT,
+    impersonator_id    BIGINT,
+    actor_role         VARCHAR(20),
+    ip_address         VARCHAR(45),
+    action             VARCHAR(20) NOT NULL,
+    entity_type        VARCHAR(30) NOT NULL,
+    entity_id          BIGINT NOT NULL,
+    old_data           JSONB,
+    new_data           JSONB,
+    changes            JSONB,
+    prev_hash          VARCHAR(64) NOT NULL,
+    hash               VARCHAR(64) NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_request_id ON "audit_log" (request_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_user_id ON "audit_log" (actor_user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_service_account_id ON "audit_log" (service_account_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_impersonator_id ON "audit_log" (impersonator_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON "audit_log" (entity_type, entity_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_hash ON "audit_log" (hash);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON "audit_log" (created_at);
+
+-- audit_log is append-only, enforced by the database itself.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_update ON "audit_log";
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON "audit_log";
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON "audit_log"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- GORM filled these timestamps client-side; raw SQL inserts rely on the
+-- database instead.
+ALTER TABLE "user" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "lecturer" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "program" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "enrollment" ALTER COLUMN enrolled_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "assessment" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "user_session" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "user_invitation" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "password_reset_token" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "login_throttle" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "user_recovery_code" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "oidc_auth_request" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "service_account" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "api_key" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "permission" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "role_permission" ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,41 @@
+ALTER TABLE "login_throttle" DROP CONSTRAINT IF EXISTS chk_login_throttle_kind;
+
+ALTER TABLE "role_permission" DROP CONSTRAINT IF EXISTS fk_role_permission_permission;
+
+ALTER TABLE "api_key"
+    DROP CONSTRAINT IF EXISTS fk_api_key_created_by,
+    DROP CONSTRAINT IF EXISTS fk_api_key_service_account;
+
+ALTER TABLE "service_account" DROP CONSTRAINT IF EXISTS fk_service_account_created_by;
+
+ALTER TABLE "user_invitation"
+    DROP CONSTRAINT IF EXISTS fk_user_invitation_accepted_user,
+    DROP CONSTRAINT IF EXISTS fk_user_invitation_invited_by;
+UPDATE "user_invitation" SET invited_by = 0 WHERE invited_by IS NULL;
+ALTER TABLE "user_invitation" ALTER COLUMN invited_by SET NOT NULL;
+
+ALTER TABLE "user_recovery_code" DROP CONSTRAINT IF EXISTS fk_user_recovery_code_user;
+ALTER TABLE "password_reset_token" DROP CONSTRAINT IF EXISTS fk_password_reset_token_user;
+ALTER TABLE "user_session" DROP CONSTRAINT IF EXISTS fk_user_session_user;
+
+ALTER TABLE "assessment"
+    DROP CONSTRAINT IF EXISTS chk_assessment_weight,
+    DROP CONSTRAINT IF EXISTS chk_assessment_max_score,
+    DROP CONSTRAINT IF EXISTS chk_assessment_score,
+    DROP CONSTRAINT IF EXISTS fk_assessment_enrollment;
+DROP INDEX IF EXISTS idx_assessment_enrollment_id;
+
+ALTER TABLE "enrollment"
+    DROP CONSTRAINT IF EXISTS chk_enrollment_status,
+    DROP CONSTRAINT IF EXISTS fk_enrollment_program,
+    DROP CONSTRAINT IF EXISTS fk_enrollment_student;
+DROP INDEX IF EXISTS idx_enrollment_identity;
+DROP INDEX IF EXISTS idx_enrollment_program_id;
+
+ALTER TABLE "program"
+    DROP CONSTRAINT IF EXISTS chk_program_semester,
+    DROP CONSTRAINT IF EXISTS chk_program_credits,
+    DROP CONSTRAINT IF EXISTS fk_program_lecturer;
+DROP INDEX IF EXISTS idx_program_lecturer_id;
+
+ALTER TABLE "lecturer" DROP CONSTRAINT IF EXISTS fk_lecturer_user;
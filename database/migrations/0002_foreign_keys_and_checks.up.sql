@@ -0,0 +1,66 @@
+-- Referential integrity and value rules the API used to trust the handlers
+-- for. Academic records are never removed implicitly: deleting a lecturer,
+-- program or enrollment that still has dependants is rejected, so every
+-- removal goes through the API and lands in the audit log. Credentials and
+-- sessions follow their owner. audit_log has no foreign keys on purpose; it
+-- must outlive the rows it describes.
+--
+-- Rows that already violate a rule make this migration fail and roll back;
+-- fix them first (see README, "Integritas data").
+
+ALTER TABLE "lecturer"
+    ADD CONSTRAINT fk_lecturer_user FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE RESTRICT;
+
+ALTER TABLE "program"
+    ADD CONSTRAINT fk_program_lecturer FOREIGN KEY (lecturer_id) REFERENCES "lecturer" (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT chk_program_credits CHECK (credits BETWEEN 1 AND 24),
+    ADD CONSTRAINT chk_program_semester CHECK (semester BETWEEN 1 AND 14);
+CREATE INDEX IF NOT EXISTS idx_program_lecturer_id ON "program" (lecturer_id);
+
+ALTER TABLE "enrollment"
+    ADD CONSTRAINT fk_enrollment_student FOREIGN KEY (student_id) REFERENCES "user" (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT fk_enrollment_program FOREIGN KEY (program_id) REFERENCES "program" (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT chk_enrollment_status CHECK (status IN ('enrolled', 'active', 'completed', 'dropped'));
+CREATE INDEX IF NOT EXISTS idx_enrollment_program_id ON "enrollment" (program_id);
+-- Target of the assessment foreign key below.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_enrollment_identity ON "enrollment" (id, student_id, program_id);
+
+-- student_id and program_id are copied from the enrollment; the composite key
+-- keeps the copies from drifting.
+ALTER TABLE "assessment"
+    ADD CONSTRAINT fk_assessment_enrollment FOREIGN KEY (enrollment_id, student_id, program_id)
+        REFERENCES "enrollment" (id, student_id, program_id) ON DELETE RESTRICT,
+    ADD CONSTRAINT chk_assessment_score CHECK (score >= 0 AND score <= max_score),
+    ADD CONSTRAINT chk_assessment_max_score CHECK (max_score > 0),
+    ADD CONSTRAINT chk_assessment_weight CHECK (weight BETWEEN 0 AND 100);
+CREATE INDEX IF NOT EXISTS idx_assessment_enrollment_id ON "assessment" (enrollment_id);
+
+ALTER TABLE "user_session"
+    ADD CONSTRAINT fk_user_session_user FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE;
+
+ALTER TABLE "password_reset_token"
+    ADD CONSTRAINT fk_password_reset_token_user FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE;
+
+ALTER TABLE "user_recovery_code"
+    ADD CONSTRAINT fk_user_recovery_code_user FOREIGN KEY (user_id) REFERENCES "user" (id) ON DELETE CASCADE;
+
+-- Invitations sent with an API key have no inviting user; they used to
+-- store 0.
+ALTER TABLE "user_invitation" ALTER COLUMN invited_by DROP NOT NULL;
+UPDATE "user_invitation" SET invited_by = NULL WHERE invited_by = 0;
+ALTER TABLE "user_invitation"
+    ADD CONSTRAINT fk_user_invitation_invited_by FOREIGN KEY (invited_by) REFERENCES "user" (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_user_invitation_accepted_user FOREIGN KEY (accepted_user_id) REFERENCES "user" (id) ON DELETE SET NULL;
+
+ALTER TABLE "service_account"
+    ADD CONSTRAINT fk_service_account_created_by FOREIGN KEY (created_by) REFERENCES "user" (id) ON DELETE RESTRICT;
+
+ALTER TABLE "api_key"
+    ADD CONSTRAINT fk_api_key_service_account FOREIGN KEY (service_account_id) REFERENCES "service_account" (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_api_key_created_by FOREIGN KEY (created_by) REFERENCES "user" (id) ON DELETE RESTRICT;
+
+ALTER TABLE "role_permission"
+    ADD CONSTRAINT fk_role_permission_permission FOREIGN KEY (permission) REFERENCES "permission" (name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE "login_throttle"
+    ADD CONSTRAINT chk_login_throttle_kind CHECK (kind IN ('email', 'ip'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_program_search_vector;
+ALTER TABLE "program" DROP COLUMN IF EXISTS search_vector;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS mbkm_indonesian;
+DROP EXTENSION IF EXISTS unaccent;
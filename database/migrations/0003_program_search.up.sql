@@ -0,0 +1,22 @@
+-- Keyword search over the program catalogue. mbkm_indonesian is the built-in
+-- Indonesian Snowball stemmer (PostgreSQL 12+) with accents folded first, so
+-- affixed forms and "café" / "cafe" match each other. Code and name outrank
+-- the description.
+--
+-- unaccent ships with postgresql-contrib and is a trusted extension, so the
+-- database owner can create it without superuser rights.
+
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION mbkm_indonesian (COPY = pg_catalog.indonesian);
+ALTER TEXT SEARCH CONFIGURATION mbkm_indonesian
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, indonesian_stem;
+
+-- to_tsvector with an explicit configuration is immutable, which a generated
+-- column requires; unaccent() itself is not.
+ALTER TABLE "program" ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('mbkm_indonesian', COALESCE(code, '')), 'A') ||
+    setweight(to_tsvector('mbkm_indonesian', COALESCE(name, '')), 'A') ||
+    setweight(to_tsvector('mbkm_indonesian', COALESCE(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_program_search_vector ON "program" USING GIN (search_vector);
@@ -0,0 +1,4 @@
+ALTER TABLE "assessment" DROP COLUMN IF EXISTS version;
+ALTER TABLE "enrollment" DROP COLUMN IF EXISTS version;
+ALTER TABLE "lecturer" DROP COLUMN IF EXISTS version;
+ALTER TABLE "program" DROP COLUMN IF EXISTS version;
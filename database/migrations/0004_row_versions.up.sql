@@ -0,0 +1,8 @@
+-- Row versions for optimistic concurrency. Every update increments version;
+-- clients send it back in If-Match and a write against a newer row fails
+-- instead of overwriting it.
+
+ALTER TABLE "program" ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "lecturer" ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "enrollment" ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "assessment" ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
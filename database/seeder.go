@@ -2,8 +2,11 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"mbkm-api/models"
 	"mbkm-api/utils"
+	"slices"
 )
 
 type Seeder struct {
@@ -294,6 +297,72 @@ func (s *Seeder) SeedLecturers() error {
 	return nil
 }
 
+// SeedPermissions syncs the permission catalog into the database. Only
+// permissions that are new to the database get their default role grants, so
+// edits made through the API survive restarts and upgrades.
+func (s *Seeder) SeedPermissions() error {
+	ctx := context.Background()
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, p := range models.PermissionCatalog {
+		// xmax is 0 only for rows this statement inserted.
+		var id int
+		query := `
+			INSERT INTO "permission" (name, description, created_at)
+			VALUES ($1, $2, CURRENT_TIMESTAMP)
+			ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description
+			RETURNING id, (xmax = 0)
+		`
+		var inserted bool
+		if err := tx.QueryRow(ctx, query, p.Name, p.Description).Scan(&id, &inserted); err != nil {
+			return fmt.Errorf("failed to seed permission %s: %w", p.Name, err)
+		}
+		if !inserted {
+			continue
+		}
+
+		roles := []string{models.RoleAdmin}
+		for role, perms := range models.DefaultRolePermissions {
+			if slices.Contains(perms, p.Name) && !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+
+		for _, role := range roles {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO "role_permission" (role, permission, created_at)
+				VALUES ($1, $2, CURRENT_TIMESTAMP)
+				ON CONFLICT (role, permission) DO NOTHING
+			`, role, p.Name)
+			if err != nil {
+				return fmt.Errorf("failed to grant %s to %s: %w", p.Name, role, err)
+			}
+		}
+		log.Printf("✅ Permission added: %s (%d roles)", p.Name, len(roles))
+	}
+
+	return tx.Commit(ctx)
+}
+
+// MissingPermissions counts catalog permissions that SeedPermissions has not
+// stored yet. It only reads, so the server can check it on every start.
+func (s *Seeder) MissingPermissions(ctx context.Context) (int, error) {
+	names := make([]string, len(models.PermissionCatalog))
+	for i, p := range models.PermissionCatalog {
+		names[i] = p.Name
+	}
+
+	var missing int
+	query := `SELECT COUNT(*) FROM UNNEST($1::text[]) AS n(name) WHERE NOT EXISTS (SELECT 1 FROM "permission" p WHERE p.name = n.name)`
+	err := s.db.Pool.QueryRow(ctx, query, names).Scan(&missing)
+	return missing, err
+}
+
 func (s *Seeder) SeedAll() error {
 	log.Println("🌱 Starting database seeding...")
 
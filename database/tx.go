@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// txMaxAttempts bounds how often InTx runs a unit of work that keeps losing
+// serialization conflicts before giving up with the last error.
+const txMaxAttempts = 5
+
+// Querier is implemented by both the pool and a transaction. Begin on a
+// transaction opens a savepoint.
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+// Conn returns the transaction InTx stored in ctx, or the pool.
+func (db *Database) Conn(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db.Pool
+}
+
+// InTx runs fn in a serializable transaction. Queries made through Conn with
+// the ctx passed to fn join it, and a nested InTx joins the outer one. When
+// Postgres aborts the transaction with a serialization failure or deadlock,
+// the whole of fn runs again in a fresh transaction, so fn must not have side
+// effects outside the database.
+func (db *Database) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	var err error
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		err = pgx.BeginTxFunc(ctx, db.Pool, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(tx pgx.Tx) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+		if !retryable(err) || attempt == txMaxAttempts {
+			break
+		}
+
+		// Back off a little, with jitter so the conflicting transactions do
+		// not collide again in lockstep.
+		wait := time.Duration(attempt)*10*time.Millisecond + time.Duration(rand.Intn(10))*time.Millisecond
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+	return err
+}
+
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"context"
-	"mbkm-api/database"
 	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/service"
 	"mbkm-api/utils"
 	"strconv"
 
@@ -11,16 +12,18 @@ import (
 )
 
 type AssessmentHandler struct {
-	db *database.Database
+	repos       *repository.Repositories
+	policy      *policy.Policy
+	assessments *service.AssessmentService
 }
 
-func NewAssessmentHandler(db *database.Database) *AssessmentHandler {
-	return &AssessmentHandler{db: db}
+func NewAssessmentHandler(repos *repository.Repositories) *AssessmentHandler {
+	return &AssessmentHandler{repos: repos, policy: policy.New(repos.Owners), assessments: service.NewAssessmentService(repos)}
 }
 
 // GetByEnrollment godoc
 // @Summary Get assessments by enrollment
-// @Description Retrieve all assessments for a specific enrollment
+// @Description Retrieve all assessments for a specific enrollment. Students only see their own enrollments and lecturers those in programs they teach.
 // @Tags Assessments
 // @Accept json
 // @Produce json
@@ -29,6 +32,8 @@ func NewAssessmentHandler(db *database.Database) *AssessmentHandler {
 // @Success 200 {array} models.Assessment "Assessments retrieved successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid enrollment ID"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not allowed to view this enrollment"
+// @Failure 404 {object} map[string]interface{} "Enrollment not found"
 // @Router /assessments/enrollment/{enrollmentId} [get]
 func (h *AssessmentHandler) GetByEnrollment(c *fiber.Ctx) error {
 	enrollmentID, err := strconv.Atoi(c.Params("enrollmentId"))
@@ -36,59 +41,52 @@ func (h *AssessmentHandler) GetByEnrollment(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid enrollment ID")
 	}
 
-	ctx := context.Background()
-	query := `SELECT id, enrollment_id, student_id, program_id, category, score, max_score, weight, notes, created_at, updated_at FROM "assessment" WHERE enrollment_id = $1 ORDER BY created_at DESC`
+	ctx := requestContext(c)
+	if err := h.policy.CanViewEnrollment(ctx, policy.ActorFrom(c), enrollmentID); err != nil {
+		return denied(c, err, "Enrollment not found")
+	}
 
-	rows, err := h.db.Pool.Query(ctx, query, enrollmentID)
+	assessments, err := h.repos.Assessments.ListByEnrollment(ctx, enrollmentID)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Failed to fetch assessments")
+		return utils.DBError(err, "Failed to fetch assessments")
 	}
-	defer rows.Close()
 
-	var assessments []models.Assessment
-	for rows.Next() {
-		var a models.Assessment
-		var id, enrollmentID, studentID, programID int64
-		err := rows.Scan(&id, &enrollmentID, &studentID, &programID, &a.Category, &a.Score, &a.MaxScore, &a.Weight, &a.Notes, &a.CreatedAt, &a.UpdatedAt)
-		if err != nil {
-			return utils.InternalServerErrorResponse(c, "Failed to scan assessment data")
-		}
-		a.ID = int(id)
-		a.EnrollmentID = int(enrollmentID)
-		a.StudentID = int(studentID)
-		a.ProgramID = int(programID)
-		assessments = append(assessments, a)
+	return utils.SuccessResponse(c, "Assessments retrieved successfully", assessments)
+}
+
+func (h *AssessmentHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid assessment ID")
 	}
 
-	if assessments == nil {
-		assessments = []models.Assessment{}
+	ctx := requestContext(c)
+	assessment, err := h.repos.Assessments.Get(ctx, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch assessment").On(utils.ErrCodeNotFound, "Assessment not found")
+	}
+	if err := h.policy.CanViewEnrollment(ctx, policy.ActorFrom(c), assessment.EnrollmentID); err != nil {
+		return denied(c, err, "Assessment not found")
 	}
 
-	return utils.SuccessResponse(c, "Assessments retrieved successfully", assessments)
+	c.Set(fiber.HeaderETag, utils.ETag(assessment.Version))
+	return utils.SuccessResponse(c, "Assessment retrieved successfully", assessment)
 }
 
 func (h *AssessmentHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateAssessmentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-	var sid, pid int64
-	enrollmentQuery := `SELECT student_id, program_id FROM "enrollment" WHERE id = $1`
-	err := h.db.Pool.QueryRow(ctx, enrollmentQuery, req.EnrollmentID).Scan(&sid, &pid)
-	if err != nil {
-		return utils.BadRequestResponse(c, "Invalid enrollment ID")
+	assessmentID, err := h.assessments.Create(requestContext(c), policy.ActorFrom(c), req)
+	if isDenied(err) {
+		return denied(c, err, "Enrollment not found")
 	}
-	studentID := int(sid)
-	programID := int(pid)
-
-	var assessmentID int
-	insertQuery := `INSERT INTO "assessment" (enrollment_id, student_id, program_id, category, score, max_score, weight, notes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-
-	err = h.db.Pool.QueryRow(ctx, insertQuery, req.EnrollmentID, studentID, programID, req.Category, req.Score, req.MaxScore, req.Weight, req.Notes).Scan(&assessmentID)
 	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to create assessment")
+		return utils.DBError(err, "Failed to create assessment").
+			On(utils.ErrCodeNotFound, "Enrollment not found").
+			On(utils.ErrCodeReferenceViolation, "Invalid enrollment ID")
 	}
 
 	return utils.CreatedResponse(c, "Assessment created successfully", fiber.Map{"id": assessmentID})
@@ -101,23 +99,20 @@ func (h *AssessmentHandler) Update(c *fiber.Ctx) error {
 	}
 
 	var req models.UpdateAssessmentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-	query := `UPDATE "assessment" SET score = $1, max_score = $2, weight = $3, notes = $4 WHERE id = $5`
-
-	result, err := h.db.Pool.Exec(ctx, query, req.Score, req.MaxScore, req.Weight, req.Notes, id)
-	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to update assessment")
+	version, err := h.assessments.Update(requestContext(c), policy.ActorFrom(c), id, req)
+	if isDenied(err) {
+		return denied(c, err, "Assessment not found")
 	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Assessment not found")
+	if err != nil {
+		return utils.DBError(err, "Failed to update assessment").On(utils.ErrCodeNotFound, "Assessment not found")
 	}
 
-	return utils.SuccessResponse(c, "Assessment updated successfully", nil)
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Assessment updated successfully", fiber.Map{"version": version})
 }
 
 func (h *AssessmentHandler) Delete(c *fiber.Ctx) error {
@@ -126,16 +121,12 @@ func (h *AssessmentHandler) Delete(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid assessment ID")
 	}
 
-	ctx := context.Background()
-	query := `DELETE FROM "assessment" WHERE id = $1`
-
-	result, err := h.db.Pool.Exec(ctx, query, id)
-	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to delete assessment")
+	err = h.assessments.Delete(requestContext(c), policy.ActorFrom(c), id)
+	if isDenied(err) {
+		return denied(c, err, "Assessment not found")
 	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Assessment not found")
+	if err != nil {
+		return utils.DBError(err, "Failed to delete assessment").On(utils.ErrCodeNotFound, "Assessment not found")
 	}
 
 	return utils.SuccessResponse(c, "Assessment deleted successfully", nil)
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"mbkm-api/audit"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"mbkm-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditLogHandler struct {
+	db *database.Database
+}
+
+func NewAuditLogHandler(db *database.Database) *AuditLogHandler {
+	return &AuditLogHandler{db: db}
+}
+
+const auditLogColumns = `id, COALESCE(request_id, ''), actor_user_id, service_account_id, impersonator_id, COALESCE(actor_role, ''), COALESCE(ip_address, ''), action, entity_type, entity_id, old_data, new_data, changes, prev_hash, hash, created_at`
+
+func scanAuditLog(row interface{ Scan(dest ...any) error }, e *models.AuditLog) error {
+	return row.Scan(&e.ID, &e.RequestID, &e.ActorUserID, &e.ServiceAccountID, &e.ImpersonatorID, &e.ActorRole, &e.IPAddress, &e.Action, &e.EntityType, &e.EntityID, &e.OldData, &e.NewData, &e.Changes, &e.PrevHash, &e.Hash, &e.CreatedAt)
+}
+
+// GetAll godoc
+// @Summary List audit log entries
+// @Description Retrieve recorded changes, newest first, with paging and optional filters (admin and accreditation team)
+// @Tags Audit Logs
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param entity_type query string false "Filter by entity (program, lecturer, enrollment, assessment, user)"
+// @Param entity_id query int false "Filter by entity ID"
+// @Param action query string false "Filter by action (create, update, delete, impersonate)"
+// @Param actor_user_id query int false "Filter by acting user"
+// @Param service_account_id query int false "Filter by acting service account"
+// @Param impersonator_id query int false "Filter by administrator impersonating the actor"
+// @Param actor_role query string false "Filter by role of the actor"
+// @Param request_id query string false "Filter by request ID"
+// @Param from query string false "Only entries at or after this RFC 3339 time or YYYY-MM-DD date"
+// @Param to query string false "Only entries before this RFC 3339 time or the end of this YYYY-MM-DD date"
+// @Success 200 {object} map[string]interface{} "Audit logs retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid filter"
+// @Failure 403 {object} map[string]interface{} "Access denied"
+// @Router /audit-logs [get]
+func (h *AuditLogHandler) GetAll(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
+
+	for _, filter := range []string{"entity_type", "action", "actor_role", "request_id"} {
+		if v := c.Query(filter); v != "" {
+			args = append(args, v)
+			where = append(where, filter+" = $"+strconv.Itoa(len(args)))
+		}
+	}
+
+	for _, filter := range []string{"entity_id", "actor_user_id", "service_account_id", "impersonator_id"} {
+		if v := c.Query(filter); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return utils.BadRequestResponse(c, "Invalid "+filter+" filter")
+			}
+			args = append(args, id)
+			where = append(where, filter+" = $"+strconv.Itoa(len(args)))
+		}
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, _, err := parseAuditTime(v)
+		if err != nil {
+			return utils.BadRequestResponse(c, "Invalid from filter")
+		}
+		args = append(args, from)
+		where = append(where, "created_at >= $"+strconv.Itoa(len(args)))
+	}
+
+	if v := c.Query("to"); v != "" {
+		to, isDate, err := parseAuditTime(v)
+		if err != nil {
+			return utils.BadRequestResponse(c, "Invalid to filter")
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		args = append(args, to)
+		where = append(where, "created_at < $"+strconv.Itoa(len(args)))
+	}
+
+	ctx := c.UserContext()
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	if err := h.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM "audit_log" WHERE `+whereClause, args...).Scan(&total); err != nil {
+		return utils.DBError(err, "Failed to count audit logs")
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	query := `SELECT ` + auditLogColumns + ` FROM "audit_log" WHERE ` + whereClause +
+		` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	rows, err := h.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch audit logs")
+	}
+	defer rows.Close()
+
+	var entries []models.AuditLog
+	for rows.Next() {
+		var e models.AuditLog
+		if err := scanAuditLog(rows, &e); err != nil {
+			return utils.DBError(err, "Failed to scan audit log data")
+		}
+		entries = append(entries, e)
+	}
+
+	if entries == nil {
+		entries = []models.AuditLog{}
+	}
+
+	return utils.SuccessResponse(c, "Audit logs retrieved successfully", fiber.Map{
+		"items": entries,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// GetByID godoc
+// @Summary Get an audit log entry
+// @Description Retrieve a single entry including its before/after snapshots and chain hashes
+// @Tags Audit Logs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Audit log ID"
+// @Success 200 {object} models.AuditLog "Audit log retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "Audit log not found"
+// @Router /audit-logs/{id} [get]
+func (h *AuditLogHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid audit log ID")
+	}
+
+	var e models.AuditLog
+	row := h.db.Pool.QueryRow(c.UserContext(), `SELECT `+auditLogColumns+` FROM "audit_log" WHERE id = $1`, id)
+	if err := scanAuditLog(row, &e); err != nil {
+		return utils.DBError(err, "Failed to fetch audit log").On(utils.ErrCodeNotFound, "Audit log not found")
+	}
+
+	return utils.SuccessResponse(c, "Audit log retrieved successfully", e)
+}
+
+// Verify godoc
+// @Summary Verify the audit log hash chain
+// @Description Re-hash every entry in order. valid is false and broken_at names the first entry if any row was altered or removed.
+// @Tags Audit Logs
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.AuditChainStatus "Audit log verified"
+// @Failure 403 {object} map[string]interface{} "Access denied"
+// @Router /audit-logs/verify [get]
+func (h *AuditLogHandler) Verify(c *fiber.Ctx) error {
+	status, err := audit.Verify(c.UserContext(), h.db)
+	if err != nil {
+		return utils.DBError(err, "Failed to verify audit log")
+	}
+
+	return utils.SuccessResponse(c, "Audit log verified", status)
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a plain date, reporting
+// which one it got so "to" can include the whole day.
+func parseAuditTime(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	return t, true, err
+}
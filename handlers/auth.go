@@ -2,42 +2,66 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"mbkm-api/config"
-	"mbkm-api/database"
+	"mbkm-api/mailer"
 	"mbkm-api/models"
+	"mbkm-api/oidc"
+	"mbkm-api/repository"
 	"mbkm-api/utils"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
 type AuthHandler struct {
-	db  *database.Database
-	cfg *config.Config
+	repos    *repository.Repositories
+	cfg      *config.Config
+	tokens   *utils.TokenManager
+	mailer   mailer.Mailer
+	throttle *loginThrottle
+	sso      *oidc.Provider
 }
 
-func NewAuthHandler(db *database.Database, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{db: db, cfg: cfg}
+func NewAuthHandler(repos *repository.Repositories, cfg *config.Config, tokens *utils.TokenManager, m mailer.Mailer) *AuthHandler {
+	h := &AuthHandler{repos: repos, cfg: cfg, tokens: tokens, mailer: m, throttle: newLoginThrottle(repos, cfg)}
+	if cfg.OIDCEnabled() {
+		h.sso = oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
+	}
+	return h
 }
 
 // Register godoc
-// @Summary Register a new user
-// @Description Register a new user with username, email, and password
+// @Summary Register a new student
+// @Description Register a new student account with username, email, and password
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param request body models.RegisterRequest true "Register Request"
 // @Success 201 {object} map[string]interface{} "User registered successfully"
-// @Failure 400 {object} map[string]interface{} "Invalid request or user already exists"
+// @Failure 400 {object} map[string]interface{} "Invalid request or password policy violation"
+// @Failure 409 {object} map[string]interface{} "Username or email already exists"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req models.RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if req.Username == "" || req.Email == "" || req.Password == "" {
-		return utils.BadRequestResponse(c, "Username, email, and password are required")
+	if err := h.cfg.PasswordPolicy().Validate(req.Password); err != nil {
+		return utils.BadRequestResponse(c, err.Error())
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
@@ -45,37 +69,42 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return utils.InternalServerErrorResponse(c, "Failed to hash password")
 	}
 
-	ctx := context.Background()
-	var userID int
-	query := `
-		INSERT INTO "user" (username, email, password_hash, full_name, phone, role)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
-	`
-	err = h.db.Pool.QueryRow(ctx, query, req.Username, req.Email, hashedPassword, req.FullName, req.Phone, req.Role).Scan(&userID)
+	ctx := c.UserContext()
+	// Self-service registration always creates a student account. Privileged
+	// accounts are provisioned by an admin or through an invitation.
+	userID, err := h.repos.Users.Create(ctx, models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		FullName:     req.FullName,
+		Phone:        req.Phone,
+		Role:         models.RoleStudent,
+	})
 	if err != nil {
-		return utils.ConflictResponse(c, "Username or email already exists")
+		return utils.DBError(err, "Failed to register user").On(utils.ErrCodeAlreadyExists, "Username or email already exists")
 	}
 
-	token, err := utils.GenerateToken(userID, req.Email, req.Role, h.cfg.JWTSecret, h.cfg.JWTExpiration)
+	tokens, err := h.createSession(ctx, c, models.User{ID: userID, Email: req.Email, Role: models.RoleStudent})
 	if err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to generate token")
 	}
 
 	return utils.CreatedResponse(c, "User registered successfully", fiber.Map{
-		"token": token,
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": fiber.Map{
 			"id":       userID,
 			"username": req.Username,
 			"email":    req.Email,
-			"role":     req.Role,
+			"role":     models.RoleStudent,
 		},
 	})
 }
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. When the account has 2FA enabled the response carries mfa_required and an mfa_token for /auth/login/2fa instead.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -84,20 +113,47 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 // @Failure 400 {object} map[string]interface{} "Invalid request body"
 // @Failure 401 {object} map[string]interface{} "Invalid credentials"
 // @Failure 403 {object} map[string]interface{} "Account inactive"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 429 {object} map[string]interface{} "Too many failed attempts"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req models.LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	ip := c.IP()
+
+	lockedFor, err := h.throttle.lockedFor(ctx, req.Email, ip)
+	if err != nil {
+		return utils.DBError(err, "Failed to check login attempts")
+	}
+	if lockedFor > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(lockedFor.Seconds())+1))
+		return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Too many failed login attempts, please try again later")
 	}
 
-	ctx := context.Background()
-	var user models.User
-	query := `SELECT id, username, email, password_hash, full_name, role, is_active FROM "user" WHERE email = $1`
-	err := h.db.Pool.QueryRow(ctx, query, req.Email).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, &user.IsActive,
-	)
+	// Look the account up by the same spelling the throttle counts under, so
+	// a lockout cannot be sidestepped by changing the case of the email.
+	user, err := h.repos.Users.GetByEmail(ctx, normalizeEmail(req.Email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unknown emails pay the same bcrypt cost and count towards the same
+		// lockout as wrong passwords, so the two cases are indistinguishable.
+		utils.CheckDummyPassword(req.Password)
+		if err := h.throttle.recordFailure(ctx, req.Email, ip); err != nil {
+			return utils.DBError(err, "Failed to record login attempt")
+		}
+		return utils.UnauthorizedResponse(c, "Invalid email or password")
+	}
 	if err != nil {
+		return utils.DBError(err, "Failed to fetch user")
+	}
+
+	if err := utils.CheckPassword(user.PasswordHash, req.Password); err != nil {
+		if err := h.throttle.recordFailure(ctx, req.Email, ip); err != nil {
+			return utils.DBError(err, "Failed to record login attempt")
+		}
 		return utils.UnauthorizedResponse(c, "Invalid email or password")
 	}
 
@@ -105,22 +161,136 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return utils.ForbiddenResponse(c, "Account is inactive")
 	}
 
-	if err := utils.CheckPassword(user.PasswordHash, req.Password); err != nil {
-		return utils.UnauthorizedResponse(c, "Invalid email or password")
+	// With 2FA enabled the password alone is not enough: hand out a
+	// short-lived challenge token that must be exchanged at /auth/login/2fa.
+	// The failure counter is only cleared once the second factor passes.
+	if user.TOTPEnabled {
+		challenge, err := h.mfaChallenge(user)
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to generate token")
+		}
+
+		return utils.SuccessResponse(c, "Two-factor authentication required", challenge)
+	}
+
+	if err := h.throttle.recordSuccess(ctx, req.Email); err != nil {
+		return utils.DBError(err, "Failed to reset login attempts")
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Email, user.Role, h.cfg.JWTSecret, h.cfg.JWTExpiration)
+	tokens, err := h.createSession(ctx, c, user)
 	if err != nil {
 		return utils.InternalServerErrorResponse(c, "Failed to generate token")
 	}
 
-	user.PasswordHash = ""
 	return utils.SuccessResponse(c, "Login successful", models.LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
 	})
 }
 
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token. The refresh token is rotated on every use.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "Refresh Token Request"
+// @Success 200 {object} models.TokenResponse "Token refreshed"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Invalid or expired refresh token"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshTokenRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	oldHash := utils.HashToken(req.RefreshToken)
+
+	session, user, err := h.repos.Sessions.ByRefreshToken(ctx, oldHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.UnauthorizedResponse(c, "Invalid or expired refresh token")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch session")
+	}
+
+	if !user.IsActive {
+		return utils.ForbiddenResponse(c, "Account is inactive")
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	// Rotate the refresh token. Matching on the old hash makes a concurrent
+	// second use of the same token lose the race instead of minting a twin.
+	rotated, err := h.repos.Sessions.Rotate(ctx, session.ID, oldHash, utils.HashToken(refreshToken))
+	if err != nil {
+		return utils.DBError(err, "Failed to refresh session")
+	}
+
+	if !rotated {
+		return utils.UnauthorizedResponse(c, "Invalid or expired refresh token")
+	}
+
+	token, err := h.accessToken(user, session.ID)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	return utils.SuccessResponse(c, "Token refreshed successfully", models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    h.cfg.JWTExpiration * 3600,
+	})
+}
+
+// Logout godoc
+// @Summary Logout current session
+// @Description Revoke the session bound to the current access token and its refresh token
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Logged out"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+	sessionID := c.Locals("sessionID").(int)
+
+	if err := h.repos.Sessions.Revoke(c.UserContext(), userID, sessionID); err != nil {
+		return utils.DBError(err, "Failed to logout")
+	}
+
+	return utils.SuccessResponse(c, "Logged out successfully", nil)
+}
+
+// LogoutAll godoc
+// @Summary Logout from all devices
+// @Description Revoke every active session of the current user
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "All sessions revoked"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	revoked, err := h.repos.Sessions.RevokeAll(c.UserContext(), userID, 0)
+	if err != nil {
+		return utils.DBError(err, "Failed to revoke sessions")
+	}
+
+	return utils.SuccessResponse(c, "All sessions revoked successfully", fiber.Map{"revoked": revoked})
+}
+
 // GetMe godoc
 // @Summary Get current user profile
 // @Description Get authenticated user's profile information
@@ -135,15 +305,374 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 func (h *AuthHandler) GetMe(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(int)
 
-	ctx := context.Background()
-	var user models.User
-	query := `SELECT id, username, email, full_name, phone, role, is_active, created_at, updated_at FROM "user" WHERE id = $1`
-	err := h.db.Pool.QueryRow(ctx, query, userID).Scan(
-		&user.ID, &user.Username, &user.Email, &user.FullName, &user.Phone, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := h.repos.Users.Get(c.UserContext(), userID)
 	if err != nil {
-		return utils.NotFoundResponse(c, "User not found")
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
 	}
 
 	return utils.SuccessResponse(c, "User profile retrieved", user)
 }
+
+// UpdateMe godoc
+// @Summary Update current user profile
+// @Description Update full name and/or phone of the authenticated user. Omitted fields are left unchanged.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} map[string]interface{} "Profile updated"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/me [put]
+func (h *AuthHandler) UpdateMe(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	var req models.UpdateProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	err := h.repos.Users.Update(c.UserContext(), userID, models.UpdateUserRequest{FullName: req.FullName, Phone: req.Phone})
+	if err != nil {
+		return utils.DBError(err, "Failed to update profile").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	return utils.SuccessResponse(c, "Profile updated successfully", nil)
+}
+
+// ChangePassword godoc
+// @Summary Change own password
+// @Description Verify the current password, set a new one that satisfies the password policy and revoke every other session
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Change Password Request"
+// @Success 200 {object} map[string]interface{} "Password changed"
+// @Failure 400 {object} map[string]interface{} "Invalid request or password policy violation"
+// @Failure 401 {object} map[string]interface{} "Current password is incorrect"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/me/password [post]
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+	sessionID := c.Locals("sessionID").(int)
+
+	var req models.ChangePasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if req.CurrentPassword == req.NewPassword {
+		return utils.BadRequestResponse(c, "New password must differ from the current password")
+	}
+
+	if err := h.cfg.PasswordPolicy().Validate(req.NewPassword); err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.Get(ctx, userID)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if err := utils.CheckPassword(user.PasswordHash, req.CurrentPassword); err != nil {
+		return utils.UnauthorizedResponse(c, "Current password is incorrect")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to hash password")
+	}
+
+	err = h.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := h.repos.Users.SetPassword(ctx, userID, hashedPassword, false); err != nil {
+			return err
+		}
+		// Keep the caller signed in but kick every other device.
+		_, err := h.repos.Sessions.RevokeAll(ctx, userID, sessionID)
+		return err
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to change password")
+	}
+
+	return utils.SuccessResponse(c, "Password changed successfully", nil)
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use, time-limited reset link. The response is the same whether or not the email is registered.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Forgot Password Request"
+// @Success 200 {object} map[string]interface{} "Reset link sent if the account exists"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	const message = "If the email is registered, a password reset link has been sent"
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.GetByEmail(ctx, req.Email)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && !user.IsActive {
+		return utils.SuccessResponse(c, message, nil)
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to create reset token")
+	}
+
+	token, _, err := storeResetToken(ctx, h.repos, h.cfg, user.ID)
+	if err != nil {
+		return utils.DBError(err, "Failed to create reset token")
+	}
+
+	msg := resetLinkMessage(h.cfg, req.Email, token, "We received a request to reset your MBKM password.")
+
+	// Deliver in the background so response time does not reveal whether the
+	// account exists.
+	go func() {
+		if err := h.mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("❌ Failed to send password reset email to %s: %v", req.Email, err)
+		}
+	}()
+
+	return utils.SuccessResponse(c, message, nil)
+}
+
+// storeResetToken invalidates the user's earlier reset links, so only the
+// most recent one works, and stores a new one. It returns the plain token for
+// the email and when it expires.
+func storeResetToken(ctx context.Context, repos *repository.Repositories, cfg *config.Config, userID int) (string, time.Time, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(time.Minute * time.Duration(cfg.PasswordResetExpiration))
+
+	if err := repos.PasswordResets.Issue(ctx, userID, utils.HashToken(token), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// resetLinkMessage is the email carrying a reset link, opened by reason.
+func resetLinkMessage(cfg *config.Config, email, token, reason string) mailer.Message {
+	link := fmt.Sprintf("%s/reset-password?token=%s", cfg.AppBaseURL, token)
+	return mailer.Message{
+		To:      []string{email},
+		Subject: "Reset your MBKM password",
+		Body: fmt.Sprintf("%s\n\n"+
+			"Open the link below within %d minutes to choose a new password:\n%s\n\n"+
+			"If you did not request this, you can ignore this email.\n", reason, cfg.PasswordResetExpiration, link),
+	}
+}
+
+// ResetPassword godoc
+// @Summary Reset password with token
+// @Description Set a new password using a reset token. The token is single-use and every session of the user is revoked.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset Password Request"
+// @Success 200 {object} map[string]interface{} "Password reset successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid or expired token, or password policy violation"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.cfg.PasswordPolicy().Validate(req.NewPassword); err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to hash password")
+	}
+
+	errInvalidToken := errors.New("invalid reset token")
+	err = h.repos.Tx.Do(c.UserContext(), func(ctx context.Context) error {
+		userID, err := h.repos.PasswordResets.Redeem(ctx, utils.HashToken(req.Token))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		if err := h.repos.Users.SetPassword(ctx, userID, hashedPassword, false); err != nil {
+			return err
+		}
+		_, err = h.repos.Sessions.RevokeAll(ctx, userID, 0)
+		return err
+	})
+	if errors.Is(err, errInvalidToken) {
+		return utils.BadRequestResponse(c, "Invalid or expired reset token")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to reset password")
+	}
+
+	return utils.SuccessResponse(c, "Password reset successfully", nil)
+}
+
+// AcceptInvitation godoc
+// @Summary Accept an invitation
+// @Description Redeem a single-use invitation token, set a password and create the invited account
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.AcceptInvitationRequest true "Accept Invitation Request"
+// @Success 201 {object} map[string]interface{} "Invitation accepted"
+// @Failure 400 {object} map[string]interface{} "Invalid request or invitation"
+// @Failure 409 {object} map[string]interface{} "Username or email already exists"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/invitations/accept [post]
+func (h *AuthHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req models.AcceptInvitationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.cfg.PasswordPolicy().Validate(req.Password); err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to hash password")
+	}
+
+	ctx := c.UserContext()
+	var userID int
+	var invitation models.Invitation
+	err = h.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		// Pending locks the invitation so the same token cannot be redeemed
+		// twice.
+		var err error
+		if invitation, err = h.repos.Invitations.Pending(ctx, utils.HashToken(req.Token)); err != nil {
+			return err
+		}
+
+		userID, err = h.repos.Users.Create(ctx, models.User{
+			Username:     req.Username,
+			Email:        invitation.Email,
+			PasswordHash: hashedPassword,
+			FullName:     req.FullName,
+			Phone:        req.Phone,
+			Role:         invitation.Role,
+		})
+		if err != nil {
+			return err
+		}
+
+		return h.repos.Invitations.Accept(ctx, invitation.ID, userID)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.BadRequestResponse(c, "Invalid or expired invitation")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to accept invitation").On(utils.ErrCodeAlreadyExists, "Username or email already exists")
+	}
+
+	tokens, err := h.createSession(ctx, c, models.User{ID: userID, Email: invitation.Email, Role: invitation.Role})
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	return utils.CreatedResponse(c, "Invitation accepted successfully", fiber.Map{
+		"token":         tokens.Token,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user": fiber.Map{
+			"id":       userID,
+			"username": req.Username,
+			"email":    invitation.Email,
+			"role":     invitation.Role,
+		},
+	})
+}
+
+// createSession persists a new refresh-token session for the user and returns
+// an access token bound to it.
+func (h *AuthHandler) createSession(ctx context.Context, c *fiber.Ctx, user models.User) (*models.TokenResponse, error) {
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(time.Hour * time.Duration(h.cfg.RefreshTokenExpiration))
+
+	sessionID, err := h.repos.Sessions.Create(ctx, models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		UserAgent:        truncate(c.Get("User-Agent"), 255),
+		IPAddress:        c.IP(),
+		ExpiresAt:        expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := h.accessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    h.cfg.JWTExpiration * 3600,
+	}, nil
+}
+
+// mfaChallenge issues the short-lived token that LoginTwoFactor exchanges
+// for a session once the second factor is verified.
+func (h *AuthHandler) mfaChallenge(user models.User) (models.MFAChallengeResponse, error) {
+	mfaToken, err := h.tokens.Generate(utils.Claims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Role:    user.Role,
+		Purpose: utils.TokenPurposeMFA,
+	}, mfaChallengeTTL)
+	if err != nil {
+		return models.MFAChallengeResponse{}, err
+	}
+
+	return models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+		ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// accessToken signs an access token for the session. Users whose role
+// mandates 2FA but who have not enrolled yet get a token restricted to the
+// enrollment endpoints.
+func (h *AuthHandler) accessToken(user models.User, sessionID int) (string, error) {
+	return h.tokens.Generate(utils.Claims{
+		UserID:           user.ID,
+		SessionID:        sessionID,
+		Email:            user.Email,
+		Role:             user.Role,
+		MFASetupRequired: h.cfg.MFARequiredFor(user.Role) && !user.TOTPEnabled,
+	}, time.Hour*time.Duration(h.cfg.JWTExpiration))
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"mbkm-api/utils"
+	"mbkm-api/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseBody decodes the request body into dst and checks it against its
+// validate tags.
+func parseBody(c *fiber.Ctx, dst any) error {
+	if err := c.BodyParser(dst); err != nil {
+		return utils.NewAppError(fiber.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid request body")
+	}
+	return validate(dst)
+}
+
+// validate checks dst against its validate tags and reports every failing
+// field in one 422 response.
+func validate(dst any) error {
+	err := validation.Struct(dst)
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		appErr := utils.NewAppError(fiber.StatusUnprocessableEntity, utils.ErrCodeValidation, "Validation failed")
+		appErr.Details = fields
+		return appErr
+	}
+	return err
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"context"
+	"mbkm-api/audit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestContext carries the request's actor to the repositories, which
+// attribute audit log entries to it.
+func requestContext(c *fiber.Ctx) context.Context {
+	return audit.WithActor(c.UserContext(), audit.ActorFrom(c))
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"context"
-	"mbkm-api/database"
 	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/service"
 	"mbkm-api/utils"
 	"strconv"
 
@@ -11,66 +12,90 @@ import (
 )
 
 type EnrollmentHandler struct {
-	db *database.Database
+	repos       *repository.Repositories
+	policy      *policy.Policy
+	enrollments *service.EnrollmentService
 }
 
-func NewEnrollmentHandler(db *database.Database) *EnrollmentHandler {
-	return &EnrollmentHandler{db: db}
+func NewEnrollmentHandler(repos *repository.Repositories) *EnrollmentHandler {
+	return &EnrollmentHandler{repos: repos, policy: policy.New(repos.Owners), enrollments: service.NewEnrollmentService(repos)}
 }
 
 // GetAll godoc
 // @Summary Get all enrollments
-// @Description Retrieve list of all enrollments (admin/lecturer only)
+// @Description Retrieve list of all enrollments (admin/lecturer only). Lecturers only see enrollments in programs they teach.
 // @Tags Enrollments
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number, for offset paging"
+// @Param cursor query string false "next_cursor of the previous page, for cursor paging"
+// @Param sort query string false "created_at (default -created_at), enrolled_at or status; prefix - for descending"
+// @Param status query string false "Filter by status (enrolled, active, completed, dropped)"
+// @Param program_id query int false "Filter by program"
 // @Success 200 {array} models.Enrollment "Enrollments retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid list query or filter"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /enrollments [get]
 func (h *EnrollmentHandler) GetAll(c *fiber.Ctx) error {
-	ctx := context.Background()
-	query := `SELECT id, student_id, program_id, status, enrolled_at, created_at, updated_at FROM "enrollment" ORDER BY created_at DESC`
-
-	rows, err := h.db.Pool.Query(ctx, query)
+	opts, err := listOptions(c)
 	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to fetch enrollments")
+		return listFailed(c, err, "Failed to fetch enrollments")
 	}
-	defer rows.Close()
 
-	var enrollments []models.Enrollment
-	for rows.Next() {
-		var e models.Enrollment
-		var id, studentID, programID int64
-		err := rows.Scan(&id, &studentID, &programID, &e.Status, &e.EnrolledAt, &e.CreatedAt, &e.UpdatedAt)
-		if err != nil {
-			return utils.InternalServerErrorResponse(c, "Failed to scan enrollment data: "+err.Error())
-		}
-		e.ID = int(id)
-		e.StudentID = int(studentID)
-		e.ProgramID = int(programID)
-		enrollments = append(enrollments, e)
+	filter, invalid := enrollmentFilter(c)
+	if invalid != "" {
+		return utils.BadRequestResponse(c, "Invalid "+invalid+" filter")
 	}
 
-	if enrollments == nil {
-		enrollments = []models.Enrollment{}
+	actor := policy.ActorFrom(c)
+	if !actor.SeesEverything() {
+		filter.TaughtBy = &actor.UserID
 	}
 
-	return utils.SuccessResponse(c, "Enrollments retrieved successfully", enrollments)
+	page, err := h.repos.Enrollments.List(requestContext(c), filter, opts)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch enrollments")
+	}
+
+	return paginated(c, "Enrollments retrieved successfully", page, opts)
+}
+
+// enrollmentFilter reads the status and program_id filters. invalid names
+// the first malformed one.
+func enrollmentFilter(c *fiber.Ctx) (filter repository.EnrollmentFilter, invalid string) {
+	filter.Status = c.Query("status")
+	if filter.Status != "" && !models.IsValidEnrollmentStatus(filter.Status) {
+		return filter, "status"
+	}
+
+	var ok bool
+	if filter.ProgramID, ok = queryIntFilter(c, "program_id"); !ok {
+		return filter, "program_id"
+	}
+	return filter, ""
 }
 
 // GetByStudent godoc
 // @Summary Get enrollments by student
-// @Description Retrieve all enrollments for a specific student
+// @Description Retrieve all enrollments for a specific student. Students can only request their own; lecturers only see enrollments in programs they teach.
 // @Tags Enrollments
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param studentId path int true "Student ID"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number, for offset paging"
+// @Param cursor query string false "next_cursor of the previous page, for cursor paging"
+// @Param sort query string false "created_at (default -created_at), enrolled_at or status; prefix - for descending"
+// @Param status query string false "Filter by status (enrolled, active, completed, dropped)"
+// @Param program_id query int false "Filter by program"
 // @Success 200 {array} models.Enrollment "Student enrollments retrieved"
-// @Failure 400 {object} map[string]interface{} "Invalid student ID"
+// @Failure 400 {object} map[string]interface{} "Invalid student ID, list query or filter"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not your enrollments"
 // @Router /enrollments/student/{studentId} [get]
 func (h *EnrollmentHandler) GetByStudent(c *fiber.Ctx) error {
 	studentID, err := strconv.Atoi(c.Params("studentId"))
@@ -78,61 +103,84 @@ func (h *EnrollmentHandler) GetByStudent(c *fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid student ID")
 	}
 
-	ctx := context.Background()
-	query := `SELECT id, student_id, program_id, status, enrolled_at, created_at, updated_at FROM "enrollment" WHERE student_id = $1 ORDER BY created_at DESC`
+	opts, err := listOptions(c)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch enrollments")
+	}
 
-	rows, err := h.db.Pool.Query(ctx, query, studentID)
+	filter, invalid := enrollmentFilter(c)
+	if invalid != "" {
+		return utils.BadRequestResponse(c, "Invalid "+invalid+" filter")
+	}
+
+	ctx := requestContext(c)
+	actor := policy.ActorFrom(c)
+	if err := h.policy.CanViewStudent(ctx, actor, studentID); err != nil {
+		return denied(c, err, "Student not found")
+	}
+
+	filter.StudentID = &studentID
+	if !actor.SeesEverything() && !actor.IsSelf(studentID) {
+		filter.TaughtBy = &actor.UserID
+	}
+
+	page, err := h.repos.Enrollments.List(ctx, filter, opts)
 	if err != nil {
-		return utils.ErrorResponse(c, fiber.StatusInternalServerError, "Failed to fetch enrollments")
+		return listFailed(c, err, "Failed to fetch enrollments")
 	}
-	defer rows.Close()
 
-	var enrollments []models.Enrollment
-	for rows.Next() {
-		var e models.Enrollment
-		var id, studentID, programID int64
-		err := rows.Scan(&id, &studentID, &programID, &e.Status, &e.EnrolledAt, &e.CreatedAt, &e.UpdatedAt)
-		if err != nil {
-			return utils.InternalServerErrorResponse(c, "Failed to scan enrollment data")
-		}
-		e.ID = int(id)
-		e.StudentID = int(studentID)
-		e.ProgramID = int(programID)
-		enrollments = append(enrollments, e)
+	return paginated(c, "Student enrollments retrieved successfully", page, opts)
+}
+
+func (h *EnrollmentHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid enrollment ID")
 	}
 
-	if enrollments == nil {
-		enrollments = []models.Enrollment{}
+	ctx := requestContext(c)
+	if err := h.policy.CanViewEnrollment(ctx, policy.ActorFrom(c), id); err != nil {
+		return denied(c, err, "Enrollment not found")
 	}
 
-	return utils.SuccessResponse(c, "Student enrollments retrieved successfully", enrollments)
+	enrollment, err := h.repos.Enrollments.Get(ctx, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch enrollment").On(utils.ErrCodeNotFound, "Enrollment not found")
+	}
+
+	c.Set(fiber.HeaderETag, utils.ETag(enrollment.Version))
+	return utils.SuccessResponse(c, "Enrollment retrieved successfully", enrollment)
 }
 
 // Create godoc
 // @Summary Create new enrollment
-// @Description Enroll a student in a program
+// @Description Enroll a student in a program. Students can only enroll themselves and lecturers only into programs they teach.
 // @Tags Enrollments
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body models.CreateEnrollmentRequest true "Enrollment details"
 // @Success 201 {object} map[string]interface{} "Enrollment created successfully"
-// @Failure 400 {object} map[string]interface{} "Invalid request or already enrolled"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not allowed to enroll this student in this program"
+// @Failure 409 {object} map[string]interface{} "Already enrolled, or student or program does not exist"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
 // @Router /enrollments [post]
 func (h *EnrollmentHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateEnrollmentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-	var enrollmentID int
-	query := `INSERT INTO "enrollment" (student_id, program_id) VALUES ($1, $2) RETURNING id`
-
-	err := h.db.Pool.QueryRow(ctx, query, req.StudentID, req.ProgramID).Scan(&enrollmentID)
+	enrollmentID, err := h.enrollments.Create(requestContext(c), policy.ActorFrom(c), req)
+	if isDenied(err) {
+		return denied(c, err, "Program not found")
+	}
 	if err != nil {
-		return utils.ConflictResponse(c, "Failed to create enrollment or already enrolled")
+		return utils.DBError(err, "Failed to create enrollment").
+			On(utils.ErrCodeAlreadyExists, "Student is already enrolled in this program").
+			On(utils.ErrCodeReferenceViolation, "Invalid student or program ID")
 	}
 
 	return utils.CreatedResponse(c, "Enrollment created successfully", fiber.Map{"id": enrollmentID})
@@ -145,23 +193,20 @@ func (h *EnrollmentHandler) UpdateStatus(c *fiber.Ctx) error {
 	}
 
 	var req models.UpdateEnrollmentRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-	query := `UPDATE "enrollment" SET status = $1 WHERE id = $2`
-
-	result, err := h.db.Pool.Exec(ctx, query, req.Status, id)
-	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to update enrollment status")
+	version, err := h.enrollments.UpdateStatus(requestContext(c), policy.ActorFrom(c), id, req.Status)
+	if isDenied(err) {
+		return denied(c, err, "Enrollment not found")
 	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Enrollment not found")
+	if err != nil {
+		return utils.DBError(err, "Failed to update enrollment status").On(utils.ErrCodeNotFound, "Enrollment not found")
 	}
 
-	return utils.SuccessResponse(c, "Enrollment status updated successfully", nil)
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Enrollment status updated successfully", fiber.Map{"version": version})
 }
 
 func (h *EnrollmentHandler) Delete(c *fiber.Ctx) error {
@@ -170,16 +215,14 @@ func (h *EnrollmentHandler) Delete(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid enrollment ID")
 	}
 
-	ctx := context.Background()
-	query := `DELETE FROM "enrollment" WHERE id = $1`
-
-	result, err := h.db.Pool.Exec(ctx, query, id)
-	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to delete enrollment")
+	err = h.enrollments.Delete(requestContext(c), policy.ActorFrom(c), id)
+	if isDenied(err) {
+		return denied(c, err, "Enrollment not found")
 	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Enrollment not found")
+	if err != nil {
+		return utils.DBError(err, "Failed to delete enrollment").
+			On(utils.ErrCodeNotFound, "Enrollment not found").
+			On(utils.ErrCodeReferenceViolation, "Cannot delete enrollment, it has assessments")
 	}
 
 	return utils.SuccessResponse(c, "Enrollment deleted successfully", nil)
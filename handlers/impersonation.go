@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"mbkm-api/models"
+	"mbkm-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Impersonate godoc
+// @Summary Impersonate a user
+// @Description Issue a short-lived, read-only access token that acts as the target user so support staff see exactly what the user sees. The token carries an act claim naming the administrator, ends when the administrator's session ends, and any non-GET request made with it is rejected. Starting an impersonation is recorded in the audit log (admin only).
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body models.ImpersonateRequest true "Reason for the impersonation"
+// @Success 200 {object} models.ImpersonationResponse "Impersonation token issued"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Target cannot be impersonated"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users/{id}/impersonate [post]
+func (h *AuthHandler) Impersonate(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req models.ImpersonateRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	adminID := c.Locals("userID").(int)
+	if id == adminID {
+		return utils.BadRequestResponse(c, "You cannot impersonate yourself")
+	}
+
+	ctx := requestContext(c)
+	user, err := h.repos.Users.Get(ctx, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if !user.IsActive {
+		return utils.BadRequestResponse(c, "Cannot impersonate an inactive user")
+	}
+
+	if user.Role == models.RoleAdmin {
+		return utils.ForbiddenResponse(c, "Administrators cannot be impersonated")
+	}
+
+	ttl := time.Minute * time.Duration(h.cfg.ImpersonationTTL)
+	email, _ := c.Locals("email").(string)
+	token, err := h.tokens.Generate(utils.Claims{
+		UserID:    user.ID,
+		SessionID: c.Locals("sessionID").(int),
+		Email:     user.Email,
+		Role:      user.Role,
+		Act:       &utils.ActorClaim{Subject: strconv.Itoa(adminID), UserID: adminID, Email: email},
+	}, ttl)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	event := fiber.Map{"reason": req.Reason, "expires_at": time.Now().Add(ttl).UTC()}
+	if err := h.repos.Users.RecordImpersonation(ctx, user.ID, event); err != nil {
+		return utils.DBError(err, "Failed to start impersonation")
+	}
+
+	var resp models.ImpersonationResponse
+	resp.Token = token
+	resp.ExpiresIn = int(ttl.Seconds())
+	resp.User.ID, resp.User.Username, resp.User.Email, resp.User.Role = user.ID, user.Username, user.Email, user.Role
+
+	return utils.SuccessResponse(c, "Impersonation token issued", resp)
+}
@@ -1,63 +1,56 @@
 package handlers
 
 import (
-	"context"
-	"mbkm-api/database"
+	"errors"
 	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/service"
 	"mbkm-api/utils"
 	"strconv"
-	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type LecturerHandler struct {
-	db *database.Database
+	repos     *repository.Repositories
+	lecturers *service.LecturerService
 }
 
-func NewLecturerHandler(db *database.Database) *LecturerHandler {
-	return &LecturerHandler{db: db}
+func NewLecturerHandler(repos *repository.Repositories) *LecturerHandler {
+	return &LecturerHandler{repos: repos, lecturers: service.NewLecturerService(repos)}
 }
 
 // GetAll godoc
 // @Summary Get all lecturers
-// @Description Retrieve list of all lecturers
+// @Description Retrieve lecturers one page at a time, optionally of one department
 // @Tags Lecturers
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number, for offset paging"
+// @Param cursor query string false "next_cursor of the previous page, for cursor paging"
+// @Param sort query string false "full_name (default), nidn or created_at; prefix - for descending"
+// @Param department query string false "Filter by department"
 // @Success 200 {array} models.Lecturer "Lecturers retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid list query"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /lecturers [get]
 func (h *LecturerHandler) GetAll(c *fiber.Ctx) error {
-	ctx := context.Background()
-	query := `SELECT id, user_id, nidn, full_name, phone, department, is_active, created_at, updated_at FROM "lecturer" ORDER BY full_name ASC`
-
-	rows, err := h.db.Pool.Query(ctx, query)
+	opts, err := listOptions(c)
 	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to fetch lecturers")
-	}
-	defer rows.Close()
-
-	var lecturers []models.Lecturer
-	for rows.Next() {
-		var l models.Lecturer
-		var id, userID int64
-		err := rows.Scan(&id, &userID, &l.NIDN, &l.FullName, &l.Phone, &l.Department, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
-		if err != nil {
-			return utils.InternalServerErrorResponse(c, "Failed to scan lecturer data: "+err.Error())
-		}
-		l.ID = int(id)
-		l.UserID = int(userID)
-		lecturers = append(lecturers, l)
+		return listFailed(c, err, "Failed to fetch lecturers")
 	}
 
-	if lecturers == nil {
-		lecturers = []models.Lecturer{}
+	filter := repository.LecturerFilter{Department: c.Query("department")}
+	page, err := h.repos.Lecturers.List(requestContext(c), filter, opts)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch lecturers")
 	}
 
-	return utils.SuccessResponse(c, "Lecturers retrieved successfully", lecturers)
+	return paginated(c, "Lecturers retrieved successfully", page, opts)
 }
 
 // GetByID godoc
@@ -68,7 +61,10 @@ func (h *LecturerHandler) GetAll(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Lecturer ID"
+// @Param If-None-Match header string false "ETag from an earlier response; 304 if unchanged"
 // @Success 200 {object} models.Lecturer "Lecturer retrieved successfully"
+// @Header 200 {string} ETag "Version of the record"
+// @Success 304 "Not modified"
 // @Failure 400 {object} map[string]interface{} "Invalid lecturer ID"
 // @Failure 404 {object} map[string]interface{} "Lecturer not found"
 // @Router /lecturers/{id} [get]
@@ -78,18 +74,12 @@ func (h *LecturerHandler) GetByID(c *fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid lecturer ID")
 	}
 
-	ctx := context.Background()
-	var lecturer models.Lecturer
-	query := `SELECT id, user_id, nidn, full_name, phone, department, is_active, created_at, updated_at FROM "lecturer" WHERE id = $1`
-
-	var lid, userID int64
-	err = h.db.Pool.QueryRow(ctx, query, id).Scan(&lid, &userID, &lecturer.NIDN, &lecturer.FullName, &lecturer.Phone, &lecturer.Department, &lecturer.IsActive, &lecturer.CreatedAt, &lecturer.UpdatedAt)
+	lecturer, err := h.repos.Lecturers.Get(requestContext(c), id)
 	if err != nil {
-		return utils.NotFoundResponse(c, "Lecturer not found")
+		return utils.DBError(err, "Failed to fetch lecturer").On(utils.ErrCodeNotFound, "Lecturer not found")
 	}
-	lecturer.ID = int(lid)
-	lecturer.UserID = int(userID)
 
+	c.Set(fiber.HeaderETag, utils.ETag(lecturer.Version))
 	return utils.SuccessResponse(c, "Lecturer retrieved successfully", lecturer)
 }
 
@@ -104,55 +94,47 @@ func (h *LecturerHandler) GetByID(c *fiber.Ctx) error {
 // @Success 201 {object} map[string]interface{} "Lecturer created successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
-// @Failure 409 {object} map[string]interface{} "NIDN already exists"
+// @Failure 409 {object} map[string]interface{} "NIDN already exists or user does not exist"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
 // @Router /lecturers [post]
 func (h *LecturerHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateLecturerRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.BadRequestResponse(c, "Invalid request body")
-	}
-
-	// Validate required fields
-	if req.NIDN == "" || req.FullName == "" {
-		return utils.BadRequestResponse(c, "NIDN and full name are required")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-
-	// Check if user exists and is a lecturer
-	var userExists bool
-	err := h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "user" WHERE id = $1 AND role = 'lecturer')`, req.UserID).Scan(&userExists)
-	if err != nil || !userExists {
+	lecturerID, err := h.lecturers.Create(requestContext(c), req)
+	if errors.Is(err, service.ErrNotLecturerUser) {
 		return utils.BadRequestResponse(c, "Invalid user ID or user is not a lecturer")
 	}
-
-	var lecturerID int64
-	query := `INSERT INTO "lecturer" (user_id, nidn, full_name, phone, department, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
-
-	err = h.db.Pool.QueryRow(ctx, query, req.UserID, req.NIDN, req.FullName, req.Phone, req.Department).Scan(&lecturerID)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			return utils.ConflictResponse(c, "NIDN or User ID already exists")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to create lecturer")
+		return utils.DBError(err, "Failed to create lecturer").
+			On(utils.ErrCodeAlreadyExists, "NIDN or User ID already exists").
+			On(utils.ErrCodeReferenceViolation, "Invalid user ID or user is not a lecturer")
 	}
 
-	return utils.CreatedResponse(c, "Lecturer created successfully", fiber.Map{"id": int(lecturerID)})
+	return utils.CreatedResponse(c, "Lecturer created successfully", fiber.Map{"id": lecturerID})
 }
 
 // Update godoc
 // @Summary Update lecturer
-// @Description Update an existing lecturer (admin only)
+// @Description Replace every field of a lecturer (admin only). is_active is kept when omitted; use PATCH to change single fields.
 // @Tags Lecturers
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Lecturer ID"
 // @Param request body models.UpdateLecturerRequest true "Updated lecturer details"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} map[string]interface{} "Lecturer updated successfully"
+// @Header 200 {string} ETag "New version of the record"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this lecturer"
 // @Failure 404 {object} map[string]interface{} "Lecturer not found"
 // @Failure 409 {object} map[string]interface{} "NIDN already exists"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
 // @Router /lecturers/{id} [put]
 func (h *LecturerHandler) Update(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -161,34 +143,68 @@ func (h *LecturerHandler) Update(c *fiber.Ctx) error {
 	}
 
 	var req models.UpdateLecturerRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.BadRequestResponse(c, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-
-	// Check if lecturer exists
-	var exists bool
-	err = h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "lecturer" WHERE id = $1)`, id).Scan(&exists)
-	if err != nil || !exists {
-		return utils.NotFoundResponse(c, "Lecturer not found")
+	version, err := h.lecturers.Update(requestContext(c), policy.ActorFrom(c), id, req)
+	if isDenied(err) {
+		return denied(c, err, "Lecturer not found")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to update lecturer").
+			On(utils.ErrCodeNotFound, "Lecturer not found").
+			On(utils.ErrCodeAlreadyExists, "NIDN already exists")
 	}
 
-	query := `UPDATE "lecturer" SET nidn = $1, full_name = $2, phone = $3, department = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5`
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Lecturer updated successfully", fiber.Map{"version": version})
+}
 
-	result, err := h.db.Pool.Exec(ctx, query, req.NIDN, req.FullName, req.Phone, req.Department, id)
+// Patch godoc
+// @Summary Patch lecturer
+// @Description Change only the fields present in a JSON merge patch (admin only). null clears phone and department; nidn, full_name and is_active cannot be null.
+// @Tags Lecturers
+// @Accept application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Lecturer ID"
+// @Param request body models.LecturerPatch true "Fields to change"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
+// @Success 200 {object} map[string]interface{} "Lecturer updated successfully"
+// @Header 200 {string} ETag "New version of the record"
+// @Failure 400 {object} map[string]interface{} "Invalid lecturer ID, body or unknown field"
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this lecturer"
+// @Failure 404 {object} map[string]interface{} "Lecturer not found"
+// @Failure 409 {object} map[string]interface{} "NIDN already exists"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 415 {object} map[string]interface{} "Body is not a merge patch"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
+// @Router /lecturers/{id} [patch]
+func (h *LecturerHandler) Patch(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			return utils.ConflictResponse(c, "NIDN already exists")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to update lecturer")
+		return utils.BadRequestResponse(c, "Invalid lecturer ID")
+	}
+
+	var patch models.LecturerPatch
+	if err := parseMergePatch(c, &patch); err != nil {
+		return err
 	}
 
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Lecturer not found")
+	version, err := h.lecturers.Patch(requestContext(c), policy.ActorFrom(c), id, patch)
+	if isDenied(err) {
+		return denied(c, err, "Lecturer not found")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to update lecturer").
+			On(utils.ErrCodeNotFound, "Lecturer not found").
+			On(utils.ErrCodeAlreadyExists, "NIDN already exists")
 	}
 
-	return utils.SuccessResponse(c, "Lecturer updated successfully", nil)
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Lecturer updated successfully", fiber.Map{"version": version})
 }
 
 // Delete godoc
@@ -199,10 +215,14 @@ func (h *LecturerHandler) Update(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Lecturer ID"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} map[string]interface{} "Lecturer deleted successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid lecturer ID"
+// @Failure 403 {object} map[string]interface{} "Not allowed to manage this lecturer"
 // @Failure 404 {object} map[string]interface{} "Lecturer not found"
-// @Failure 409 {object} map[string]interface{} "Cannot delete, lecturer has related data"
+// @Failure 409 {object} map[string]interface{} "Cannot delete, lecturer still teaches programs"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
 // @Router /lecturers/{id} [delete]
 func (h *LecturerHandler) Delete(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -210,27 +230,14 @@ func (h *LecturerHandler) Delete(c *fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid lecturer ID")
 	}
 
-	ctx := context.Background()
-
-	// Check if lecturer exists
-	var exists bool
-	err = h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "lecturer" WHERE id = $1)`, id).Scan(&exists)
-	if err != nil || !exists {
-		return utils.NotFoundResponse(c, "Lecturer not found")
+	err = h.lecturers.Delete(requestContext(c), policy.ActorFrom(c), id)
+	if isDenied(err) {
+		return denied(c, err, "Lecturer not found")
 	}
-
-	query := `DELETE FROM "lecturer" WHERE id = $1`
-
-	result, err := h.db.Pool.Exec(ctx, query, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "foreign key") {
-			return utils.ConflictResponse(c, "Cannot delete lecturer, has related programs or data")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to delete lecturer")
-	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Lecturer not found")
+		return utils.DBError(err, "Failed to delete lecturer").
+			On(utils.ErrCodeNotFound, "Lecturer not found").
+			On(utils.ErrCodeReferenceViolation, "Cannot delete lecturer, it still teaches programs")
 	}
 
 	return utils.SuccessResponse(c, "Lecturer deleted successfully", nil)
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listOptions reads limit, page, cursor and sort from the query string.
+// Sort fields are checked by the repository.
+func listOptions(c *fiber.Ctx) (repository.ListOptions, error) {
+	opts := repository.ListOptions{
+		Limit:  c.QueryInt("limit", repository.DefaultLimit),
+		Page:   c.QueryInt("page", 0),
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+	}
+	if opts.Limit < 1 || opts.Limit > repository.MaxLimit {
+		opts.Limit = repository.DefaultLimit
+	}
+	if opts.Page != 0 && opts.Cursor != "" {
+		return opts, &repository.ListOptionsError{Detail: "page and cursor cannot be combined"}
+	}
+	return opts, nil
+}
+
+// paginated answers with one page of a list and its pagination metadata.
+func paginated[T any](c *fiber.Ctx, message string, page repository.Page[T], opts repository.ListOptions) error {
+	p := utils.Pagination{Limit: opts.Limit, Total: page.Total, NextCursor: page.NextCursor, HasMore: page.NextCursor != ""}
+	if opts.Cursor == "" {
+		p.Page = max(opts.Page, 1)
+	}
+	return utils.PaginatedResponse(c, message, page.Items, p)
+}
+
+// listFailed answers 400 for unusable list options and otherwise treats err
+// as a database error.
+func listFailed(c *fiber.Ctx, err error, fallback string) error {
+	var optErr *repository.ListOptionsError
+	if errors.As(err, &optErr) {
+		return utils.BadRequestResponse(c, "Invalid list query: "+optErr.Detail)
+	}
+	return utils.DBError(err, fallback)
+}
+
+// queryIntFilter parses an optional integer filter; nil when absent and
+// ok false when malformed.
+func queryIntFilter(c *fiber.Ctx, name string) (value *int, ok bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, false
+	}
+	return &n, true
+}
+
+// queryBoolFilter parses an optional boolean filter like queryIntFilter.
+func queryBoolFilter(c *fiber.Ctx, name string) (value *bool, ok bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, false
+	}
+	return &b, true
+}
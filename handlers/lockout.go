@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LockoutHandler struct {
+	repos *repository.Repositories
+}
+
+func NewLockoutHandler(repos *repository.Repositories) *LockoutHandler {
+	return &LockoutHandler{repos: repos}
+}
+
+// GetAll godoc
+// @Summary List login lockouts
+// @Description Retrieve failed-login counters per email and IP. By default only entries that are currently locked are returned (admin only).
+// @Tags Lockouts
+// @Produce json
+// @Security BearerAuth
+// @Param all query bool false "Include counters that are not locked"
+// @Success 200 {array} models.LoginThrottle "Lockouts retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /lockouts [get]
+func (h *LockoutHandler) GetAll(c *fiber.Ctx) error {
+	lockouts, err := h.repos.LoginThrottles.List(c.UserContext(), !c.QueryBool("all", false))
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch lockouts")
+	}
+
+	return utils.SuccessResponse(c, "Lockouts retrieved successfully", lockouts)
+}
+
+// Delete godoc
+// @Summary Clear a lockout
+// @Description Reset the failed-login counter and lift the lockout of an email or IP (admin only)
+// @Tags Lockouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Lockout ID"
+// @Success 200 {object} map[string]interface{} "Lockout cleared successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid lockout ID"
+// @Failure 404 {object} map[string]interface{} "Lockout not found"
+// @Router /lockouts/{id} [delete]
+func (h *LockoutHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid lockout ID")
+	}
+
+	if err := h.repos.LoginThrottles.Delete(c.UserContext(), id); err != nil {
+		return utils.DBError(err, "Failed to clear lockout").On(utils.ErrCodeNotFound, "Lockout not found")
+	}
+
+	return utils.SuccessResponse(c, "Lockout cleared successfully", nil)
+}
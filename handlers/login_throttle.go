@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"mbkm-api/config"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+	"strings"
+	"time"
+)
+
+// loginThrottle implements per-account and per-IP failed login tracking with
+// exponential backoff once a threshold is crossed.
+type loginThrottle struct {
+	repos *repository.Repositories
+	cfg   *config.Config
+}
+
+func newLoginThrottle(repos *repository.Repositories, cfg *config.Config) *loginThrottle {
+	return &loginThrottle{repos: repos, cfg: cfg}
+}
+
+// lockedFor returns how long the email or IP is still locked out, or zero.
+func (t *loginThrottle) lockedFor(ctx context.Context, email, ip string) (time.Duration, error) {
+	lockedUntil, err := t.repos.LoginThrottles.LockedUntil(ctx, normalizeEmail(email), ip)
+	if err != nil || lockedUntil == nil {
+		return 0, err
+	}
+	return time.Until(*lockedUntil), nil
+}
+
+// recordFailure counts a failed attempt against both the email and the IP.
+func (t *loginThrottle) recordFailure(ctx context.Context, email, ip string) error {
+	if err := t.bump(ctx, models.ThrottleKindEmail, normalizeEmail(email), t.cfg.LoginMaxAttempts); err != nil {
+		return err
+	}
+	return t.bump(ctx, models.ThrottleKindIP, ip, t.cfg.LoginIPMaxAttempts)
+}
+
+// recordSuccess clears the account counter. The IP counter is left alone so a
+// single valid account cannot be used to reset an attacker's budget.
+func (t *loginThrottle) recordSuccess(ctx context.Context, email string) error {
+	return t.repos.LoginThrottles.Clear(ctx, models.ThrottleKindEmail, normalizeEmail(email))
+}
+
+func (t *loginThrottle) bump(ctx context.Context, kind, value string, threshold int) error {
+	window := time.Now().Add(-time.Duration(t.cfg.LoginAttemptWindow) * time.Second)
+
+	// Counters restart once the last failure is older than the window.
+	attempts, err := t.repos.LoginThrottles.Fail(ctx, kind, value, window)
+	if err != nil {
+		return err
+	}
+
+	if attempts < threshold {
+		return nil
+	}
+
+	return t.repos.LoginThrottles.Lock(ctx, kind, value, time.Now().Add(t.backoff(attempts-threshold)))
+}
+
+// backoff doubles the lockout for every failure past the threshold, capped at
+// LoginLockoutMax.
+func (t *loginThrottle) backoff(over int) time.Duration {
+	base := time.Duration(t.cfg.LoginLockoutBase) * time.Second
+	max := time.Duration(t.cfg.LoginLockoutMax) * time.Second
+
+	d := base
+	for i := 0; i < over && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
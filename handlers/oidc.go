@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mbkm-api/models"
+	"mbkm-api/oidc"
+	"mbkm-api/utils"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errOIDCNotProvisioned   = errors.New("no local account for this identity")
+	errOIDCIdentityConflict = errors.New("account is linked to another identity")
+	errOIDCEmailUnverified  = errors.New("email is not verified by the identity provider")
+	errOIDCEmailMissing     = errors.New("identity provider did not return an email address")
+)
+
+var usernameDisallowedChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+const oidcUsernameAttempts = 3
+
+// OIDCLogin godoc
+// @Summary Start single sign-on login
+// @Description Redirect the browser to the university identity provider (OpenID Connect authorization code flow with PKCE)
+// @Tags Authentication
+// @Success 302 "Redirect to the identity provider"
+// @Failure 502 {object} map[string]interface{} "Identity provider unavailable"
+// @Router /auth/oidc/login [get]
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	state, err1 := utils.GenerateRandomToken(32)
+	nonce, err2 := utils.GenerateRandomToken(32)
+	verifier, err3 := utils.GenerateRandomToken(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to start login")
+	}
+
+	ctx := c.UserContext()
+	err := h.repos.OIDCRequests.Create(ctx, models.OIDCAuthRequest{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		IPAddress:    c.IP(),
+		ExpiresAt:    time.Now().Add(time.Second * time.Duration(h.cfg.OIDCAuthRequestTimeout)),
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to start login")
+	}
+
+	authURL, err := h.sso.AuthCodeURL(ctx, state, nonce, oidc.PKCEChallenge(verifier))
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		return utils.ErrorResponse(c, fiber.StatusBadGateway, "Identity provider is unavailable")
+	}
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback godoc
+// @Summary Finish single sign-on login
+// @Description Redirect target of the identity provider. Verifies the ID token, links or provisions the local account and returns a token pair (or an MFA challenge). With OIDC_POST_LOGIN_REDIRECT set the result is passed to the frontend in the URL fragment instead.
+// @Tags Authentication
+// @Produce json
+// @Param state query string true "State issued by /auth/oidc/login"
+// @Param code query string true "Authorization code"
+// @Success 200 {object} models.LoginResponse "Login successful"
+// @Failure 400 {object} map[string]interface{} "Invalid or expired login request"
+// @Failure 401 {object} map[string]interface{} "Identity provider rejected the login"
+// @Failure 403 {object} map[string]interface{} "No account or account inactive"
+// @Failure 409 {object} map[string]interface{} "Email belongs to an account linked to another identity"
+// @Router /auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	if idpErr := c.Query("error"); idpErr != "" {
+		return utils.UnauthorizedResponse(c, "Identity provider rejected the login: "+idpErr)
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		return utils.BadRequestResponse(c, "State and code are required")
+	}
+
+	ctx := c.UserContext()
+
+	// Taking the request makes the state single-use.
+	request, err := h.repos.OIDCRequests.Take(ctx, utils.HashToken(state))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.BadRequestResponse(c, "Invalid or expired login request")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to sign in")
+	}
+
+	rawIDToken, err := h.sso.Exchange(ctx, code, request.CodeVerifier)
+	if err != nil {
+		log.Printf("OIDC: %v", err)
+		return utils.UnauthorizedResponse(c, "Failed to exchange authorization code")
+	}
+
+	claims, err := h.sso.VerifyIDToken(ctx, rawIDToken, request.Nonce)
+	if err != nil {
+		log.Printf("OIDC: invalid id token: %v", err)
+		return utils.UnauthorizedResponse(c, "Invalid ID token")
+	}
+
+	issuer, err := h.sso.Issuer(ctx)
+	if err != nil {
+		return utils.ErrorResponse(c, fiber.StatusBadGateway, "Identity provider is unavailable")
+	}
+
+	user, err := h.resolveOIDCUser(ctx, issuer, claims)
+	switch {
+	case errors.Is(err, errOIDCNotProvisioned):
+		return utils.ForbiddenResponse(c, "No account exists for this identity, please contact an administrator")
+	case errors.Is(err, errOIDCIdentityConflict):
+		return utils.ConflictResponse(c, "This email is already linked to another identity")
+	case errors.Is(err, errOIDCEmailUnverified), errors.Is(err, errOIDCEmailMissing):
+		return utils.ForbiddenResponse(c, "Cannot sign in: "+err.Error())
+	case err != nil:
+		log.Printf("OIDC: failed to resolve user: %v", err)
+		return utils.DBError(err, "Failed to sign in")
+	}
+
+	if !user.IsActive {
+		return utils.ForbiddenResponse(c, "Account is inactive")
+	}
+
+	// Local 2FA still applies on top of the IdP login.
+	if user.TOTPEnabled {
+		challenge, err := h.mfaChallenge(user)
+		if err != nil {
+			return utils.InternalServerErrorResponse(c, "Failed to generate token")
+		}
+
+		if h.cfg.OIDCPostLoginRedirect != "" {
+			return h.oidcRedirect(c, url.Values{
+				"mfa_required": {"true"},
+				"mfa_token":    {challenge.MFAToken},
+				"expires_in":   {strconv.Itoa(challenge.ExpiresIn)},
+			})
+		}
+		return utils.SuccessResponse(c, "Two-factor authentication required", challenge)
+	}
+
+	tokens, err := h.createSession(ctx, c, user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	if h.cfg.OIDCPostLoginRedirect != "" {
+		return h.oidcRedirect(c, url.Values{
+			"token":         {tokens.Token},
+			"refresh_token": {tokens.RefreshToken},
+			"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+		})
+	}
+
+	return utils.SuccessResponse(c, "Login successful", models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	})
+}
+
+// oidcRedirect hands the login result to the frontend in the URL fragment,
+// which browsers never send to servers or put in Referer headers.
+func (h *AuthHandler) oidcRedirect(c *fiber.Ctx, values url.Values) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect(h.cfg.OIDCPostLoginRedirect+"#"+values.Encode(), fiber.StatusFound)
+}
+
+// resolveOIDCUser finds the account for an IdP identity. Accounts already
+// linked to (issuer, sub) win; otherwise an account with the same verified
+// email is linked, and as a last resort a new one is provisioned.
+func (h *AuthHandler) resolveOIDCUser(ctx context.Context, issuer string, claims oidc.Claims) (models.User, error) {
+	subject := claims.String("sub")
+	email := strings.ToLower(strings.TrimSpace(claims.String("email")))
+	mappedRole, mapped := h.cfg.OIDCRoleFor(claims.Strings(h.cfg.OIDCRoleClaim))
+
+	var user models.User
+	err := h.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = h.repos.Users.GetByOIDC(ctx, issuer, subject)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			if email == "" {
+				return errOIDCEmailMissing
+			}
+
+			user, err = h.repos.Users.GetByEmail(ctx, email)
+
+			switch {
+			case err == nil:
+				// Linking by email is only safe when the IdP vouches for it,
+				// otherwise anyone could claim an existing account.
+				if !claims.Bool("email_verified") {
+					return errOIDCEmailUnverified
+				}
+				if user.OIDCSubject != nil {
+					return errOIDCIdentityConflict
+				}
+
+				if err := h.repos.Users.LinkOIDC(ctx, user.ID, issuer, subject); err != nil {
+					return err
+				}
+				log.Printf("OIDC: linked user %d to %s", user.ID, subject)
+			case errors.Is(err, pgx.ErrNoRows):
+				if !h.cfg.OIDCAutoProvision {
+					return errOIDCNotProvisioned
+				}
+
+				role := h.cfg.OIDCDefaultRole
+				if mapped {
+					role = mappedRole
+				}
+				if user, err = h.provisionOIDCUser(ctx, issuer, subject, email, role, claims); err != nil {
+					return err
+				}
+				log.Printf("OIDC: provisioned user %d (%s) for %s", user.ID, user.Role, subject)
+			default:
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if h.cfg.OIDCSyncRole && mapped && user.Role != mappedRole {
+			if err := h.repos.Users.SetRole(ctx, user.ID, mappedRole); err != nil {
+				return err
+			}
+			log.Printf("OIDC: role of user %d changed from %s to %s", user.ID, user.Role, mappedRole)
+			user.Role = mappedRole
+		}
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// provisionOIDCUser creates an account for a first-time SSO user. The
+// password is random and never revealed; the user can still set one through
+// the forgot-password flow.
+func (h *AuthHandler) provisionOIDCUser(ctx context.Context, issuer, subject, email, role string, claims oidc.Claims) (models.User, error) {
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return models.User{}, err
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	base := claims.String("preferred_username")
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = truncate(usernameDisallowedChars.ReplaceAllString(base, ""), 90)
+	if base == "" {
+		base = "user"
+	}
+
+	user := models.User{
+		Email:        email,
+		PasswordHash: hashedPassword,
+		FullName:     truncate(claims.String("name"), 100),
+		Role:         role,
+		OIDCIssuer:   &issuer,
+		OIDCSubject:  &subject,
+	}
+
+	// Usernames from the IdP may already be taken locally; retry with a
+	// random suffix instead of failing the login.
+	user.Username = base
+	for i := 0; i < oidcUsernameAttempts; i++ {
+		user.ID, err = h.repos.Users.Create(ctx, user)
+		if err == nil {
+			user.IsActive = true
+			return user, nil
+		}
+		if !utils.IsConstraintViolation(err, "idx_user_username") {
+			return models.User{}, err
+		}
+
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return models.User{}, err
+		}
+		user.Username = base + "-" + strings.ToLower(usernameDisallowedChars.ReplaceAllString(suffix, ""))
+	}
+
+	return models.User{}, errors.New("could not find a free username")
+}
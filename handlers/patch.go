@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+
+	"mbkm-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// mergePatchType is the media type of JSON merge patches (RFC 7396).
+// application/json is accepted too.
+const mergePatchType = "application/merge-patch+json"
+
+// parseMergePatch decodes a merge patch body into dst and validates the
+// members it sets. The patch must be a JSON object naming only members dst
+// knows.
+func parseMergePatch(c *fiber.Ctx, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(string(c.Request().Header.ContentType()))
+	if mediaType != mergePatchType && mediaType != fiber.MIMEApplicationJSON {
+		return utils.NewAppError(fiber.StatusUnsupportedMediaType, utils.ErrCodeBadRequest, "Content-Type must be "+mergePatchType)
+	}
+
+	body := bytes.TrimSpace(c.Body())
+	if len(body) == 0 || body[0] != '{' {
+		return utils.NewAppError(fiber.StatusBadRequest, utils.ErrCodeBadRequest, "Patch must be a JSON object")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		if field, unknown := strings.CutPrefix(err.Error(), "json: unknown field "); unknown {
+			return utils.NewAppError(fiber.StatusBadRequest, utils.ErrCodeBadRequest, "Unknown field "+field)
+		}
+		return utils.NewAppError(fiber.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid request body")
+	}
+	return validate(dst)
+}
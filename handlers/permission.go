@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PermissionHandler struct {
+	repos *repository.Repositories
+	perms *policy.Resolver
+}
+
+func NewPermissionHandler(repos *repository.Repositories, perms *policy.Resolver) *PermissionHandler {
+	return &PermissionHandler{repos: repos, perms: perms}
+}
+
+// GetPermissions godoc
+// @Summary List permissions
+// @Description Retrieve every permission that can be granted to a role
+// @Tags Permissions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Permission "Permissions retrieved successfully"
+// @Failure 403 {object} map[string]interface{} "Access denied"
+// @Router /permissions [get]
+func (h *PermissionHandler) GetPermissions(c *fiber.Ctx) error {
+	permissions, err := h.repos.Permissions.List(c.UserContext())
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch permissions")
+	}
+
+	return utils.SuccessResponse(c, "Permissions retrieved successfully", permissions)
+}
+
+// GetRoles godoc
+// @Summary List roles with their permissions
+// @Description Retrieve the role to permission mapping for every assignable role
+// @Tags Permissions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.RolePermissionsResponse "Roles retrieved successfully"
+// @Failure 403 {object} map[string]interface{} "Access denied"
+// @Router /roles [get]
+func (h *PermissionHandler) GetRoles(c *fiber.Ctx) error {
+	granted, err := h.repos.Principals.RolePermissions(c.UserContext())
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch roles")
+	}
+
+	roles := make([]models.RolePermissionsResponse, 0, len(models.ValidRoles))
+	for _, role := range models.ValidRoles {
+		perms := append([]string{}, granted[role]...)
+		sort.Strings(perms)
+		roles = append(roles, models.RolePermissionsResponse{Role: role, Permissions: perms})
+	}
+
+	return utils.SuccessResponse(c, "Roles retrieved successfully", roles)
+}
+
+// UpdateRolePermissions godoc
+// @Summary Replace the permissions of a role
+// @Description Set the exact list of permissions granted to a role. The admin role must keep permission.manage so the mapping stays editable.
+// @Tags Permissions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role path string true "Role"
+// @Param request body models.UpdateRolePermissionsRequest true "Permissions"
+// @Success 200 {object} models.RolePermissionsResponse "Role permissions updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid role or permission"
+// @Failure 403 {object} map[string]interface{} "Access denied"
+// @Router /roles/{role}/permissions [put]
+func (h *PermissionHandler) UpdateRolePermissions(c *fiber.Ctx) error {
+	role := c.Params("role")
+	if !models.IsValidRole(role) {
+		return utils.BadRequestResponse(c, "Invalid role")
+	}
+
+	var req models.UpdateRolePermissionsRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, p := range req.Permissions {
+		if !models.IsValidPermission(p) {
+			return utils.BadRequestResponse(c, "Invalid permission: "+p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	sort.Strings(permissions)
+
+	// Guard against locking every administrator out of this endpoint.
+	if role == models.RoleAdmin && !seen[models.PermPermissionManage] {
+		return utils.BadRequestResponse(c, "The admin role must keep "+models.PermPermissionManage)
+	}
+
+	if err := h.repos.Permissions.SetRole(c.UserContext(), role, permissions); err != nil {
+		return utils.DBError(err, "Failed to update role permissions")
+	}
+
+	h.perms.Invalidate()
+
+	return utils.SuccessResponse(c, "Role permissions updated successfully", models.RolePermissionsResponse{Role: role, Permissions: permissions})
+}
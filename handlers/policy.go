@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"errors"
+	"mbkm-api/policy"
+	"mbkm-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// denied turns a policy decision into the standard response so every
+// ownership failure looks the same to clients.
+func denied(c *fiber.Ctx, err error, notFoundMessage string) error {
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		return utils.ForbiddenResponse(c, "You do not have access to this resource")
+	case errors.Is(err, policy.ErrNotFound):
+		return utils.NotFoundResponse(c, notFoundMessage)
+	}
+	return utils.DBError(err, "Failed to check access")
+}
+
+// isDenied reports whether err is a policy decision rather than a failure.
+func isDenied(err error) bool {
+	return errors.Is(err, policy.ErrForbidden) || errors.Is(err, policy.ErrNotFound)
+}
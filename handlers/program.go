@@ -1,9 +1,11 @@
 package handlers
 
 import (
-	"context"
-	"mbkm-api/database"
+	"errors"
 	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/service"
 	"mbkm-api/utils"
 	"strconv"
 	"strings"
@@ -12,54 +14,112 @@ import (
 )
 
 type ProgramHandler struct {
-	db *database.Database
+	repos    *repository.Repositories
+	programs *service.ProgramService
 }
 
-func NewProgramHandler(db *database.Database) *ProgramHandler {
-	return &ProgramHandler{db: db}
+func NewProgramHandler(repos *repository.Repositories) *ProgramHandler {
+	return &ProgramHandler{repos: repos, programs: service.NewProgramService(repos)}
 }
 
 // GetAll godoc
 // @Summary Get all programs
-// @Description Retrieve list of all MBKM programs
+// @Description Retrieve MBKM programs one page at a time with optional filters
 // @Tags Programs
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number, for offset paging"
+// @Param cursor query string false "next_cursor of the previous page, for cursor paging"
+// @Param sort query string false "created_at (default -created_at), code, name, semester or credits; prefix - for descending"
+// @Param semester query int false "Filter by semester"
+// @Param is_active query bool false "Filter by active flag"
+// @Param lecturer_id query int false "Filter by lecturer"
 // @Success 200 {array} models.Program "Programs retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid list query or filter"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /programs [get]
 func (h *ProgramHandler) GetAll(c *fiber.Ctx) error {
-	ctx := context.Background()
-	query := `SELECT id, code, name, description, credits, semester, lecturer_id, is_active, created_at, updated_at FROM "program" ORDER BY created_at DESC`
+	opts, err := listOptions(c)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch programs")
+	}
+
+	filter, invalid := programFilter(c)
+	if invalid != "" {
+		return utils.BadRequestResponse(c, "Invalid "+invalid+" filter")
+	}
+
+	page, err := h.repos.Programs.List(requestContext(c), filter, opts)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch programs")
+	}
+
+	return paginated(c, "Programs retrieved successfully", page, opts)
+}
+
+// programFilter reads the list filters, naming the first malformed one.
+func programFilter(c *fiber.Ctx) (filter repository.ProgramFilter, invalid string) {
+	var ok bool
+	if filter.Semester, ok = queryIntFilter(c, "semester"); !ok {
+		return filter, "semester"
+	}
+	if filter.IsActive, ok = queryBoolFilter(c, "is_active"); !ok {
+		return filter, "is_active"
+	}
+	if filter.LecturerID, ok = queryIntFilter(c, "lecturer_id"); !ok {
+		return filter, "lecturer_id"
+	}
+	return filter, ""
+}
 
-	rows, err := h.db.Pool.Query(ctx, query)
+// Search godoc
+// @Summary Search programs
+// @Description Full-text search over program code, name and description with Indonesian stemming, best match first. Highlights wrap matched terms in <mark>; facets count all matches by semester and credits.
+// @Tags Programs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Keywords; supports 'quoted phrases', or, and -excluded words"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number"
+// @Param semester query int false "Filter by semester"
+// @Param is_active query bool false "Filter by active flag"
+// @Param lecturer_id query int false "Filter by lecturer"
+// @Success 200 {object} models.ProgramSearchResult "Programs found"
+// @Failure 400 {object} map[string]interface{} "Missing query, invalid list query or filter"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /programs/search [get]
+func (h *ProgramHandler) Search(c *fiber.Ctx) error {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		return utils.BadRequestResponse(c, "Search query q is required")
+	}
+
+	opts, err := listOptions(c)
 	if err != nil {
-		return utils.InternalServerErrorResponse(c, "Failed to fetch programs")
+		return listFailed(c, err, "Failed to search programs")
 	}
-	defer rows.Close()
 
-	var programs []models.Program
-	for rows.Next() {
-		var p models.Program
-		var id, credits, semester, lecturerID int64
-		err := rows.Scan(&id, &p.Code, &p.Name, &p.Description, &credits, &semester, &lecturerID, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
-		if err != nil {
-			return utils.InternalServerErrorResponse(c, "Failed to scan program data: "+err.Error())
-		}
-		p.ID = int(id)
-		p.Credits = int(credits)
-		p.Semester = int(semester)
-		p.LecturerID = int(lecturerID)
-		programs = append(programs, p)
+	filter, invalid := programFilter(c)
+	if invalid != "" {
+		return utils.BadRequestResponse(c, "Invalid "+invalid+" filter")
 	}
 
-	if programs == nil {
-		programs = []models.Program{}
+	result, err := h.repos.Programs.Search(requestContext(c), q, filter, opts)
+	if err != nil {
+		return listFailed(c, err, "Failed to search programs")
 	}
 
-	return utils.SuccessResponse(c, "Programs retrieved successfully", programs)
+	page := max(opts.Page, 1)
+	return utils.PaginatedResponse(c, "Programs found", models.ProgramSearchResult{Hits: result.Items, Facets: result.Facets}, utils.Pagination{
+		Limit:   opts.Limit,
+		Total:   result.Total,
+		Page:    page,
+		HasMore: page*opts.Limit < result.Total,
+	})
 }
 
 // GetByID godoc
@@ -70,7 +130,10 @@ func (h *ProgramHandler) GetAll(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Program ID"
+// @Param If-None-Match header string false "ETag from an earlier response; 304 if unchanged"
 // @Success 200 {object} models.Program "Program retrieved successfully"
+// @Header 200 {string} ETag "Version of the record"
+// @Success 304 "Not modified"
 // @Failure 400 {object} map[string]interface{} "Invalid program ID"
 // @Failure 404 {object} map[string]interface{} "Program not found"
 // @Router /programs/{id} [get]
@@ -80,26 +143,18 @@ func (h *ProgramHandler) GetByID(c *fiber.Ctx) error {
 		return utils.ErrorResponse(c, fiber.StatusBadRequest, "Invalid program ID")
 	}
 
-	ctx := context.Background()
-	var program models.Program
-	query := `SELECT id, code, name, description, credits, semester, lecturer_id, is_active, created_at, updated_at FROM "program" WHERE id = $1`
-
-	var pid, credits, semester, lecturerID int64
-	err = h.db.Pool.QueryRow(ctx, query, id).Scan(&pid, &program.Code, &program.Name, &program.Description, &credits, &semester, &lecturerID, &program.IsActive, &program.CreatedAt, &program.UpdatedAt)
+	program, err := h.repos.Programs.Get(requestContext(c), id)
 	if err != nil {
-		return utils.NotFoundResponse(c, "Program not found")
+		return utils.DBError(err, "Failed to fetch program").On(utils.ErrCodeNotFound, "Program not found")
 	}
-	program.ID = int(pid)
-	program.Credits = int(credits)
-	program.Semester = int(semester)
-	program.LecturerID = int(lecturerID)
 
+	c.Set(fiber.HeaderETag, utils.ETag(program.Version))
 	return utils.SuccessResponse(c, "Program retrieved successfully", program)
 }
 
 // Create godoc
 // @Summary Create new program
-// @Description Create a new MBKM program (admin/lecturer only)
+// @Description Create a new MBKM program (admin/lecturer only). Lecturers can only create programs they teach themselves.
 // @Tags Programs
 // @Accept json
 // @Produce json
@@ -108,53 +163,51 @@ func (h *ProgramHandler) GetByID(c *fiber.Ctx) error {
 // @Success 201 {object} map[string]interface{} "Program created successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Lecturer assigned is not the caller"
+// @Failure 409 {object} map[string]interface{} "Program code already exists or lecturer does not exist"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
 // @Router /programs [post]
 func (h *ProgramHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateProgramRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.BadRequestResponse(c, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Validate required fields
-	if req.Code == "" || req.Name == "" {
-		return utils.BadRequestResponse(c, "Code and name are required")
-	}
-
-	ctx := context.Background()
-
-	// Check if lecturer exists
-	var lecturerExists bool
-	err := h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "lecturer" WHERE id = $1 AND is_active = true)`, req.LecturerID).Scan(&lecturerExists)
-	if err != nil || !lecturerExists {
+	programID, err := h.programs.Create(requestContext(c), policy.ActorFrom(c), req)
+	if errors.Is(err, service.ErrInvalidLecturer) {
 		return utils.BadRequestResponse(c, "Invalid lecturer ID")
 	}
-
-	var programID int64
-	query := `INSERT INTO "program" (code, name, description, credits, semester, lecturer_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
-
-	err = h.db.Pool.QueryRow(ctx, query, req.Code, req.Name, req.Description, req.Credits, req.Semester, req.LecturerID).Scan(&programID)
+	if isDenied(err) {
+		return denied(c, err, "Lecturer not found")
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			return utils.ConflictResponse(c, "Program code already exists")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to create program")
+		return utils.DBError(err, "Failed to create program").
+			On(utils.ErrCodeAlreadyExists, "Program code already exists").
+			On(utils.ErrCodeReferenceViolation, "Invalid lecturer ID")
 	}
 
-	return utils.CreatedResponse(c, "Program created successfully", fiber.Map{"id": int(programID)})
+	return utils.CreatedResponse(c, "Program created successfully", fiber.Map{"id": programID})
 }
 
 // Update godoc
 // @Summary Update program
-// @Description Update an existing program (admin/lecturer only)
+// @Description Replace every field of a program except its lecturer (admin, or the lecturer teaching it). is_active is kept when omitted; use PATCH to change single fields.
 // @Tags Programs
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Program ID"
 // @Param request body models.UpdateProgramRequest true "Updated program details"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} map[string]interface{} "Program updated successfully"
+// @Header 200 {string} ETag "New version of the record"
 // @Failure 400 {object} map[string]interface{} "Invalid request"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not the lecturer of this program"
+// @Failure 404 {object} map[string]interface{} "Program not found"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
 // @Router /programs/{id} [put]
 func (h *ProgramHandler) Update(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -163,34 +216,68 @@ func (h *ProgramHandler) Update(c *fiber.Ctx) error {
 	}
 
 	var req models.UpdateProgramRequest
-	if err := c.BodyParser(&req); err != nil {
-		return utils.BadRequestResponse(c, "Invalid request body")
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	ctx := context.Background()
-
-	// Check if program exists
-	var exists bool
-	err = h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "program" WHERE id = $1)`, id).Scan(&exists)
-	if err != nil || !exists {
-		return utils.NotFoundResponse(c, "Program not found")
+	version, err := h.programs.Update(requestContext(c), policy.ActorFrom(c), id, req)
+	if isDenied(err) {
+		return denied(c, err, "Program not found")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to update program").
+			On(utils.ErrCodeNotFound, "Program not found").
+			On(utils.ErrCodeAlreadyExists, "Program code already exists")
 	}
 
-	query := `UPDATE "program" SET code = $1, name = $2, description = $3, credits = $4, semester = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $6`
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Program updated successfully", fiber.Map{"version": version})
+}
 
-	result, err := h.db.Pool.Exec(ctx, query, req.Code, req.Name, req.Description, req.Credits, req.Semester, id)
+// Patch godoc
+// @Summary Patch program
+// @Description Change only the fields present in a JSON merge patch (admin, or the lecturer teaching it). null clears description; code, name, credits, semester and is_active cannot be null. The lecturer cannot be patched.
+// @Tags Programs
+// @Accept application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Program ID"
+// @Param request body models.ProgramPatch true "Fields to change"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
+// @Success 200 {object} map[string]interface{} "Program updated successfully"
+// @Header 200 {string} ETag "New version of the record"
+// @Failure 400 {object} map[string]interface{} "Invalid program ID, body or unknown field"
+// @Failure 403 {object} map[string]interface{} "Not the lecturer of this program"
+// @Failure 404 {object} map[string]interface{} "Program not found"
+// @Failure 409 {object} map[string]interface{} "Program code already exists"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 415 {object} map[string]interface{} "Body is not a merge patch"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
+// @Router /programs/{id} [patch]
+func (h *ProgramHandler) Patch(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			return utils.ConflictResponse(c, "Program code already exists")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to update program")
+		return utils.BadRequestResponse(c, "Invalid program ID")
 	}
 
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Program not found")
+	var patch models.ProgramPatch
+	if err := parseMergePatch(c, &patch); err != nil {
+		return err
+	}
+
+	version, err := h.programs.Patch(requestContext(c), policy.ActorFrom(c), id, patch)
+	if isDenied(err) {
+		return denied(c, err, "Program not found")
+	}
+	if err != nil {
+		return utils.DBError(err, "Failed to update program").
+			On(utils.ErrCodeNotFound, "Program not found").
+			On(utils.ErrCodeAlreadyExists, "Program code already exists")
 	}
 
-	return utils.SuccessResponse(c, "Program updated successfully", nil)
+	c.Set(fiber.HeaderETag, utils.ETag(version))
+	return utils.SuccessResponse(c, "Program updated successfully", fiber.Map{"version": version})
 }
 
 // Delete godoc
@@ -201,10 +288,14 @@ func (h *ProgramHandler) Update(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Program ID"
+// @Param If-Match header string false "ETag the change is based on; required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} map[string]interface{} "Program deleted successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid program ID"
+// @Failure 403 {object} map[string]interface{} "Not the lecturer of this program"
 // @Failure 404 {object} map[string]interface{} "Program not found"
-// @Failure 409 {object} map[string]interface{} "Cannot delete, program has related data"
+// @Failure 409 {object} map[string]interface{} "Cannot delete, program has enrollments"
+// @Failure 412 {object} map[string]interface{} "Record changed since it was read"
+// @Failure 428 {object} map[string]interface{} "If-Match required"
 // @Router /programs/{id} [delete]
 func (h *ProgramHandler) Delete(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -212,27 +303,14 @@ func (h *ProgramHandler) Delete(c *fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid program ID")
 	}
 
-	ctx := context.Background()
-
-	// Check if program exists
-	var exists bool
-	err = h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "program" WHERE id = $1)`, id).Scan(&exists)
-	if err != nil || !exists {
-		return utils.NotFoundResponse(c, "Program not found")
+	err = h.programs.Delete(requestContext(c), policy.ActorFrom(c), id)
+	if isDenied(err) {
+		return denied(c, err, "Program not found")
 	}
-
-	query := `DELETE FROM "program" WHERE id = $1`
-
-	result, err := h.db.Pool.Exec(ctx, query, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "foreign key") {
-			return utils.ConflictResponse(c, "Cannot delete program, it has related enrollments")
-		}
-		return utils.InternalServerErrorResponse(c, "Failed to delete program")
-	}
-
-	if result.RowsAffected() == 0 {
-		return utils.NotFoundResponse(c, "Program not found")
+		return utils.DBError(err, "Failed to delete program").
+			On(utils.ErrCodeNotFound, "Program not found").
+			On(utils.ErrCodeReferenceViolation, "Cannot delete program, it has related enrollments")
 	}
 
 	return utils.SuccessResponse(c, "Program deleted successfully", nil)
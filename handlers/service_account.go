@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"mbkm-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ServiceAccountHandler struct {
+	db *database.Database
+}
+
+func NewServiceAccountHandler(db *database.Database) *ServiceAccountHandler {
+	return &ServiceAccountHandler{db: db}
+}
+
+const apiKeyColumns = `id, service_account_id, name, prefix, scopes, expires_at, last_used_at, COALESCE(last_used_ip, ''), revoked_at, created_by, created_at`
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }, k *models.APIKey) error {
+	return row.Scan(&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP, &k.RevokedAt, &k.CreatedBy, &k.CreatedAt)
+}
+
+// GetAll godoc
+// @Summary List service accounts
+// @Description Retrieve all service accounts used by integrations (admin only)
+// @Tags Service Accounts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ServiceAccount "Service accounts retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /service-accounts [get]
+func (h *ServiceAccountHandler) GetAll(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	query := `SELECT id, name, COALESCE(description, ''), role, is_active, created_by, created_at, updated_at FROM "service_account" ORDER BY name ASC`
+
+	rows, err := h.db.Pool.Query(ctx, query)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch service accounts")
+	}
+	defer rows.Close()
+
+	var accounts []models.ServiceAccount
+	for rows.Next() {
+		var a models.ServiceAccount
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.Role, &a.IsActive, &a.CreatedBy, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return utils.DBError(err, "Failed to scan service account data")
+		}
+		accounts = append(accounts, a)
+	}
+
+	if accounts == nil {
+		accounts = []models.ServiceAccount{}
+	}
+
+	return utils.SuccessResponse(c, "Service accounts retrieved successfully", accounts)
+}
+
+// Create godoc
+// @Summary Create a service account
+// @Description Create a named service account for an integration. Its role caps what its API keys can access (admin only).
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateServiceAccountRequest true "Service account details"
+// @Success 201 {object} map[string]interface{} "Service account created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 409 {object} map[string]interface{} "Name already exists"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /service-accounts [post]
+func (h *ServiceAccountHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateServiceAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	var id int
+	query := `
+		INSERT INTO "service_account" (name, description, role, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`
+	err := h.db.Pool.QueryRow(ctx, query, req.Name, req.Description, req.Role, c.Locals("userID").(int)).Scan(&id)
+	if err != nil {
+		return utils.DBError(err, "Failed to create service account").On(utils.ErrCodeAlreadyExists, "Service account name already exists")
+	}
+
+	return utils.CreatedResponse(c, "Service account created successfully", fiber.Map{"id": id})
+}
+
+// Update godoc
+// @Summary Update a service account
+// @Description Change description, role or active flag. Deactivating an account disables all of its keys (admin only).
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param request body models.UpdateServiceAccountRequest true "Updated fields"
+// @Success 200 {object} map[string]interface{} "Service account updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Service account not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /service-accounts/{id} [put]
+func (h *ServiceAccountHandler) Update(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid service account ID")
+	}
+
+	var req models.UpdateServiceAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	query := `
+		UPDATE "service_account"
+		SET description = COALESCE($1, description), role = COALESCE($2, role), is_active = COALESCE($3, is_active), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`
+	result, err := h.db.Pool.Exec(ctx, query, req.Description, req.Role, req.IsActive, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to update service account")
+	}
+
+	if result.RowsAffected() == 0 {
+		return utils.NotFoundResponse(c, "Service account not found")
+	}
+
+	return utils.SuccessResponse(c, "Service account updated successfully", nil)
+}
+
+// GetKeys godoc
+// @Summary List API keys of a service account
+// @Description Retrieve key metadata (prefix, scopes, expiry, last use). The secret itself is never returned (admin only).
+// @Tags Service Accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {array} models.APIKey "API keys retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid service account ID"
+// @Router /service-accounts/{id}/keys [get]
+func (h *ServiceAccountHandler) GetKeys(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid service account ID")
+	}
+
+	ctx := c.UserContext()
+	query := `SELECT ` + apiKeyColumns + ` FROM "api_key" WHERE service_account_id = $1 ORDER BY created_at DESC`
+
+	rows, err := h.db.Pool.Query(ctx, query, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch API keys")
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return utils.DBError(err, "Failed to scan API key data")
+		}
+		keys = append(keys, k)
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	return utils.SuccessResponse(c, "API keys retrieved successfully", keys)
+}
+
+// CreateKey godoc
+// @Summary Issue an API key
+// @Description Issue a scoped API key for a service account. The plaintext key is returned only in this response (admin only).
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param request body models.CreateAPIKeyRequest true "Key name, scopes and optional expiry"
+// @Success 201 {object} models.CreateAPIKeyResponse "API key created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "Service account not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /service-accounts/{id}/keys [post]
+func (h *ServiceAccountHandler) CreateKey(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid service account ID")
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			return utils.BadRequestResponse(c, "Invalid scope: "+scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return utils.BadRequestResponse(c, "Expiry must be in the future")
+	}
+
+	ctx := c.UserContext()
+	var exists bool
+	if err := h.db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "service_account" WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return utils.DBError(err, "Failed to create API key")
+	}
+	if !exists {
+		return utils.NotFoundResponse(c, "Service account not found")
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate API key")
+	}
+
+	var resp models.CreateAPIKeyResponse
+	query := `
+		INSERT INTO "api_key" (service_account_id, name, prefix, key_hash, scopes, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+		RETURNING ` + apiKeyColumns
+	row := h.db.Pool.QueryRow(ctx, query, id, req.Name, prefix, utils.HashToken(key), req.Scopes, req.ExpiresAt, c.Locals("userID").(int))
+	if err := scanAPIKey(row, &resp.APIKey); err != nil {
+		return utils.DBError(err, "Failed to create API key")
+	}
+	resp.Key = key
+
+	return utils.CreatedResponse(c, "API key created successfully, store it now as it cannot be shown again", resp)
+}
+
+// RevokeKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key immediately (admin only)
+// @Tags Service Accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param keyId path int true "API key ID"
+// @Success 200 {object} map[string]interface{} "API key revoked successfully"
+// @Failure 404 {object} map[string]interface{} "API key not found"
+// @Router /service-accounts/{id}/keys/{keyId} [delete]
+func (h *ServiceAccountHandler) RevokeKey(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid service account ID")
+	}
+
+	keyID, err := strconv.Atoi(c.Params("keyId"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid API key ID")
+	}
+
+	ctx := c.UserContext()
+	query := `UPDATE "api_key" SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND service_account_id = $2 AND revoked_at IS NULL`
+
+	result, err := h.db.Pool.Exec(ctx, query, keyID, id)
+	if err != nil {
+		return utils.DBError(err, "Failed to revoke API key")
+	}
+
+	if result.RowsAffected() == 0 {
+		return utils.NotFoundResponse(c, "API key not found")
+	}
+
+	return utils.SuccessResponse(c, "API key revoked successfully", nil)
+}
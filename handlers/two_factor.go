@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"mbkm-api/models"
+	"mbkm-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// SetupTwoFactor godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret and otpauth URI. 2FA stays disabled until the first code is confirmed.
+// @Tags Two-Factor Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorSetupResponse "TOTP secret generated"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 409 {object} map[string]interface{} "2FA already enabled"
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.Get(ctx, userID)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if user.TOTPEnabled {
+		return utils.ConflictResponse(c, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate secret")
+	}
+
+	if err := h.repos.Users.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return utils.DBError(err, "Failed to store secret")
+	}
+
+	return utils.SuccessResponse(c, "Scan the QR code and confirm with a code to enable 2FA", models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPProvisioningURI(h.cfg.MFAIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm TOTP enrollment
+// @Description Verify the first code from the authenticator app, enable 2FA and return one-time recovery codes
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse "2FA enabled"
+// @Failure 400 {object} map[string]interface{} "Setup not started or invalid code"
+// @Failure 409 {object} map[string]interface{} "2FA already enabled"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	var req models.TwoFactorCodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.Get(ctx, userID)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if user.TOTPEnabled {
+		return utils.ConflictResponse(c, "Two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return utils.BadRequestResponse(c, "Two-factor setup has not been started")
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return utils.BadRequestResponse(c, "Invalid two-factor code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate recovery codes")
+	}
+
+	err = h.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := h.repos.Users.EnableTOTP(ctx, userID, step); err != nil {
+			return err
+		}
+		return h.repos.Users.ReplaceRecoveryCodes(ctx, userID, hashes)
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to enable two-factor authentication")
+	}
+
+	return utils.SuccessResponse(c, "Two-factor authentication enabled. Store the recovery codes somewhere safe.", models.RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable TOTP
+// @Description Turn off 2FA after re-checking the password and a TOTP or recovery code. Not allowed for roles where 2FA is mandatory.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DisableTwoFactorRequest true "Password and second factor"
+// @Success 200 {object} map[string]interface{} "2FA disabled"
+// @Failure 400 {object} map[string]interface{} "2FA not enabled"
+// @Failure 401 {object} map[string]interface{} "Invalid password or code"
+// @Failure 403 {object} map[string]interface{} "2FA is mandatory for this role"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	var req models.DisableTwoFactorRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.Get(ctx, userID)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if h.cfg.MFARequiredFor(user.Role) {
+		return utils.ForbiddenResponse(c, "Two-factor authentication is mandatory for your role")
+	}
+	if !user.TOTPEnabled {
+		return utils.BadRequestResponse(c, "Two-factor authentication is not enabled")
+	}
+
+	if err := utils.CheckPassword(user.PasswordHash, req.Password); err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid password or two-factor code")
+	}
+
+	ok, err := h.verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return utils.DBError(err, "Failed to verify two-factor code")
+	}
+	if !ok {
+		return utils.UnauthorizedResponse(c, "Invalid password or two-factor code")
+	}
+
+	if err := h.repos.Users.DisableTOTP(ctx, userID); err != nil {
+		return utils.DBError(err, "Failed to disable two-factor authentication")
+	}
+
+	return utils.SuccessResponse(c, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Invalidate all existing recovery codes and issue a new set. Requires a current TOTP code.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse "Recovery codes regenerated"
+// @Failure 400 {object} map[string]interface{} "2FA not enabled"
+// @Failure 401 {object} map[string]interface{} "Invalid code"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(int)
+
+	var req models.TwoFactorCodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	user, err := h.repos.Users.Get(ctx, userID)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	if !user.TOTPEnabled {
+		return utils.BadRequestResponse(c, "Two-factor authentication is not enabled")
+	}
+
+	ok, err := h.verifySecondFactor(ctx, user, req.Code, "")
+	if err != nil {
+		return utils.DBError(err, "Failed to verify two-factor code")
+	}
+	if !ok {
+		return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate recovery codes")
+	}
+
+	if err := h.repos.Users.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return utils.DBError(err, "Failed to generate recovery codes")
+	}
+
+	return utils.SuccessResponse(c, "Recovery codes regenerated", models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete two-factor login
+// @Description Exchange the mfa_token from /auth/login plus a TOTP code or a recovery code for access and refresh tokens
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.LoginTwoFactorRequest true "Two-factor login request"
+// @Success 200 {object} models.LoginResponse "Login successful"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Invalid or expired challenge, or invalid code"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Failure 429 {object} map[string]interface{} "Too many failed attempts"
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	var req models.LoginTwoFactorRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	claims, err := h.tokens.Validate(req.MFAToken)
+	if err != nil || claims.Purpose != utils.TokenPurposeMFA {
+		return utils.UnauthorizedResponse(c, "Invalid or expired two-factor challenge")
+	}
+
+	ctx := c.UserContext()
+	ip := c.IP()
+
+	lockedFor, err := h.throttle.lockedFor(ctx, claims.Email, ip)
+	if err != nil {
+		return utils.DBError(err, "Failed to check login attempts")
+	}
+	if lockedFor > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(lockedFor.Seconds())+1))
+		return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+	}
+
+	user, err := h.repos.Users.Get(ctx, claims.UserID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return utils.DBError(err, "Failed to fetch user")
+	}
+	if err != nil || !user.TOTPEnabled {
+		return utils.UnauthorizedResponse(c, "Invalid or expired two-factor challenge")
+	}
+
+	if !user.IsActive {
+		return utils.ForbiddenResponse(c, "Account is inactive")
+	}
+
+	ok, err := h.verifySecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return utils.DBError(err, "Failed to verify two-factor code")
+	}
+	if !ok {
+		if err := h.throttle.recordFailure(ctx, user.Email, ip); err != nil {
+			return utils.DBError(err, "Failed to record login attempt")
+		}
+		return utils.UnauthorizedResponse(c, "Invalid two-factor code")
+	}
+
+	if err := h.throttle.recordSuccess(ctx, user.Email); err != nil {
+		return utils.DBError(err, "Failed to reset login attempts")
+	}
+
+	tokens, err := h.createSession(ctx, c, user)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate token")
+	}
+
+	return utils.SuccessResponse(c, "Login successful", models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         user,
+	})
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// Each TOTP step and each recovery code can only be used once.
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return false, nil
+		}
+		return h.repos.Users.UseTOTPStep(ctx, user.ID, step)
+	}
+
+	if recoveryCode != "" {
+		return h.repos.Users.UseRecoveryCode(ctx, user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+
+	return false, nil
+}
+
+// newRecoveryCodes generates a fresh set of recovery codes and their
+// hashes. Only the hashes are persisted.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes = make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mbkm-api/config"
+	"mbkm-api/mailer"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+type UserHandler struct {
+	repos  *repository.Repositories
+	cfg    *config.Config
+	mailer mailer.Mailer
+}
+
+func NewUserHandler(repos *repository.Repositories, cfg *config.Config, m mailer.Mailer) *UserHandler {
+	return &UserHandler{repos: repos, cfg: cfg, mailer: m}
+}
+
+// GetAll godoc
+// @Summary List users
+// @Description Retrieve users with paging and optional role / active filters (admin only)
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param page query int false "Page number, for offset paging"
+// @Param cursor query string false "next_cursor of the previous page, for cursor paging"
+// @Param sort query string false "id (default), username, full_name or created_at; prefix - for descending"
+// @Param role query string false "Filter by role"
+// @Param is_active query bool false "Filter by active flag"
+// @Success 200 {array} models.User "Users retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid list query"
+// @Router /users [get]
+func (h *UserHandler) GetAll(c *fiber.Ctx) error {
+	opts, err := listOptions(c)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch users")
+	}
+
+	isActive, ok := queryBoolFilter(c, "is_active")
+	if !ok {
+		return utils.BadRequestResponse(c, "Invalid is_active filter")
+	}
+
+	filter := repository.UserFilter{Role: c.Query("role"), IsActive: isActive}
+	page, err := h.repos.Users.List(c.UserContext(), filter, opts)
+	if err != nil {
+		return listFailed(c, err, "Failed to fetch users")
+	}
+
+	return paginated(c, "Users retrieved successfully", page, opts)
+}
+
+// GetByID godoc
+// @Summary Get user by ID
+// @Description Retrieve a specific user (admin only)
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User "User retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid user ID"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Router /users/{id} [get]
+func (h *UserHandler) GetByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	user, err := h.repos.Users.Get(c.UserContext(), id)
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	return utils.SuccessResponse(c, "User retrieved successfully", user)
+}
+
+// Create godoc
+// @Summary Provision a user
+// @Description Create a user account with any role (admin only)
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateUserRequest true "User details"
+// @Success 201 {object} map[string]interface{} "User created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 409 {object} map[string]interface{} "Username or email already exists"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users [post]
+func (h *UserHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateUserRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.cfg.PasswordPolicy().Validate(req.Password); err != nil {
+		return utils.BadRequestResponse(c, err.Error())
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to hash password")
+	}
+
+	userID, err := h.repos.Users.Create(c.UserContext(), models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hashedPassword,
+		FullName:     req.FullName,
+		Phone:        req.Phone,
+		Role:         req.Role,
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to create user").On(utils.ErrCodeAlreadyExists, "Username or email already exists")
+	}
+
+	return utils.CreatedResponse(c, "User created successfully", fiber.Map{"id": userID})
+}
+
+// CreateInvitation godoc
+// @Summary Invite a user
+// @Description Issue a single-use invitation bound to an email and role and email the link to the invitee (admin only). Pending invitations for the same email are revoked.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateInvitationRequest true "Invitation details"
+// @Success 201 {object} map[string]interface{} "Invitation created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 409 {object} map[string]interface{} "Email already registered"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users/invitations [post]
+func (h *UserHandler) CreateInvitation(c *fiber.Ctx) error {
+	var req models.CreateInvitationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+
+	_, err := h.repos.Users.GetByEmail(ctx, req.Email)
+	if err == nil {
+		return utils.ConflictResponse(c, "Email already registered")
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return utils.DBError(err, "Failed to create invitation")
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to generate invitation token")
+	}
+	expiresAt := time.Now().Add(time.Hour * time.Duration(h.cfg.InvitationExpiration))
+	// API keys invite on behalf of no user.
+	var invitedBy *int
+	if userID, _ := c.Locals("userID").(int); userID != 0 {
+		invitedBy = &userID
+	}
+
+	invitationID, err := h.repos.Invitations.Create(ctx, models.Invitation{
+		Email:     req.Email,
+		Role:      req.Role,
+		TokenHash: utils.HashToken(token),
+		InvitedBy: invitedBy,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to create invitation")
+	}
+
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", h.cfg.AppBaseURL, token)
+	msg := mailer.Message{
+		To:      []string{req.Email},
+		Subject: "You have been invited to MBKM",
+		Body: fmt.Sprintf("You have been invited to join MBKM as %s.\n\n"+
+			"Open the link below before %s to set your password:\n%s\n", req.Role, expiresAt.Format(time.RFC1123), link),
+	}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("❌ Failed to send invitation email to %s: %v", req.Email, err)
+	}
+
+	// The plain token is only ever returned here; the database keeps its hash.
+	return utils.CreatedResponse(c, "Invitation created successfully", fiber.Map{
+		"id":         invitationID,
+		"email":      req.Email,
+		"role":       req.Role,
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetInvitations godoc
+// @Summary List pending invitations
+// @Description Retrieve invitations that have not been accepted or revoked (admin only)
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Invitation "Invitations retrieved successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Router /users/invitations [get]
+func (h *UserHandler) GetInvitations(c *fiber.Ctx) error {
+	invitations, err := h.repos.Invitations.ListPending(c.UserContext())
+	if err != nil {
+		return utils.DBError(err, "Failed to fetch invitations")
+	}
+
+	if invitations == nil {
+		invitations = []models.Invitation{}
+	}
+
+	return utils.SuccessResponse(c, "Invitations retrieved successfully", invitations)
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke an invitation
+// @Description Revoke a pending invitation so its token can no longer be redeemed (admin only)
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} map[string]interface{} "Invitation revoked successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid invitation ID"
+// @Failure 404 {object} map[string]interface{} "Invitation not found"
+// @Router /users/invitations/{id} [delete]
+func (h *UserHandler) RevokeInvitation(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid invitation ID")
+	}
+
+	if err := h.repos.Invitations.Revoke(c.UserContext(), id); err != nil {
+		return utils.DBError(err, "Failed to revoke invitation").On(utils.ErrCodeNotFound, "Invitation not found")
+	}
+
+	return utils.SuccessResponse(c, "Invitation revoked successfully", nil)
+}
+
+// Update godoc
+// @Summary Update user profile
+// @Description Update username, email, full name or phone of a user. Omitted fields are left unchanged (admin only).
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body models.UpdateUserRequest true "Updated user fields"
+// @Success 200 {object} map[string]interface{} "User updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 409 {object} map[string]interface{} "Username or email already exists"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users/{id} [put]
+func (h *UserHandler) Update(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req models.UpdateUserRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.repos.Users.Update(c.UserContext(), id, req); err != nil {
+		return utils.DBError(err, "Failed to update user").
+			On(utils.ErrCodeAlreadyExists, "Username or email already exists").
+			On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	return utils.SuccessResponse(c, "User updated successfully", nil)
+}
+
+// UpdateRole godoc
+// @Summary Change user role
+// @Description Change the role of a user and revoke their sessions so new tokens carry the new role (admin only)
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body models.UpdateUserRoleRequest true "New role"
+// @Success 200 {object} map[string]interface{} "User role updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users/{id}/role [put]
+func (h *UserHandler) UpdateRole(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req models.UpdateUserRoleRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if id == c.Locals("userID").(int) {
+		return utils.BadRequestResponse(c, "You cannot change your own role")
+	}
+
+	return h.updateAndRevoke(c, id, func(ctx context.Context) error {
+		return h.repos.Users.SetRole(ctx, id, req.Role)
+	}, "User role updated successfully")
+}
+
+// UpdateStatus godoc
+// @Summary Activate or deactivate user
+// @Description Toggle is_active. Deactivating a user revokes all of their sessions immediately (admin only).
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body models.UpdateUserStatusRequest true "Active flag"
+// @Success 200 {object} map[string]interface{} "User status updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 422 {object} map[string]interface{} "Validation failed"
+// @Router /users/{id}/status [put]
+func (h *UserHandler) UpdateStatus(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req models.UpdateUserStatusRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if id == c.Locals("userID").(int) && !*req.IsActive {
+		return utils.BadRequestResponse(c, "You cannot deactivate your own account")
+	}
+
+	if *req.IsActive {
+		if err := h.repos.Users.SetActive(c.UserContext(), id, true); err != nil {
+			return utils.DBError(err, "Failed to update user status").On(utils.ErrCodeNotFound, "User not found")
+		}
+		return utils.SuccessResponse(c, "User status updated successfully", nil)
+	}
+
+	return h.updateAndRevoke(c, id, func(ctx context.Context) error {
+		return h.repos.Users.SetActive(ctx, id, false)
+	}, "User status updated successfully")
+}
+
+// ResetPassword godoc
+// @Summary Force password reset
+// @Description Invalidate the user's password, revoke all sessions and email a single-use reset link (admin only). The user cannot sign in with a password until the link is used.
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Password reset link sent"
+// @Failure 400 {object} map[string]interface{} "Invalid user ID"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Router /users/{id}/reset-password [post]
+func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID")
+	}
+
+	// Replace the password with one nobody knows, so the old one stops
+	// working at once and the reset link is the only way back in.
+	unusable, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to reset password")
+	}
+	hashedPassword, err := utils.HashPassword(unusable)
+	if err != nil {
+		return utils.InternalServerErrorResponse(c, "Failed to hash password")
+	}
+
+	ctx := c.UserContext()
+	var email, token string
+	var expiresAt time.Time
+	err = h.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		user, err := h.repos.Users.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		email = user.Email
+
+		if err := h.repos.Users.SetPassword(ctx, id, hashedPassword, true); err != nil {
+			return err
+		}
+		if _, err := h.repos.Sessions.RevokeAll(ctx, id, 0); err != nil {
+			return err
+		}
+
+		token, expiresAt, err = storeResetToken(ctx, h.repos, h.cfg, id)
+		return err
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to reset password").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	msg := resetLinkMessage(h.cfg, email, token, "An administrator has reset your MBKM password.")
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("❌ Failed to send password reset email to %s: %v", email, err)
+	}
+
+	// The link only goes to the user's mailbox, never into the response.
+	return utils.SuccessResponse(c, "Password reset link sent", fiber.Map{
+		"email":      email,
+		"expires_at": expiresAt,
+	})
+}
+
+// updateAndRevoke applies update to user id and revokes all of their
+// sessions in the same transaction.
+func (h *UserHandler) updateAndRevoke(c *fiber.Ctx, id int, update func(ctx context.Context) error, message string) error {
+	err := h.repos.Tx.Do(c.UserContext(), func(ctx context.Context) error {
+		if err := update(ctx); err != nil {
+			return err
+		}
+		_, err := h.repos.Sessions.RevokeAll(ctx, id, 0)
+		return err
+	})
+	if err != nil {
+		return utils.DBError(err, "Failed to update user").On(utils.ErrCodeNotFound, "User not found")
+	}
+
+	return utils.SuccessResponse(c, message, nil)
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends mail through an SMTP relay. Authentication is skipped when
+// no username is configured, which is what local catchers such as MailHog or
+// Mailpit expect.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	sender   string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	// The envelope sender must be a bare address even when MAIL_FROM carries a
+	// display name.
+	sender := from
+	if addr, err := mail.ParseAddress(from); err == nil {
+		sender = addr.Address
+	}
+
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+		sender:   sender,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(m.addr, auth, m.sender, msg.To, build(m.from, msg))
+}
@@ -1,16 +1,32 @@
 package middleware
 
 import (
-	"mbkm-api/config"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
 	"mbkm-api/utils"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) fiber.Handler {
+// AuthMiddleware accepts either a Bearer access token or a service account
+// API key (X-API-Key header or "Authorization: ApiKey <key>").
+func AuthMiddleware(tokens *utils.TokenManager, principals repository.PrincipalRepository, perms *policy.Resolver) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+
+		apiKey := c.Get("X-API-Key")
+		if apiKey == "" && strings.HasPrefix(authHeader, "ApiKey ") {
+			apiKey = strings.TrimPrefix(authHeader, "ApiKey ")
+		}
+		if apiKey != "" {
+			return apiKeyAuth(c, principals, perms, apiKey)
+		}
+
 		if authHeader == "" {
 			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Missing authorization header")
 		}
@@ -20,29 +36,113 @@ func AuthMiddleware(cfg *config.Config) fiber.Handler {
 			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Invalid authorization header format")
 		}
 
-		claims, err := utils.ValidateToken(tokenParts[1], cfg.JWTSecret)
-		if err != nil {
+		claims, err := tokens.Validate(tokenParts[1])
+		if err != nil || claims.Purpose != "" {
 			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Invalid or expired token")
 		}
 
+		// Access tokens are only honoured while their session is alive and the
+		// account is active, so logout and deactivation take effect immediately.
+		// Impersonation tokens ride on the administrator's session, and the
+		// impersonated account must be active too.
+		sessionUserID := claims.UserID
+		if claims.Act != nil {
+			sessionUserID = claims.Act.UserID
+		}
+
+		active, err := principals.SessionActive(c.UserContext(), claims.SessionID, sessionUserID, claims.UserID)
+		if err != nil {
+			return utils.DBError(err, "Failed to verify session")
+		}
+		if !active {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Session has been revoked")
+		}
+
 		c.Locals("userID", claims.UserID)
+		c.Locals("sessionID", claims.SessionID)
 		c.Locals("email", claims.Email)
 		c.Locals("role", claims.Role)
+		c.Locals("mfaSetupRequired", claims.MFASetupRequired)
 
-		return c.Next()
+		if claims.Act != nil {
+			c.Locals("impersonatorID", claims.Act.UserID)
+			log.Printf("🎭 [%v] user %d as user %d: %s %s", c.Locals("requestid"), claims.Act.UserID, claims.UserID, c.Method(), c.Path())
+
+			// Impersonation is for looking, never for acting on someone's behalf.
+			if !isReadOnlyMethod(c.Method()) {
+				return utils.ErrorResponse(c, fiber.StatusForbidden, "This action is not allowed while impersonating a user")
+			}
+		}
+
+		return resolvePermissions(c, perms, claims.Role)
 	}
 }
 
-func RoleMiddleware(allowedRoles ...string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		role := c.Locals("role").(string)
+func isReadOnlyMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
 
-		for _, allowedRole := range allowedRoles {
-			if role == allowedRole {
-				return c.Next()
-			}
-		}
+// apiKeyAuth authenticates a service account. The principal carries the
+// account's role, and with it that role's permissions, plus the key scopes
+// checked by ScopeMiddleware. userID is 0 since no human is behind the request.
+func apiKeyAuth(c *fiber.Ctx, principals repository.PrincipalRepository, perms *policy.Resolver, key string) error {
+	prefix, ok := utils.ParseAPIKeyPrefix(key)
+	if !ok {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Invalid API key")
+	}
+
+	ctx := c.UserContext()
+	k, err := principals.APIKey(ctx, prefix)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return utils.DBError(err, "Failed to verify API key")
+	}
+	if err != nil || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(utils.HashToken(key))) != 1 {
+		return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Invalid, expired or revoked API key")
+	}
+
+	principals.TouchAPIKey(ctx, k.ID, c.IP())
+
+	c.Locals("userID", 0)
+	c.Locals("sessionID", 0)
+	c.Locals("email", "")
+	c.Locals("role", k.Role)
+	c.Locals("serviceAccountID", k.ServiceAccountID)
+	c.Locals("apiKeyID", k.ID)
+	c.Locals("scopes", k.Scopes)
+
+	return resolvePermissions(c, perms, k.Role)
+}
+
+// resolvePermissions stores the role's permissions on the request so route
+// checks and ownership policies do not query them again.
+func resolvePermissions(c *fiber.Ctx, perms *policy.Resolver, role string) error {
+	set, err := perms.Permissions(c.UserContext(), role)
+	if err != nil {
+		return utils.DBError(err, "Failed to resolve permissions")
+	}
+	c.Locals("permissions", set)
 
+	return c.Next()
+}
+
+// RequirePermission rejects principals whose role lacks permission.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if set, _ := c.Locals("permissions").(policy.PermissionSet); set.Has(permission) {
+			return c.Next()
+		}
 		return utils.ErrorResponse(c, fiber.StatusForbidden, "Access denied")
 	}
 }
+
+// MFAEnrollmentMiddleware blocks tokens issued to users whose role mandates
+// 2FA until they have enrolled. Routes registered before it (2FA setup,
+// profile, logout) stay reachable.
+func MFAEnrollmentMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if required, _ := c.Locals("mfaSetupRequired").(bool); required {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Two-factor authentication setup required")
+		}
+		return c.Next()
+	}
+}
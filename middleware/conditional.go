@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConditionalMiddleware handles conditional requests on a record route
+// group. The versions named in If-Match go with c.UserContext() to the
+// repository, and a write against a row that has moved on answers 412; with
+// requireIfMatch, PUT, PATCH and DELETE without If-Match answer 428. A GET
+// whose ETag matches If-None-Match answers 304 without a body.
+func ConditionalMiddleware(requireIfMatch bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead:
+			if err := c.Next(); err != nil {
+				return err
+			}
+			etag := string(c.Response().Header.Peek(fiber.HeaderETag))
+			if etag != "" && c.Response().StatusCode() == fiber.StatusOK && utils.NoneMatch(c.Get(fiber.HeaderIfNoneMatch), etag) {
+				c.Status(fiber.StatusNotModified)
+				c.Response().ResetBody()
+			}
+			return nil
+
+		case fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+			ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+			if ifMatch == "" {
+				if requireIfMatch {
+					return utils.NewAppError(fiber.StatusPreconditionRequired, utils.ErrCodePreconditionNeeded, "If-Match header with the record's ETag is required")
+				}
+				return c.Next()
+			}
+			// "*" only asks for the record to exist, which the write checks anyway.
+			if ifMatch != "*" {
+				c.SetUserContext(repository.WithVersions(c.UserContext(), utils.ETagVersions(ifMatch)...))
+			}
+
+			err := c.Next()
+			if errors.Is(err, repository.ErrVersionMismatch) {
+				return utils.NewAppError(fiber.StatusPreconditionFailed, utils.ErrCodePreconditionFailed, "Record was changed by someone else, fetch it again and retry")
+			}
+			return err
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"mbkm-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// RateLimit allows at most max requests per client IP within window.
+func RateLimit(max int, window time.Duration) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: window,
+		LimitReached: func(c *fiber.Ctx) error {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(window.Seconds())))
+			return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Too many requests, please try again later")
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"mbkm-api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScopeMiddleware enforces API key scopes on a route group: reads need
+// "<resource>:read", everything else "<resource>:write". "<resource>:*" grants
+// both. Requests authenticated with a user token are not affected.
+func ScopeMiddleware(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, isAPIKey := c.Locals("scopes").([]string)
+		if !isAPIKey {
+			return c.Next()
+		}
+
+		required := resource + ":write"
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			required = resource + ":read"
+		}
+
+		for _, s := range scopes {
+			if s == required || s == resource+":*" {
+				return c.Next()
+			}
+		}
+
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "API key lacks scope "+required)
+	}
+}
+
+// UserOnlyMiddleware rejects API keys on routes that act on the signed-in
+// user (profile, sessions, 2FA) or that could mint further credentials.
+func UserOnlyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, isAPIKey := c.Locals("apiKeyID").(int); isAPIKey {
+			return utils.ErrorResponse(c, fiber.StatusForbidden, "Not available to API keys")
+		}
+		return c.Next()
+	}
+}
@@ -2,36 +2,46 @@ package models
 
 import "time"
 
-type Assessment struct {
-	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	EnrollmentID int       `gorm:"not null" json:"enrollment_id"`
-	StudentID    int       `gorm:"not null" json:"student_id"`
-	ProgramID    int       `gorm:"not null" json:"program_id"`
-	Category     string    `gorm:"type:varchar(50);not null" json:"category"`
-	Score        float64   `gorm:"type:decimal(5,2);default:0" json:"score"`
-	MaxScore     float64   `gorm:"type:decimal(5,2)" json:"max_score"`
-	Weight       float64   `gorm:"type:decimal(5,2);default:0" json:"weight"`
-	Notes        string    `gorm:"type:text" json:"notes"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-}
+// Assessment categories, the components a grade is made of. Keep the oneof
+// rule of CreateAssessmentRequest in step.
+const (
+	AssessmentCategoryAssignment   = "assignment"
+	AssessmentCategoryQuiz         = "quiz"
+	AssessmentCategoryMidterm      = "midterm"
+	AssessmentCategoryFinal        = "final"
+	AssessmentCategoryPracticum    = "practicum"
+	AssessmentCategoryProject      = "project"
+	AssessmentCategoryPresentation = "presentation"
+	AssessmentCategoryReport       = "report"
+)
 
-func (Assessment) TableName() string {
-	return "assessment"
+type Assessment struct {
+	ID           int       `json:"id"`
+	EnrollmentID int       `json:"enrollment_id"`
+	StudentID    int       `json:"student_id"`
+	ProgramID    int       `json:"program_id"`
+	Category     string    `json:"category"`
+	Score        float64   `json:"score"`
+	MaxScore     float64   `json:"max_score"`
+	Weight       float64   `json:"weight"`
+	Notes        string    `json:"notes"`
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type CreateAssessmentRequest struct {
-	EnrollmentID int     `json:"enrollment_id"`
-	Category     string  `json:"category"`
-	Score        float64 `json:"score"`
-	MaxScore     float64 `json:"max_score"`
-	Weight       float64 `json:"weight"`
+	EnrollmentID int     `json:"enrollment_id" validate:"required"`
+	Category     string  `json:"category" validate:"required,oneof=assignment quiz midterm final practicum project presentation report"`
+	Score        float64 `json:"score" validate:"min=0,ltefield=MaxScore"`
+	MaxScore     float64 `json:"max_score" validate:"gt=0,max=999.99"`
+	Weight       float64 `json:"weight" validate:"min=0,max=100"`
 	Notes        string  `json:"notes"`
 }
 
 type UpdateAssessmentRequest struct {
-	Score    float64 `json:"score"`
-	MaxScore float64 `json:"max_score"`
-	Weight   float64 `json:"weight"`
+	Score    float64 `json:"score" validate:"min=0,ltefield=MaxScore"`
+	MaxScore float64 `json:"max_score" validate:"gt=0,max=999.99"`
+	Weight   float64 `json:"weight" validate:"min=0,max=100"`
 	Notes    string  `json:"notes"`
 }
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// AuditActionImpersonate marks the start of an admin impersonation.
+	AuditActionImpersonate = "impersonate"
+)
+
+// AuditLog records one mutation or sensitive action. Rows are append-only
+// and chained: Hash covers the row's content plus PrevHash, so editing or
+// removing any row breaks every hash after it.
+type AuditLog struct {
+	ID               int64           `json:"id"`
+	RequestID        string          `json:"request_id"`
+	ActorUserID      *int            `json:"actor_user_id"`
+	ServiceAccountID *int            `json:"service_account_id"`
+	ImpersonatorID   *int            `json:"impersonator_id"`
+	ActorRole        string          `json:"actor_role"`
+	IPAddress        string          `json:"ip_address"`
+	Action           string          `json:"action"`
+	EntityType       string          `json:"entity_type"`
+	EntityID         int             `json:"entity_id"`
+	OldData          json.RawMessage `json:"old_data" swaggertype:"object"`
+	NewData          json.RawMessage `json:"new_data" swaggertype:"object"`
+	Changes          json.RawMessage `json:"changes" swaggertype:"object"`
+	PrevHash         string          `json:"prev_hash"`
+	Hash             string          `json:"hash"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// AuditChainStatus is the result of re-hashing the audit log.
+type AuditChainStatus struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	LastHash string `json:"last_hash"`
+}
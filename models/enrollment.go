@@ -2,25 +2,41 @@ package models
 
 import "time"
 
-type Enrollment struct {
-	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	StudentID  int       `gorm:"not null;index:idx_student_program,unique" json:"student_id"`
-	ProgramID  int       `gorm:"not null;index:idx_student_program,unique" json:"program_id"`
-	Status     string    `gorm:"type:varchar(20);default:'enrolled'" json:"status"`
-	EnrolledAt time.Time `gorm:"autoCreateTime" json:"enrolled_at"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+// Enrollment statuses, enforced by chk_enrollment_status.
+const (
+	EnrollmentStatusEnrolled  = "enrolled"
+	EnrollmentStatusActive    = "active"
+	EnrollmentStatusCompleted = "completed"
+	EnrollmentStatusDropped   = "dropped"
+)
+
+var ValidEnrollmentStatuses = []string{EnrollmentStatusEnrolled, EnrollmentStatusActive, EnrollmentStatusCompleted, EnrollmentStatusDropped}
+
+func IsValidEnrollmentStatus(status string) bool {
+	for _, s := range ValidEnrollmentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
 
-func (Enrollment) TableName() string {
-	return "enrollment"
+type Enrollment struct {
+	ID         int       `json:"id"`
+	StudentID  int       `json:"student_id"`
+	ProgramID  int       `json:"program_id"`
+	Status     string    `json:"status"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type CreateEnrollmentRequest struct {
-	StudentID int `json:"student_id"`
-	ProgramID int `json:"program_id"`
+	StudentID int `json:"student_id" validate:"required"`
+	ProgramID int `json:"program_id" validate:"required"`
 }
 
 type UpdateEnrollmentRequest struct {
-	Status string `json:"status"`
+	Status string `json:"status" validate:"required,oneof=enrolled active completed dropped"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type Invitation struct {
+	ID             int        `json:"id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"-"`
+	InvitedBy      *int       `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *int       `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+	Role  string `json:"role" validate:"required,oneof=admin lecturer student kaprodi dosen mahasiswa tim_akreditasi"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Username string `json:"username" validate:"required,max=100"`
+	Password string `json:"password" validate:"required"`
+	FullName string `json:"full_name" validate:"max=100"`
+	Phone    string `json:"phone" validate:"max=20"`
+}
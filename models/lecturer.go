@@ -3,33 +3,63 @@ package models
 import "time"
 
 type Lecturer struct {
-	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     int       `gorm:"not null;uniqueIndex" json:"user_id"`
-	NIDN       string    `gorm:"column:nidn;type:varchar(20);uniqueIndex;not null" json:"nidn"`
-	FullName   string    `gorm:"type:varchar(100);not null" json:"full_name"`
-	Phone      string    `gorm:"type:varchar(20)" json:"phone"`
-	Department string    `gorm:"type:varchar(100)" json:"department"`
-	IsActive   bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-}
-
-func (Lecturer) TableName() string {
-	return "lecturer"
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	NIDN       string    `json:"nidn"`
+	FullName   string    `json:"full_name"`
+	Phone      string    `json:"phone"`
+	Department string    `json:"department"`
+	IsActive   bool      `json:"is_active"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type CreateLecturerRequest struct {
-	UserID     int    `json:"user_id" binding:"required"`
-	NIDN       string `json:"nidn" binding:"required"`
-	FullName   string `json:"full_name" binding:"required"`
-	Phone      string `json:"phone"`
-	Department string `json:"department"`
+	UserID     int    `json:"user_id" validate:"required"`
+	NIDN       string `json:"nidn" validate:"required,max=20"`
+	FullName   string `json:"full_name" validate:"required,max=100"`
+	Phone      string `json:"phone" validate:"max=20"`
+	Department string `json:"department" validate:"max=100"`
 }
 
 type UpdateLecturerRequest struct {
-	NIDN       string `json:"nidn"`
-	FullName   string `json:"full_name"`
-	Phone      string `json:"phone"`
-	Department string `json:"department"`
+	NIDN       string `json:"nidn" validate:"required,max=20"`
+	FullName   string `json:"full_name" validate:"required,max=100"`
+	Phone      string `json:"phone" validate:"max=20"`
+	Department string `json:"department" validate:"max=100"`
 	IsActive   *bool  `json:"is_active"`
 }
+
+// Changes reports whether storing r would change l.
+func (r UpdateLecturerRequest) Changes(l Lecturer) bool {
+	return r.NIDN != l.NIDN || r.FullName != l.FullName || r.Phone != l.Phone ||
+		r.Department != l.Department || (r.IsActive != nil && *r.IsActive != l.IsActive)
+}
+
+// LecturerPatch is a merge patch for a lecturer. Absent members are left as
+// they are and null clears phone and department.
+type LecturerPatch struct {
+	NIDN       Patch[string] `json:"nidn" validate:"required,max=20"`
+	FullName   Patch[string] `json:"full_name" validate:"required,max=100"`
+	Phone      Patch[string] `json:"phone" validate:"max=20"`
+	Department Patch[string] `json:"department" validate:"max=100"`
+	IsActive   Patch[bool]   `json:"is_active" validate:"required"`
+}
+
+// Apply merges the patch into current, giving the full update to store.
+func (p LecturerPatch) Apply(current Lecturer) UpdateLecturerRequest {
+	req := UpdateLecturerRequest{
+		NIDN:       current.NIDN,
+		FullName:   current.FullName,
+		Phone:      current.Phone,
+		Department: current.Department,
+		IsActive:   &current.IsActive,
+	}
+	p.NIDN.ApplyTo(&req.NIDN)
+	p.FullName.ApplyTo(&req.FullName)
+	p.Phone.ApplyTo(&req.Phone)
+	p.Department.ApplyTo(&req.Department)
+	p.IsActive.ApplyTo(req.IsActive)
+	return req
+}
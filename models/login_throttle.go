@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	ThrottleKindEmail = "email"
+	ThrottleKindIP    = "ip"
+)
+
+// LoginThrottle tracks consecutive failed logins per email address or client
+// IP. Unknown emails are tracked too so lockouts do not reveal which accounts
+// exist.
+type LoginThrottle struct {
+	ID             int        `json:"id"`
+	Kind           string     `json:"kind"`
+	Value          string     `json:"value"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LastFailedAt   time.Time  `json:"last_failed_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OIDCAuthRequest holds the per-login secrets of an in-flight OpenID Connect
+// authorization request until the provider redirects back.
+type OIDCAuthRequest struct {
+	ID           int       `json:"id"`
+	StateHash    string    `json:"-"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+	IPAddress    string    `json:"ip_address"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "encoding/json"
+
+// Patch is one member of a JSON merge patch (RFC 7396). Set reports that the
+// member was present and Null that it was present as null.
+type Patch[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (f *Patch[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if string(data) == "null" {
+		f.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &f.Value)
+}
+
+// ApplyTo stores the member in v: the value if given, the zero value if
+// null, and nothing if absent.
+func (f Patch[T]) ApplyTo(v *T) {
+	switch {
+	case f.Null:
+		var zero T
+		*v = zero
+	case f.Set:
+		*v = f.Value
+	}
+}
+
+// ValidationValue lets the validation package check only members that are
+// present, treating null as missing.
+func (f Patch[T]) ValidationValue() (any, bool) {
+	if f.Null {
+		return nil, true
+	}
+	return f.Value, f.Set
+}
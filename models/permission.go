@@ -0,0 +1,123 @@
+package models
+
+import "time"
+
+// Permission names. Routes declare the permission they need and roles are
+// granted permissions through the role_permission table.
+const (
+	PermUserManage           = "user.manage"
+	PermUserImpersonate      = "user.impersonate"
+	PermLockoutManage        = "lockout.manage"
+	PermServiceAccountManage = "service_account.manage"
+	PermPermissionManage     = "permission.manage"
+	PermAuditRead            = "audit.read"
+
+	PermProgramRead   = "program.read"
+	PermProgramCreate = "program.create"
+	PermProgramUpdate = "program.update"
+	PermProgramDelete = "program.delete"
+	// PermProgramManageAny lifts the "only programs you teach" restriction.
+	PermProgramManageAny = "program.manage_any"
+
+	PermLecturerRead   = "lecturer.read"
+	PermLecturerManage = "lecturer.manage"
+
+	PermEnrollmentList         = "enrollment.list"
+	PermEnrollmentRead         = "enrollment.read"
+	PermEnrollmentCreate       = "enrollment.create"
+	PermEnrollmentUpdateStatus = "enrollment.update_status"
+	PermEnrollmentDelete       = "enrollment.delete"
+	// PermEnrollmentReadAny shows every student's enrollments and assessments.
+	PermEnrollmentReadAny = "enrollment.read_any"
+	// PermEnrollmentManageAny allows enrolling and grading in any program.
+	PermEnrollmentManageAny = "enrollment.manage_any"
+
+	PermAssessmentRead  = "assessment.read"
+	PermAssessmentGrade = "assessment.grade"
+
+	PermRPSApprove = "rps.approve"
+)
+
+// PermissionCatalog lists every permission the API knows about.
+var PermissionCatalog = []Permission{
+	{Name: PermUserManage, Description: "Manage user accounts and invitations"},
+	{Name: PermUserImpersonate, Description: "View the API as another user (read-only)"},
+	{Name: PermLockoutManage, Description: "View and clear login lockouts"},
+	{Name: PermServiceAccountManage, Description: "Manage service accounts and API keys"},
+	{Name: PermPermissionManage, Description: "Edit the role to permission mapping"},
+	{Name: PermAuditRead, Description: "View and verify the audit log"},
+	{Name: PermProgramRead, Description: "View programs"},
+	{Name: PermProgramCreate, Description: "Create programs"},
+	{Name: PermProgramUpdate, Description: "Update programs"},
+	{Name: PermProgramDelete, Description: "Delete programs"},
+	{Name: PermProgramManageAny, Description: "Manage programs taught by other lecturers"},
+	{Name: PermLecturerRead, Description: "View lecturers"},
+	{Name: PermLecturerManage, Description: "Create, update and delete lecturers"},
+	{Name: PermEnrollmentList, Description: "List enrollments"},
+	{Name: PermEnrollmentRead, Description: "View enrollments of a student"},
+	{Name: PermEnrollmentCreate, Description: "Create enrollments"},
+	{Name: PermEnrollmentUpdateStatus, Description: "Change enrollment status"},
+	{Name: PermEnrollmentDelete, Description: "Delete enrollments"},
+	{Name: PermEnrollmentReadAny, Description: "View enrollments and assessments of every student"},
+	{Name: PermEnrollmentManageAny, Description: "Enroll and grade in programs taught by others"},
+	{Name: PermAssessmentRead, Description: "View assessments"},
+	{Name: PermAssessmentGrade, Description: "Create, update and delete assessments"},
+	{Name: PermRPSApprove, Description: "Approve RPS (semester learning plans)"},
+}
+
+func IsValidPermission(name string) bool {
+	for _, p := range PermissionCatalog {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	lecturerPermissions = []string{
+		PermProgramRead, PermProgramCreate, PermProgramUpdate, PermLecturerRead,
+		PermEnrollmentList, PermEnrollmentRead, PermEnrollmentCreate, PermEnrollmentUpdateStatus,
+		PermAssessmentRead, PermAssessmentGrade,
+	}
+	studentPermissions = []string{
+		PermProgramRead, PermLecturerRead, PermEnrollmentRead, PermEnrollmentCreate, PermAssessmentRead,
+	}
+	reviewerPermissions = []string{
+		PermProgramRead, PermLecturerRead, PermEnrollmentList, PermEnrollmentRead, PermEnrollmentReadAny, PermAssessmentRead,
+	}
+)
+
+// DefaultRolePermissions seeds role_permission on a fresh database. Admins
+// get every permission; afterwards the table is edited through the API.
+var DefaultRolePermissions = map[string][]string{
+	RoleLecturer:      lecturerPermissions,
+	RoleDosen:         lecturerPermissions,
+	RoleStudent:       studentPermissions,
+	RoleMahasiswa:     studentPermissions,
+	RoleKaprodi:       append(append([]string{}, reviewerPermissions...), PermRPSApprove),
+	RoleTimAkreditasi: append(append([]string{}, reviewerPermissions...), PermAuditRead),
+}
+
+type Permission struct {
+	ID          int       `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+type RolePermission struct {
+	ID         int       `json:"id"`
+	Role       string    `json:"role"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RolePermissionsResponse struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
@@ -3,36 +3,98 @@ package models
 import "time"
 
 type Program struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Code        string    `gorm:"type:varchar(20);uniqueIndex;not null" json:"code"`
-	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
-	Description string    `gorm:"type:text" json:"description"`
-	Credits     int       `gorm:"default:3" json:"credits"`
-	Semester    int       `gorm:"not null" json:"semester"`
-	LecturerID  int       `gorm:"not null" json:"lecturer_id"`
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-}
-
-func (Program) TableName() string {
-	return "program"
+	ID          int       `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Credits     int       `json:"credits"`
+	Semester    int       `json:"semester"`
+	LecturerID  int       `json:"lecturer_id"`
+	IsActive    bool      `json:"is_active"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type CreateProgramRequest struct {
-	Code        string `json:"code"`
-	Name        string `json:"name"`
+	Code        string `json:"code" validate:"required,max=20"`
+	Name        string `json:"name" validate:"required,max=100"`
 	Description string `json:"description"`
-	Credits     int    `json:"credits"`
-	Semester    int    `json:"semester"`
-	LecturerID  int    `json:"lecturer_id"`
+	Credits     int    `json:"credits" validate:"min=1,max=24"`
+	Semester    int    `json:"semester" validate:"min=1,max=14"`
+	LecturerID  int    `json:"lecturer_id" validate:"required"`
 }
 
 type UpdateProgramRequest struct {
-	Code        string `json:"code"`
-	Name        string `json:"name"`
+	Code        string `json:"code" validate:"required,max=20"`
+	Name        string `json:"name" validate:"required,max=100"`
 	Description string `json:"description"`
-	Credits     int    `json:"credits"`
-	Semester    int    `json:"semester"`
+	Credits     int    `json:"credits" validate:"min=1,max=24"`
+	Semester    int    `json:"semester" validate:"min=1,max=14"`
 	IsActive    *bool  `json:"is_active"`
 }
+
+// ProgramSearchHit is a program matching a catalogue search. Highlights are
+// HTML-escaped excerpts with the matched terms wrapped in <mark>.
+type ProgramSearchHit struct {
+	Program
+	Rank       float64           `json:"rank"`
+	Highlights ProgramHighlights `json:"highlights"`
+}
+
+type ProgramHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// FacetCount is how many search matches have Value.
+type FacetCount struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+type ProgramFacets struct {
+	Semester []FacetCount `json:"semester"`
+	Credits  []FacetCount `json:"credits"`
+}
+
+type ProgramSearchResult struct {
+	Hits   []ProgramSearchHit `json:"hits"`
+	Facets ProgramFacets      `json:"facets"`
+}
+
+// Changes reports whether storing r would change p.
+func (r UpdateProgramRequest) Changes(p Program) bool {
+	return r.Code != p.Code || r.Name != p.Name || r.Description != p.Description ||
+		r.Credits != p.Credits || r.Semester != p.Semester || (r.IsActive != nil && *r.IsActive != p.IsActive)
+}
+
+// ProgramPatch is a merge patch for a program. Absent members are left as
+// they are and null clears the description; the lecturer cannot be patched.
+type ProgramPatch struct {
+	Code        Patch[string] `json:"code" validate:"required,max=20"`
+	Name        Patch[string] `json:"name" validate:"required,max=100"`
+	Description Patch[string] `json:"description"`
+	Credits     Patch[int]    `json:"credits" validate:"required,min=1,max=24"`
+	Semester    Patch[int]    `json:"semester" validate:"required,min=1,max=14"`
+	IsActive    Patch[bool]   `json:"is_active" validate:"required"`
+}
+
+// Apply merges the patch into current, giving the full update to store.
+func (p ProgramPatch) Apply(current Program) UpdateProgramRequest {
+	req := UpdateProgramRequest{
+		Code:        current.Code,
+		Name:        current.Name,
+		Description: current.Description,
+		Credits:     current.Credits,
+		Semester:    current.Semester,
+		IsActive:    &current.IsActive,
+	}
+	p.Code.ApplyTo(&req.Code)
+	p.Name.ApplyTo(&req.Name)
+	p.Description.ApplyTo(&req.Description)
+	p.Credits.ApplyTo(&req.Credits)
+	p.Semester.ApplyTo(&req.Semester)
+	p.IsActive.ApplyTo(req.IsActive)
+	return req
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyResources are the route groups an API key can be scoped to. A scope
+// is "<resource>:read", "<resource>:write" or "<resource>:*".
+var APIKeyResources = []string{"users", "lockouts", "programs", "lecturers", "enrollments", "assessments"}
+
+func IsValidAPIKeyScope(scope string) bool {
+	resource, access, ok := strings.Cut(scope, ":")
+	if !ok || (access != "read" && access != "write" && access != "*") {
+		return false
+	}
+	for _, r := range APIKeyResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceAccount is a non-human principal used by integrations. Its role caps
+// what its API keys can reach; the key scopes narrow it further.
+type ServiceAccount struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Role        string    `json:"role"`
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   int       `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type APIKey struct {
+	ID               int        `json:"id"`
+	ServiceAccountID int        `json:"service_account_id"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"`
+	KeyHash          string     `json:"-"`
+	Scopes           []string   `json:"scopes"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	LastUsedIP       string     `json:"last_used_ip"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedBy        int        `json:"created_by"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=255"`
+	Role        string `json:"role" validate:"required,oneof=admin lecturer student kaprodi dosen mahasiswa tim_akreditasi"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Description *string `json:"description" validate:"max=255"`
+	Role        *string `json:"role" validate:"oneof=admin lecturer student kaprodi dosen mahasiswa tim_akreditasi"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse carries the plaintext key, which is shown only once.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -2,38 +2,116 @@ package models
 
 import "time"
 
-type User struct {
-	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username     string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"username"`
-	Email        string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
-	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
-	FullName     string    `gorm:"type:varchar(100)" json:"full_name"`
-	Phone        string    `gorm:"type:varchar(20)" json:"phone"`
-	Role         string    `gorm:"type:varchar(20);not null" json:"role"`
-	IsActive     bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+const (
+	RoleAdmin    = "admin"
+	RoleLecturer = "lecturer"
+	RoleStudent  = "student"
+	RoleKaprodi  = "kaprodi"
+	// Roles of the OBE curriculum design (see structure.txt). What each role
+	// may do is defined by role_permission, not by the name.
+	RoleDosen         = "dosen"
+	RoleMahasiswa     = "mahasiswa"
+	RoleTimAkreditasi = "tim_akreditasi"
+)
+
+// ValidRoles lists the roles that can be assigned to a user account. Keep the
+// oneof rules of the request DTOs in step.
+var ValidRoles = []string{RoleAdmin, RoleLecturer, RoleStudent, RoleKaprodi, RoleDosen, RoleMahasiswa, RoleTimAkreditasi}
+
+func IsValidRole(role string) bool {
+	for _, r := range ValidRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
-func (User) TableName() string {
-	return "user"
+type User struct {
+	ID                 int       `json:"id"`
+	Username           string    `json:"username"`
+	Email              string    `json:"email"`
+	PasswordHash       string    `json:"-"`
+	FullName           string    `json:"full_name"`
+	Phone              string    `json:"phone"`
+	Role               string    `json:"role"`
+	IsActive           bool      `json:"is_active"`
+	MustChangePassword bool      `json:"must_change_password"`
+	TOTPSecret         string    `json:"-"`
+	TOTPEnabled        bool      `json:"totp_enabled"`
+	TOTPLastStep       int64     `json:"-"`
+	OIDCIssuer         *string   `json:"-"`
+	OIDCSubject        *string   `json:"-"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-	Phone    string `json:"phone"`
-	Role     string `json:"role"`
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required"`
+	FullName string `json:"full_name" validate:"max=100"`
+	Phone    string `json:"phone" validate:"max=20"`
+}
+
+// CreateUserRequest is used by admins to provision accounts with any role.
+type CreateUserRequest struct {
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required"`
+	FullName string `json:"full_name" validate:"max=100"`
+	Phone    string `json:"phone" validate:"max=20"`
+	Role     string `json:"role" validate:"required,oneof=admin lecturer student kaprodi dosen mahasiswa tim_akreditasi"`
+}
+
+// UpdateUserRequest only changes the fields that are present in the body.
+type UpdateUserRequest struct {
+	Username *string `json:"username" validate:"notblank,max=100"`
+	Email    *string `json:"email" validate:"notblank,email,max=100"`
+	FullName *string `json:"full_name" validate:"max=100"`
+	Phone    *string `json:"phone" validate:"max=20"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin lecturer student kaprodi dosen mahasiswa tim_akreditasi"`
+}
+
+type UpdateUserStatusRequest struct {
+	IsActive *bool `json:"is_active" validate:"required"`
+}
+
+type UpdateProfileRequest struct {
+	FullName *string `json:"full_name" validate:"max=100"`
+	Phone    *string `json:"phone" validate:"max=20"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// MFAChallengeResponse is returned by Login instead of tokens when the account
+// has two-factor authentication enabled.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type LoginTwoFactorRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
 }
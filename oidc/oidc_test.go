@@ -0,0 +1,313 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"mbkm-api/oidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	clientID     = "mbkm-api"
+	clientSecret = "s3cret"
+	redirectURL  = "http://localhost:8080/api/v1/auth/oidc/callback"
+	keyID        = "test-key"
+)
+
+// mockProvider is a minimal identity provider: discovery, an authorization
+// endpoint that approves every request, a token endpoint that checks the
+// client and PKCE verifier, and the JWKS its ID tokens are signed with.
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// claims adjusts the ID token before it is signed.
+	claims func(jwt.MapClaims)
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// authorization is what the authorization endpoint remembers about a code.
+type authorization struct {
+	nonce, challenge, redirectURI string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, codes: map[string]authorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", m.jwks)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 m.URL,
+		"authorization_endpoint": m.URL + "/authorize",
+		"token_endpoint":         m.URL + "/token",
+		"jwks_uri":               m.URL + "/jwks",
+	})
+}
+
+func (m *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != clientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	m.mu.Lock()
+	m.codes[code] = authorization{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	m.mu.Unlock()
+
+	back := url.Values{"code": {code}, "state": {q.Get("state")}}
+	http.Redirect(w, r, q.Get("redirect_uri")+"?"+back.Encode(), http.StatusFound)
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != clientID || secret != clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, found := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	if r.PostFormValue("grant_type") != "authorization_code" || !found ||
+		r.PostFormValue("redirect_uri") != auth.redirectURI ||
+		oidc.PKCEChallenge(r.PostFormValue("code_verifier")) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.URL,
+		"aud":            clientID,
+		"sub":            "mhs-2021001",
+		"email":          "budi@student.univ.ac.id",
+		"email_verified": true,
+		"groups":         []string{"mahasiswa"},
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	if m.claims != nil {
+		m.claims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   b64(m.key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// authorize plays the browser: it follows the authorization URL to the
+// callback redirect and returns the code it carries.
+func authorize(t *testing.T, p *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, oidc.PKCEChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Query().Get("state"); got != "state-1" {
+		t.Fatalf("callback state = %q, want state-1", got)
+	}
+	return callback.Query().Get("code")
+}
+
+// login runs the whole flow up to the raw ID token.
+func login(t *testing.T, p *oidc.Provider, nonce, verifier string) (string, error) {
+	t.Helper()
+	return p.Exchange(context.Background(), authorize(t, p, nonce, verifier), verifier)
+}
+
+func newProvider(m *mockProvider) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{IssuerURL: m.URL, ClientID: clientID, ClientSecret: clientSecret, RedirectURL: redirectURL})
+}
+
+func TestLogin(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	raw, err := login(t, p, "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	claims, err := p.VerifyIDToken(context.Background(), raw, "nonce-1")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if got := claims.String("sub"); got != "mhs-2021001" {
+		t.Errorf("sub = %q", got)
+	}
+	if got := claims.String("email"); got != "budi@student.univ.ac.id" || !claims.Bool("email_verified") {
+		t.Errorf("email = %q, verified = %v", got, claims.Bool("email_verified"))
+	}
+	if got := claims.Strings("groups"); len(got) != 1 || got[0] != "mahasiswa" {
+		t.Errorf("groups = %v", got)
+	}
+	if issuer, _ := p.Issuer(context.Background()); issuer != m.URL {
+		t.Errorf("issuer = %q, want %q", issuer, m.URL)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	code := authorize(t, p, "nonce-1", "verifier-1")
+	_, err := p.Exchange(context.Background(), code, "someone-elses-verifier")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("err = %v, want invalid_grant", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims func(jwt.MapClaims)
+		nonce  string
+		want   string
+	}{
+		{name: "nonce mismatch", nonce: "nonce-of-another-login", want: "nonce mismatch"},
+		{
+			name:   "expired token",
+			claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			want:   "expired",
+		},
+		{
+			name:   "wrong audience",
+			claims: func(c jwt.MapClaims) { c["aud"] = "another-client" },
+			want:   "audience",
+		},
+		{
+			name:   "wrong issuer",
+			claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+			want:   "issuer",
+		},
+		{
+			name:   "several audiences without azp",
+			claims: func(c jwt.MapClaims) { c["aud"] = []string{clientID, "another-client"} },
+			want:   "azp mismatch",
+		},
+		{
+			name:   "no subject",
+			claims: func(c jwt.MapClaims) { delete(c, "sub") },
+			want:   "no subject",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			m.claims = tc.claims
+			p := newProvider(m)
+
+			raw, err := login(t, p, "nonce-1", "verifier-1")
+			if err != nil {
+				t.Fatalf("exchange: %v", err)
+			}
+			nonce := "nonce-1"
+			if tc.nonce != "" {
+				nonce = tc.nonce
+			}
+
+			_, err = p.VerifyIDToken(context.Background(), raw, nonce)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignSignature(t *testing.T) {
+	m := newMockProvider(t)
+	p := newProvider(m)
+
+	// Same kid, different key: only the JWKS key may sign.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": m.URL, "aud": clientID, "sub": "mhs-2021001", "nonce": "nonce-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = keyID
+	raw, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.VerifyIDToken(context.Background(), raw, "nonce-1"); err == nil {
+		t.Fatal("token signed by a foreign key was accepted")
+	}
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"context"
+	"mbkm-api/repository"
+	"sync"
+	"time"
+)
+
+// PermissionSet is the set of permissions granted to a principal.
+type PermissionSet map[string]bool
+
+func (s PermissionSet) Has(permission string) bool {
+	return s[permission]
+}
+
+// Resolver maps roles to permissions. The whole role_permission table is
+// small, so it is loaded at once and cached for ttl; Invalidate drops the
+// cache after an edit on this instance, other instances catch up after ttl.
+type Resolver struct {
+	principals repository.PrincipalRepository
+	ttl        time.Duration
+
+	mu       sync.RWMutex
+	roles    map[string]PermissionSet
+	loadedAt time.Time
+}
+
+func NewResolver(principals repository.PrincipalRepository, ttl time.Duration) *Resolver {
+	return &Resolver{principals: principals, ttl: ttl}
+}
+
+func (r *Resolver) Permissions(ctx context.Context, role string) (PermissionSet, error) {
+	r.mu.RLock()
+	if r.roles != nil && time.Since(r.loadedAt) < r.ttl {
+		set := r.roles[role]
+		r.mu.RUnlock()
+		return set, nil
+	}
+	r.mu.RUnlock()
+
+	roles, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return roles[role], nil
+}
+
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	r.roles = nil
+	r.mu.Unlock()
+}
+
+func (r *Resolver) load(ctx context.Context) (map[string]PermissionSet, error) {
+	granted, err := r.principals.RolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := map[string]PermissionSet{}
+	for role, permissions := range granted {
+		roles[role] = PermissionSet{}
+		for _, permission := range permissions {
+			roles[role][permission] = true
+		}
+	}
+
+	r.mu.Lock()
+	r.roles = roles
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	return roles, nil
+}
@@ -0,0 +1,201 @@
+// Package policy decides whether the authenticated principal may access a
+// specific resource. RequirePermission answers "may this principal call the
+// route"; the checks here answer "may this user touch this row".
+package policy
+
+import (
+	"context"
+	"errors"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrForbidden = errors.New("access to this resource is not allowed")
+	ErrNotFound  = errors.New("resource not found")
+)
+
+// Actor is the principal set by AuthMiddleware. UserID is 0 for API keys.
+type Actor struct {
+	UserID      int
+	Role        string
+	Permissions PermissionSet
+}
+
+func ActorFrom(c *fiber.Ctx) Actor {
+	userID, _ := c.Locals("userID").(int)
+	role, _ := c.Locals("role").(string)
+	perms, _ := c.Locals("permissions").(PermissionSet)
+	return Actor{UserID: userID, Role: role, Permissions: perms}
+}
+
+func (a Actor) Can(permission string) bool {
+	return a.Permissions.Has(permission)
+}
+
+// SeesEverything reports whether the actor may read all academic records.
+func (a Actor) SeesEverything() bool {
+	return a.Can(models.PermEnrollmentReadAny)
+}
+
+// IsSelf reports whether studentID is the actor's own user account.
+func (a Actor) IsSelf(studentID int) bool {
+	return a.UserID != 0 && a.UserID == studentID
+}
+
+type Policy struct {
+	owners repository.OwnershipRepository
+}
+
+func New(owners repository.OwnershipRepository) *Policy {
+	return &Policy{owners: owners}
+}
+
+// LecturerID returns the lecturer record linked to the actor's user, or 0.
+func (p *Policy) LecturerID(ctx context.Context, actor Actor) (int, error) {
+	if actor.UserID == 0 {
+		return 0, nil
+	}
+
+	return p.owners.LecturerIDForUser(ctx, actor.UserID)
+}
+
+// CanAssignLecturer allows program.manage_any holders to pick any lecturer
+// and everyone else only themselves, e.g. as the lecturer of a new program.
+func (p *Policy) CanAssignLecturer(ctx context.Context, actor Actor, lecturerID int) error {
+	if actor.Can(models.PermProgramManageAny) {
+		return nil
+	}
+
+	own, err := p.LecturerID(ctx, actor)
+	if err != nil {
+		return err
+	}
+	if own == 0 || own != lecturerID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanManageLecturer allows program.manage_any holders and the lecturer whose
+// record it is.
+func (p *Policy) CanManageLecturer(ctx context.Context, actor Actor, lecturerID int) error {
+	if actor.Can(models.PermProgramManageAny) {
+		return nil
+	}
+
+	own, err := p.LecturerID(ctx, actor)
+	if err != nil {
+		return err
+	}
+	if own == 0 || own != lecturerID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanManageProgram allows program.manage_any holders and the lecturer
+// teaching the program.
+func (p *Policy) CanManageProgram(ctx context.Context, actor Actor, programID int) error {
+	lecturerUserID, err := p.owners.ProgramLecturerUser(ctx, programID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	return programAccess(actor, lecturerUserID, models.PermProgramManageAny)
+}
+
+// CanViewStudent allows students to see their own records and lecturers to
+// see a student at all; lecturer listings are then narrowed to their own
+// programs by the caller.
+func (p *Policy) CanViewStudent(ctx context.Context, actor Actor, studentID int) error {
+	if actor.SeesEverything() || actor.IsSelf(studentID) {
+		return nil
+	}
+
+	lecturerID, err := p.LecturerID(ctx, actor)
+	if err != nil {
+		return err
+	}
+	if lecturerID == 0 {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanEnroll allows students to enroll themselves, lecturers to enroll
+// students in programs they teach and enrollment.manage_any holders anything.
+func (p *Policy) CanEnroll(ctx context.Context, actor Actor, studentID, programID int) error {
+	if actor.Can(models.PermEnrollmentManageAny) || actor.IsSelf(studentID) {
+		return nil
+	}
+
+	lecturerUserID, err := p.owners.ProgramLecturerUser(ctx, programID)
+	if err != nil {
+		return notFound(err)
+	}
+	return programAccess(actor, lecturerUserID, models.PermEnrollmentManageAny)
+}
+
+// CanViewEnrollment allows the enrolled student, the program's lecturer and
+// enrollment.read_any holders.
+func (p *Policy) CanViewEnrollment(ctx context.Context, actor Actor, enrollmentID int) error {
+	studentID, lecturerUserID, err := p.enrollmentOwners(ctx, enrollmentID)
+	if err != nil {
+		return err
+	}
+
+	if actor.IsSelf(studentID) {
+		return nil
+	}
+	return programAccess(actor, lecturerUserID, models.PermEnrollmentReadAny)
+}
+
+// CanManageEnrollment allows the lecturer of the enrolled program and
+// enrollment.manage_any holders to change an enrollment or grade it.
+func (p *Policy) CanManageEnrollment(ctx context.Context, actor Actor, enrollmentID int) error {
+	_, lecturerUserID, err := p.enrollmentOwners(ctx, enrollmentID)
+	if err != nil {
+		return err
+	}
+	return programAccess(actor, lecturerUserID, models.PermEnrollmentManageAny)
+}
+
+// CanManageAssessment applies CanManageEnrollment to an assessment's program.
+func (p *Policy) CanManageAssessment(ctx context.Context, actor Actor, assessmentID int) error {
+	lecturerUserID, err := p.owners.AssessmentLecturerUser(ctx, assessmentID)
+	if err != nil {
+		return notFound(err)
+	}
+	return programAccess(actor, lecturerUserID, models.PermEnrollmentManageAny)
+}
+
+func (p *Policy) enrollmentOwners(ctx context.Context, enrollmentID int) (int, *int, error) {
+	studentID, lecturerUserID, err := p.owners.EnrollmentOwners(ctx, enrollmentID)
+	if err != nil {
+		return 0, nil, notFound(err)
+	}
+	return studentID, lecturerUserID, nil
+}
+
+// programAccess allows holders of the bypass permission and the lecturer
+// whose user teaches the program.
+func programAccess(actor Actor, lecturerUserID *int, bypass string) error {
+	if actor.Can(bypass) {
+		return nil
+	}
+	if lecturerUserID != nil && actor.IsSelf(*lecturerUserID) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgAssessments struct {
+	db *database.Database
+}
+
+const assessmentColumns = `id, enrollment_id, student_id, program_id, category, score, COALESCE(max_score, 0), weight, COALESCE(notes, ''), version, created_at, updated_at`
+
+func scanAssessment(row pgx.Row, a *models.Assessment) error {
+	return row.Scan(&a.ID, &a.EnrollmentID, &a.StudentID, &a.ProgramID, &a.Category, &a.Score, &a.MaxScore, &a.Weight, &a.Notes, &a.Version, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func (r *pgAssessments) ListByEnrollment(ctx context.Context, enrollmentID int) ([]models.Assessment, error) {
+	query := `SELECT ` + assessmentColumns + ` FROM "assessment" WHERE enrollment_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Conn(ctx).Query(ctx, query, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assessments := []models.Assessment{}
+	for rows.Next() {
+		var a models.Assessment
+		if err := scanAssessment(rows, &a); err != nil {
+			return nil, err
+		}
+		assessments = append(assessments, a)
+	}
+	return assessments, rows.Err()
+}
+
+func (r *pgAssessments) Get(ctx context.Context, id int) (models.Assessment, error) {
+	var a models.Assessment
+	err := scanAssessment(r.db.Conn(ctx).QueryRow(ctx, `SELECT `+assessmentColumns+` FROM "assessment" WHERE id = $1`, id), &a)
+	return a, err
+}
+
+func (r *pgAssessments) Create(ctx context.Context, req models.CreateAssessmentRequest) (int, error) {
+	return insert(ctx, r.db, "assessment", func(tx pgx.Tx) (int, error) {
+		var id int
+		query := `
+			INSERT INTO "assessment" (enrollment_id, student_id, program_id, category, score, max_score, weight, notes)
+			SELECT id, student_id, program_id, $2, $3, $4, $5, $6 FROM "enrollment" WHERE id = $1
+			RETURNING id
+		`
+		err := tx.QueryRow(ctx, query, req.EnrollmentID, req.Category, req.Score, req.MaxScore, req.Weight, req.Notes).Scan(&id)
+		return id, err
+	})
+}
+
+func (r *pgAssessments) Update(ctx context.Context, id int, req models.UpdateAssessmentRequest) (int, error) {
+	var version int
+	err := mutate(ctx, r.db, models.AuditActionUpdate, "assessment", id, func(tx pgx.Tx) error {
+		query := `UPDATE "assessment" SET score = $1, max_score = $2, weight = $3, notes = $4, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $5 RETURNING version`
+		return tx.QueryRow(ctx, query, req.Score, req.MaxScore, req.Weight, req.Notes, id).Scan(&version)
+	})
+	return version, err
+}
+
+func (r *pgAssessments) Delete(ctx context.Context, id int) error {
+	return remove(ctx, r.db, "assessment", id)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgEnrollments struct {
+	db *database.Database
+}
+
+const enrollmentColumns = `id, student_id, program_id, status, enrolled_at, version, created_at, updated_at`
+
+var enrollmentSorts = sortSpec[models.Enrollment]{
+	fields: map[string]sortField[models.Enrollment]{
+		"created_at":  {"created_at", sortTime, func(e models.Enrollment) any { return e.CreatedAt }},
+		"enrolled_at": {"enrolled_at", sortTime, func(e models.Enrollment) any { return e.EnrolledAt }},
+		"status":      {"status", sortString, func(e models.Enrollment) any { return e.Status }},
+	},
+	def: "-created_at",
+	id:  func(e models.Enrollment) int { return e.ID },
+}
+
+func scanEnrollment(row pgx.Row, e *models.Enrollment) error {
+	return row.Scan(&e.ID, &e.StudentID, &e.ProgramID, &e.Status, &e.EnrolledAt, &e.Version, &e.CreatedAt, &e.UpdatedAt)
+}
+
+func (r *pgEnrollments) List(ctx context.Context, filter EnrollmentFilter, opts ListOptions) (Page[models.Enrollment], error) {
+	where := []string{}
+	args := []any{}
+	if filter.StudentID != nil {
+		args = append(args, *filter.StudentID)
+		where = append(where, "student_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.ProgramID != nil {
+		args = append(args, *filter.ProgramID)
+		where = append(where, "program_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where = append(where, "status = $"+strconv.Itoa(len(args)))
+	}
+	if filter.TaughtBy != nil {
+		args = append(args, *filter.TaughtBy)
+		where = append(where, `program_id IN (SELECT p.id FROM "program" p JOIN "lecturer" l ON l.id = p.lecturer_id WHERE l.user_id = $`+strconv.Itoa(len(args))+`)`)
+	}
+
+	return pgList(ctx, r.db.Conn(ctx), "enrollment", enrollmentColumns, where, args, enrollmentSorts, opts, scanEnrollment)
+}
+
+func (r *pgEnrollments) Get(ctx context.Context, id int) (models.Enrollment, error) {
+	var e models.Enrollment
+	err := scanEnrollment(r.db.Conn(ctx).QueryRow(ctx, `SELECT `+enrollmentColumns+` FROM "enrollment" WHERE id = $1`, id), &e)
+	return e, err
+}
+
+func (r *pgEnrollments) Create(ctx context.Context, req models.CreateEnrollmentRequest) (int, error) {
+	return insert(ctx, r.db, "enrollment", func(tx pgx.Tx) (int, error) {
+		var id int
+		err := tx.QueryRow(ctx, `INSERT INTO "enrollment" (student_id, program_id) VALUES ($1, $2) RETURNING id`, req.StudentID, req.ProgramID).Scan(&id)
+		return id, err
+	})
+}
+
+func (r *pgEnrollments) UpdateStatus(ctx context.Context, id int, status string) (int, error) {
+	var version int
+	err := mutate(ctx, r.db, models.AuditActionUpdate, "enrollment", id, func(tx pgx.Tx) error {
+		query := `UPDATE "enrollment" SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING version`
+		return tx.QueryRow(ctx, query, status, id).Scan(&version)
+	})
+	return version, err
+}
+
+func (r *pgEnrollments) Delete(ctx context.Context, id int) error {
+	return remove(ctx, r.db, "enrollment", id)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgInvitations struct {
+	db *database.Database
+}
+
+const invitationColumns = `id, email, role, invited_by, expires_at, created_at, updated_at`
+
+func scanInvitation(row pgx.Row, i *models.Invitation) error {
+	return row.Scan(&i.ID, &i.Email, &i.Role, &i.InvitedBy, &i.ExpiresAt, &i.CreatedAt, &i.UpdatedAt)
+}
+
+func (r *pgInvitations) ListPending(ctx context.Context) ([]models.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM "user_invitation" WHERE accepted_at IS NULL AND revoked_at IS NULL ORDER BY created_at DESC`
+	rows, err := r.db.Conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var i models.Invitation
+		if err := scanInvitation(rows, &i); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	return invitations, rows.Err()
+}
+
+func (r *pgInvitations) Create(ctx context.Context, inv models.Invitation) (int, error) {
+	var id int
+	err := pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE "user_invitation" SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE email = $1 AND accepted_at IS NULL AND revoked_at IS NULL`, inv.Email)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO "user_invitation" (email, role, token_hash, invited_by, expires_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+			RETURNING id
+		`
+		return tx.QueryRow(ctx, query, inv.Email, inv.Role, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt).Scan(&id)
+	})
+	return id, err
+}
+
+func (r *pgInvitations) Revoke(ctx context.Context, id int) error {
+	query := `UPDATE "user_invitation" SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+	result, err := r.db.Conn(ctx).Exec(ctx, query, id)
+	if err == nil && result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return err
+}
+
+func (r *pgInvitations) Pending(ctx context.Context, hash string) (models.Invitation, error) {
+	var i models.Invitation
+	query := `
+		SELECT ` + invitationColumns + ` FROM "user_invitation"
+		WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		FOR UPDATE
+	`
+	err := scanInvitation(r.db.Conn(ctx).QueryRow(ctx, query, hash), &i)
+	return i, err
+}
+
+func (r *pgInvitations) Accept(ctx context.Context, id, userID int) error {
+	_, err := r.db.Conn(ctx).Exec(ctx, `UPDATE "user_invitation" SET accepted_at = CURRENT_TIMESTAMP, accepted_user_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, userID, id)
+	return err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgLecturers struct {
+	db *database.Database
+}
+
+const lecturerColumns = `id, user_id, nidn, full_name, COALESCE(phone, ''), COALESCE(department, ''), is_active, version, created_at, updated_at`
+
+func scanLecturer(row pgx.Row, l *models.Lecturer) error {
+	return row.Scan(&l.ID, &l.UserID, &l.NIDN, &l.FullName, &l.Phone, &l.Department, &l.IsActive, &l.Version, &l.CreatedAt, &l.UpdatedAt)
+}
+
+var lecturerSorts = sortSpec[models.Lecturer]{
+	fields: map[string]sortField[models.Lecturer]{
+		"full_name":  {"full_name", sortString, func(l models.Lecturer) any { return l.FullName }},
+		"nidn":       {"nidn", sortString, func(l models.Lecturer) any { return l.NIDN }},
+		"created_at": {"created_at", sortTime, func(l models.Lecturer) any { return l.CreatedAt }},
+	},
+	def: "full_name",
+	id:  func(l models.Lecturer) int { return l.ID },
+}
+
+func (r *pgLecturers) List(ctx context.Context, filter LecturerFilter, opts ListOptions) (Page[models.Lecturer], error) {
+	where := []string{}
+	args := []any{}
+	if filter.Department != "" {
+		args = append(args, filter.Department)
+		where = append(where, "department = $1")
+	}
+
+	return pgList(ctx, r.db.Conn(ctx), "lecturer", lecturerColumns, where, args, lecturerSorts, opts, scanLecturer)
+}
+
+func (r *pgLecturers) Get(ctx context.Context, id int) (models.Lecturer, error) {
+	var l models.Lecturer
+	err := scanLecturer(r.db.Conn(ctx).QueryRow(ctx, `SELECT `+lecturerColumns+` FROM "lecturer" WHERE id = $1`, id), &l)
+	return l, err
+}
+
+func (r *pgLecturers) Create(ctx context.Context, req models.CreateLecturerRequest) (int, error) {
+	return insert(ctx, r.db, "lecturer", func(tx pgx.Tx) (int, error) {
+		var id int
+		query := `INSERT INTO "lecturer" (user_id, nidn, full_name, phone, department, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
+		err := tx.QueryRow(ctx, query, req.UserID, req.NIDN, req.FullName, req.Phone, req.Department).Scan(&id)
+		return id, err
+	})
+}
+
+func (r *pgLecturers) Update(ctx context.Context, id int, req models.UpdateLecturerRequest) (int, error) {
+	var version int
+	err := mutate(ctx, r.db, models.AuditActionUpdate, "lecturer", id, func(tx pgx.Tx) error {
+		query := `UPDATE "lecturer" SET nidn = $1, full_name = $2, phone = NULLIF($3, ''), department = NULLIF($4, ''), is_active = COALESCE($5, is_active), version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $6 RETURNING version`
+		return tx.QueryRow(ctx, query, req.NIDN, req.FullName, req.Phone, req.Department, req.IsActive, id).Scan(&version)
+	})
+	return version, err
+}
+
+func (r *pgLecturers) Delete(ctx context.Context, id int) error {
+	return remove(ctx, r.db, "lecturer", id)
+}
+
+func (r *pgLecturers) IsLecturerUser(ctx context.Context, userID int) (bool, error) {
+	var exists bool
+	err := r.db.Conn(ctx).QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM "user" WHERE id = $1 AND role = $2)`, userID, models.RoleLecturer).Scan(&exists)
+	return exists, err
+}
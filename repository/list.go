@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mbkm-api/database"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ListOptions selects one page of a list. Pages are addressed either by
+// Cursor, taken from the previous page, or by a 1-based Page number.
+type ListOptions struct {
+	Limit  int
+	Page   int
+	Cursor string
+	// Sort names a whitelisted field, prefixed with "-" for descending
+	// order. Empty uses the list's default.
+	Sort string
+}
+
+type Page[T any] struct {
+	Items []T
+	// Total counts all matches of the filter, not only this page.
+	Total int
+	// NextCursor continues after the last item; empty on the last page.
+	NextCursor string
+}
+
+// ListOptionsError reports a sort field or cursor the list does not accept.
+type ListOptionsError struct {
+	Detail string
+}
+
+func (e *ListOptionsError) Error() string {
+	return "invalid list options: " + e.Detail
+}
+
+type sortKind int
+
+const (
+	sortString sortKind = iota
+	sortInt
+	sortTime
+)
+
+type sortField[T any] struct {
+	column string
+	kind   sortKind
+	value  func(T) any
+}
+
+// sortSpec is the sort whitelist of one list. Ties are broken by id so the
+// order, and with it every cursor, is stable.
+type sortSpec[T any] struct {
+	fields map[string]sortField[T]
+	def    string
+	id     func(T) int
+}
+
+// listQuery is ListOptions checked against a sortSpec.
+type listQuery[T any] struct {
+	sort   string
+	field  sortField[T]
+	desc   bool
+	limit  int
+	offset int
+	// after is the (value, id) position the cursor points behind.
+	after   any
+	afterID int
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func (s sortSpec[T]) resolve(opts ListOptions) (listQuery[T], error) {
+	q := listQuery[T]{sort: opts.Sort, limit: opts.Limit}
+	if q.sort == "" {
+		q.sort = s.def
+	}
+	if q.limit < 1 || q.limit > MaxLimit {
+		q.limit = DefaultLimit
+	}
+
+	name := strings.TrimPrefix(q.sort, "-")
+	field, ok := s.fields[name]
+	if !ok {
+		names := make([]string, 0, len(s.fields))
+		for n := range s.fields {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return q, &ListOptionsError{Detail: fmt.Sprintf("unknown sort field %q, use one of %s", name, strings.Join(names, ", "))}
+	}
+	q.field, q.desc = field, strings.HasPrefix(q.sort, "-")
+
+	if opts.Cursor == "" {
+		if opts.Page > 1 {
+			q.offset = (opts.Page - 1) * q.limit
+		}
+		return q, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	var c cursor
+	if err == nil {
+		err = json.Unmarshal(raw, &c)
+	}
+	if err != nil {
+		return q, &ListOptionsError{Detail: "cursor is malformed"}
+	}
+	if c.Sort != q.sort {
+		return q, &ListOptionsError{Detail: "cursor was issued for a different sort"}
+	}
+	if q.after, err = parseSortValue(field.kind, c.Value); err != nil {
+		return q, &ListOptionsError{Detail: "cursor is malformed"}
+	}
+	q.afterID = c.ID
+	return q, nil
+}
+
+// rankedPage checks opts for a list ordered by relevance, which has no sort
+// choice and no cursors, and returns its limit and offset.
+func rankedPage(opts ListOptions) (limit, offset int, err error) {
+	if opts.Sort != "" {
+		return 0, 0, &ListOptionsError{Detail: "results are ordered by relevance and cannot be sorted"}
+	}
+	if opts.Cursor != "" {
+		return 0, 0, &ListOptionsError{Detail: "results are paged by page number, not cursor"}
+	}
+	limit = opts.Limit
+	if limit < 1 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+	if opts.Page > 1 {
+		offset = (opts.Page - 1) * limit
+	}
+	return limit, offset, nil
+}
+
+// page trims the limit+1 rows fetched to the page and sets NextCursor when
+// the extra row shows there is more.
+func (s sortSpec[T]) page(q listQuery[T], items []T, total int) Page[T] {
+	p := Page[T]{Items: items, Total: total}
+	if len(items) <= q.limit {
+		return p
+	}
+
+	p.Items = items[:q.limit]
+	last := p.Items[q.limit-1]
+	raw, _ := json.Marshal(cursor{Sort: q.sort, Value: formatSortValue(q.field.value(last)), ID: s.id(last)})
+	p.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	return p
+}
+
+func formatSortValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseSortValue(kind sortKind, s string) (any, error) {
+	switch kind {
+	case sortTime:
+		return time.Parse(time.RFC3339Nano, s)
+	case sortInt:
+		return strconv.Atoi(s)
+	default:
+		return s, nil
+	}
+}
+
+// pgList runs the count and the page query for columns of table filtered by
+// where, whose placeholders are bound to args.
+func pgList[T any](ctx context.Context, q database.Querier, table, columns string, where []string, args []any, spec sortSpec[T], opts ListOptions, scan func(pgx.Row, *T) error) (Page[T], error) {
+	lq, err := spec.resolve(opts)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	if len(where) == 0 {
+		where = []string{"1 = 1"}
+	}
+
+	var total int
+	if err := q.QueryRow(ctx, `SELECT COUNT(*) FROM "`+table+`" WHERE `+strings.Join(where, " AND "), args...).Scan(&total); err != nil {
+		return Page[T]{}, err
+	}
+
+	cmp, dir := ">", "ASC"
+	if lq.desc {
+		cmp, dir = "<", "DESC"
+	}
+	if lq.after != nil {
+		args = append(args, lq.after, lq.afterID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d, $%d)", lq.field.column, cmp, len(args)-1, len(args)))
+	}
+
+	args = append(args, lq.limit+1, lq.offset)
+	query := fmt.Sprintf(`SELECT %s FROM "%s" WHERE %s ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d`,
+		columns, table, strings.Join(where, " AND "), lq.field.column, dir, dir, len(args)-1, len(args))
+
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		var item T
+		if err := scan(rows, &item); err != nil {
+			return Page[T]{}, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	return spec.page(lq, items, total), nil
+}
+
+// memList orders, pages and counts items that already match the filter.
+func memList[T any](items []T, spec sortSpec[T], opts ListOptions) (Page[T], error) {
+	lq, err := spec.resolve(opts)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	// compare orders by (value, id), ascending.
+	compare := func(a any, aID int, b any, bID int) int {
+		if c := compareSortValues(a, b); c != 0 {
+			return c
+		}
+		return aID - bID
+	}
+	sort.Slice(items, func(i, j int) bool {
+		c := compare(lq.field.value(items[i]), spec.id(items[i]), lq.field.value(items[j]), spec.id(items[j]))
+		if lq.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	total := len(items)
+	if lq.after != nil {
+		rest := []T{}
+		for _, item := range items {
+			c := compare(lq.field.value(item), spec.id(item), lq.after, lq.afterID)
+			if (lq.desc && c < 0) || (!lq.desc && c > 0) {
+				rest = append(rest, item)
+			}
+		}
+		items = rest
+	}
+
+	if lq.offset >= len(items) {
+		items = []T{}
+	} else {
+		items = items[lq.offset:]
+	}
+	if len(items) > lq.limit+1 {
+		items = items[:lq.limit+1]
+	}
+
+	return spec.page(lq, items, total), nil
+}
+
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case int:
+		return a - b.(int)
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
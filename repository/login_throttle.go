@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgLoginThrottles struct {
+	db *database.Database
+}
+
+func (r *pgLoginThrottles) List(ctx context.Context, lockedOnly bool) ([]models.LoginThrottle, error) {
+	query := `SELECT id, kind, value, failed_attempts, last_failed_at, locked_until, created_at, updated_at FROM "login_throttle"`
+	if lockedOnly {
+		query += ` WHERE locked_until > CURRENT_TIMESTAMP`
+	}
+	query += ` ORDER BY last_failed_at DESC`
+
+	rows, err := r.db.Conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	throttles := []models.LoginThrottle{}
+	for rows.Next() {
+		var l models.LoginThrottle
+		if err := rows.Scan(&l.ID, &l.Kind, &l.Value, &l.FailedAttempts, &l.LastFailedAt, &l.LockedUntil, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		throttles = append(throttles, l)
+	}
+	return throttles, rows.Err()
+}
+
+func (r *pgLoginThrottles) LockedUntil(ctx context.Context, email, ip string) (*time.Time, error) {
+	var lockedUntil *time.Time
+	query := `
+		SELECT MAX(locked_until) FROM "login_throttle"
+		WHERE ((kind = $1 AND value = $2) OR (kind = $3 AND value = $4)) AND locked_until > CURRENT_TIMESTAMP
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, models.ThrottleKindEmail, email, models.ThrottleKindIP, ip).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+func (r *pgLoginThrottles) Fail(ctx context.Context, kind, value string, since time.Time) (int, error) {
+	var attempts int
+	query := `
+		INSERT INTO "login_throttle" (kind, value, failed_attempts, last_failed_at, created_at, updated_at)
+		VALUES ($1, $2, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (kind, value) DO UPDATE SET
+			failed_attempts = CASE WHEN "login_throttle".last_failed_at < $3 THEN 1 ELSE "login_throttle".failed_attempts + 1 END,
+			last_failed_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING failed_attempts
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, kind, value, since).Scan(&attempts)
+	return attempts, err
+}
+
+func (r *pgLoginThrottles) Lock(ctx context.Context, kind, value string, until time.Time) error {
+	_, err := r.db.Conn(ctx).Exec(ctx, `UPDATE "login_throttle" SET locked_until = $1 WHERE kind = $2 AND value = $3`, until, kind, value)
+	return err
+}
+
+func (r *pgLoginThrottles) Clear(ctx context.Context, kind, value string) error {
+	_, err := r.db.Conn(ctx).Exec(ctx, `DELETE FROM "login_throttle" WHERE kind = $1 AND value = $2`, kind, value)
+	return err
+}
+
+func (r *pgLoginThrottles) Delete(ctx context.Context, id int) error {
+	result, err := r.db.Conn(ctx).Exec(ctx, `DELETE FROM "login_throttle" WHERE id = $1`, id)
+	if err == nil && result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return err
+}
@@ -0,0 +1,834 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Memory keeps the academic records and user accounts in maps and enforces
+// the same unique, foreign key and check constraints as the schema. Changes
+// are not audited. Roles start with their default permissions.
+type Memory struct {
+	// tx serializes units of work; mu guards the maps.
+	tx            sync.Mutex
+	mu            sync.Mutex
+	nextID        int
+	users         map[int]models.User
+	programs      map[int]models.Program
+	lecturers     map[int]models.Lecturer
+	enrollments   map[int]models.Enrollment
+	assessments   map[int]models.Assessment
+	sessions      map[int]models.Session
+	recoveryCodes map[int]models.RecoveryCode
+	resetTokens   map[int]models.PasswordResetToken
+	invitations   map[int]models.Invitation
+	throttles     map[int]models.LoginThrottle
+	oidcRequests  map[int]models.OIDCAuthRequest
+	// apiKeys maps prefixes to keys.
+	apiKeys map[string]APIKeyCredential
+	roles   map[string][]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:         map[int]models.User{},
+		programs:      map[int]models.Program{},
+		lecturers:     map[int]models.Lecturer{},
+		enrollments:   map[int]models.Enrollment{},
+		assessments:   map[int]models.Assessment{},
+		sessions:      map[int]models.Session{},
+		recoveryCodes: map[int]models.RecoveryCode{},
+		resetTokens:   map[int]models.PasswordResetToken{},
+		invitations:   map[int]models.Invitation{},
+		throttles:     map[int]models.LoginThrottle{},
+		oidcRequests:  map[int]models.OIDCAuthRequest{},
+		apiKeys:       map[string]APIKeyCredential{},
+		roles:         defaultRoles(),
+	}
+}
+
+// defaultRoles is what a freshly seeded role_permission table grants.
+func defaultRoles() map[string][]string {
+	roles := map[string][]string{}
+	for role, permissions := range models.DefaultRolePermissions {
+		roles[role] = slices.Clone(permissions)
+	}
+	for _, p := range models.PermissionCatalog {
+		roles[models.RoleAdmin] = append(roles[models.RoleAdmin], p.Name)
+	}
+	return roles
+}
+
+// AddUser stores u as it is, under its own ID, e.g. with a known password
+// hash or 2FA already enabled.
+func (m *Memory) AddUser(u models.User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.ID] = u
+	m.nextID = max(m.nextID, u.ID)
+}
+
+// AddSession opens session sessionID for userID, valid for a day.
+func (m *Memory) AddSession(sessionID, userID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sessions[sessionID] = models.Session{ID: sessionID, UserID: userID, ExpiresAt: now.Add(24 * time.Hour), CreatedAt: now, UpdatedAt: now}
+	m.nextID = max(m.nextID, sessionID)
+}
+
+// AddAPIKey issues key, as created by utils.GenerateAPIKey, with its prefix.
+func (m *Memory) AddAPIKey(prefix string, key APIKeyCredential) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[prefix] = key
+}
+
+// Grant adds permissions to role.
+func (m *Memory) Grant(role string, permissions ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roles[role] = append(m.roles[role], permissions...)
+}
+
+func (m *Memory) Repositories() *Repositories {
+	return &Repositories{
+		Programs:    memPrograms{m},
+		Lecturers:   memLecturers{m},
+		Enrollments: memEnrollments{m},
+		Assessments: memAssessments{m},
+		Owners:      memOwnership{m},
+		Principals:  memPrincipals{m},
+
+		Users:          memUsers{m},
+		Sessions:       memSessions{m},
+		PasswordResets: memPasswordResets{m},
+		Invitations:    memInvitations{m},
+		LoginThrottles: memLoginThrottles{m},
+		OIDCRequests:   memOIDCRequests{m},
+		Permissions:    memPermissions{m},
+
+		Tx: memUnitOfWork{m},
+	}
+}
+
+func (m *Memory) newID() int {
+	m.nextID++
+	return m.nextID
+}
+
+// violation builds the error Postgres reports for a broken constraint.
+func violation(code, constraint string) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, ConstraintName: constraint, Message: "violates constraint " + constraint}
+}
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+)
+
+// newestFirst orders like ORDER BY created_at DESC; IDs break ties.
+func newestFirst(created func(i int) time.Time, id func(i int) int) func(i, j int) bool {
+	return func(i, j int) bool {
+		if !created(i).Equal(created(j)) {
+			return created(i).After(created(j))
+		}
+		return id(i) > id(j)
+	}
+}
+
+type memUnitOfWork struct{ m *Memory }
+
+type memTxKey struct{}
+
+// Do runs units of work one at a time and restores the records as they were
+// before fn if it fails. Calls made outside a unit of work are not isolated
+// from it.
+func (u memUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	u.m.tx.Lock()
+	defer u.m.tx.Unlock()
+
+	saved := u.m.snapshot()
+	if err := fn(context.WithValue(ctx, memTxKey{}, true)); err != nil {
+		u.m.restore(saved)
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) snapshot() *Memory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	roles := map[string][]string{}
+	for role, permissions := range m.roles {
+		roles[role] = slices.Clone(permissions)
+	}
+	return &Memory{
+		nextID:        m.nextID,
+		users:         maps.Clone(m.users),
+		programs:      maps.Clone(m.programs),
+		lecturers:     maps.Clone(m.lecturers),
+		enrollments:   maps.Clone(m.enrollments),
+		assessments:   maps.Clone(m.assessments),
+		sessions:      maps.Clone(m.sessions),
+		recoveryCodes: maps.Clone(m.recoveryCodes),
+		resetTokens:   maps.Clone(m.resetTokens),
+		invitations:   maps.Clone(m.invitations),
+		throttles:     maps.Clone(m.throttles),
+		oidcRequests:  maps.Clone(m.oidcRequests),
+		roles:         roles,
+	}
+}
+
+func (m *Memory) restore(saved *Memory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID = saved.nextID
+	m.users, m.programs, m.lecturers = saved.users, saved.programs, saved.lecturers
+	m.enrollments, m.assessments = saved.enrollments, saved.assessments
+	m.sessions, m.recoveryCodes, m.resetTokens = saved.sessions, saved.recoveryCodes, saved.resetTokens
+	m.invitations, m.throttles, m.oidcRequests = saved.invitations, saved.throttles, saved.oidcRequests
+	m.roles = saved.roles
+}
+
+type memPrograms struct{ m *Memory }
+
+func (f ProgramFilter) matches(p models.Program) bool {
+	return (f.Semester == nil || p.Semester == *f.Semester) &&
+		(f.IsActive == nil || p.IsActive == *f.IsActive) &&
+		(f.LecturerID == nil || p.LecturerID == *f.LecturerID)
+}
+
+func (r memPrograms) List(ctx context.Context, filter ProgramFilter, opts ListOptions) (Page[models.Program], error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	programs := []models.Program{}
+	for _, p := range r.m.programs {
+		if filter.matches(p) {
+			programs = append(programs, p)
+		}
+	}
+	return memList(programs, programSorts, opts)
+}
+
+// Search requires every word of q as a case-insensitive substring. There is
+// no stemming and the web search operators are taken literally.
+func (r memPrograms) Search(ctx context.Context, q string, filter ProgramFilter, opts ListOptions) (ProgramSearch, error) {
+	limit, offset, err := rankedPage(opts)
+	if err != nil {
+		return ProgramSearch{}, err
+	}
+
+	words := strings.Fields(strings.ToLower(q))
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	terms := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	mark := func(s string) string {
+		return highlight(terms.ReplaceAllString(s, markStart+"$0"+markStop))
+	}
+
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	hits := []models.ProgramSearchHit{}
+	semesters, credits := map[int]int{}, map[int]int{}
+	for _, p := range r.m.programs {
+		if len(words) == 0 || !filter.matches(p) {
+			continue
+		}
+		// Code and name weigh more than the description, as in the index.
+		title, body := strings.ToLower(p.Code+" "+p.Name), strings.ToLower(p.Description)
+		rank := 0.0
+		for _, w := range words {
+			t, b := strings.Count(title, w), strings.Count(body, w)
+			if t+b == 0 {
+				rank = 0
+				break
+			}
+			rank += float64(t) + 0.4*float64(b)
+		}
+		if rank == 0 {
+			continue
+		}
+		hits = append(hits, models.ProgramSearchHit{
+			Program:    p,
+			Rank:       rank,
+			Highlights: models.ProgramHighlights{Name: mark(p.Name), Description: mark(p.Description)},
+		})
+		semesters[p.Semester]++
+		credits[p.Credits]++
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	result := ProgramSearch{Facets: models.ProgramFacets{Semester: facetCounts(semesters), Credits: facetCounts(credits)}}
+	result.Total = len(hits)
+	result.Items = hits[min(offset, len(hits)):min(offset+limit, len(hits))]
+	return result, nil
+}
+
+func facetCounts(counts map[int]int) []models.FacetCount {
+	facets := []models.FacetCount{}
+	for _, v := range slices.Sorted(maps.Keys(counts)) {
+		facets = append(facets, models.FacetCount{Value: v, Count: counts[v]})
+	}
+	return facets
+}
+
+func (r memPrograms) Get(ctx context.Context, id int) (models.Program, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	p, ok := r.m.programs[id]
+	if !ok {
+		return models.Program{}, pgx.ErrNoRows
+	}
+	return p, nil
+}
+
+func (r memPrograms) check(id int, code string, credits, semester int) error {
+	for _, p := range r.m.programs {
+		if p.ID != id && p.Code == code {
+			return violation(uniqueViolation, "idx_program_code")
+		}
+	}
+	if credits < 1 || credits > 24 {
+		return violation(checkViolation, "chk_program_credits")
+	}
+	if semester < 1 || semester > 14 {
+		return violation(checkViolation, "chk_program_semester")
+	}
+	return nil
+}
+
+func (r memPrograms) Create(ctx context.Context, req models.CreateProgramRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if err := r.check(0, req.Code, req.Credits, req.Semester); err != nil {
+		return 0, err
+	}
+	if _, ok := r.m.lecturers[req.LecturerID]; !ok {
+		return 0, violation(foreignKeyViolation, "fk_program_lecturer")
+	}
+
+	now := time.Now()
+	p := models.Program{
+		ID:          r.m.newID(),
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Credits:     req.Credits,
+		Semester:    req.Semester,
+		LecturerID:  req.LecturerID,
+		IsActive:    true,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	r.m.programs[p.ID] = p
+	return p.ID, nil
+}
+
+func (r memPrograms) Update(ctx context.Context, id int, req models.UpdateProgramRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	p, ok := r.m.programs[id]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, p.Version); err != nil {
+		return 0, err
+	}
+	if err := r.check(id, req.Code, req.Credits, req.Semester); err != nil {
+		return 0, err
+	}
+
+	p.Code, p.Name, p.Description, p.Credits, p.Semester = req.Code, req.Name, req.Description, req.Credits, req.Semester
+	if req.IsActive != nil {
+		p.IsActive = *req.IsActive
+	}
+	p.UpdatedAt = time.Now()
+	p.Version++
+	r.m.programs[id] = p
+	return p.Version, nil
+}
+
+func (r memPrograms) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	p, ok := r.m.programs[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, p.Version); err != nil {
+		return err
+	}
+	for _, e := range r.m.enrollments {
+		if e.ProgramID == id {
+			return violation(foreignKeyViolation, "fk_enrollment_program")
+		}
+	}
+	delete(r.m.programs, id)
+	return nil
+}
+
+type memLecturers struct{ m *Memory }
+
+func (r memLecturers) List(ctx context.Context, filter LecturerFilter, opts ListOptions) (Page[models.Lecturer], error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	lecturers := []models.Lecturer{}
+	for _, l := range r.m.lecturers {
+		if filter.Department != "" && l.Department != filter.Department {
+			continue
+		}
+		lecturers = append(lecturers, l)
+	}
+	return memList(lecturers, lecturerSorts, opts)
+}
+
+func (r memLecturers) Get(ctx context.Context, id int) (models.Lecturer, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	l, ok := r.m.lecturers[id]
+	if !ok {
+		return models.Lecturer{}, pgx.ErrNoRows
+	}
+	return l, nil
+}
+
+func (r memLecturers) Create(ctx context.Context, req models.CreateLecturerRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, l := range r.m.lecturers {
+		if l.NIDN == req.NIDN {
+			return 0, violation(uniqueViolation, "idx_lecturer_nidn")
+		}
+		if l.UserID == req.UserID {
+			return 0, violation(uniqueViolation, "idx_lecturer_user_id")
+		}
+	}
+	if _, ok := r.m.users[req.UserID]; !ok {
+		return 0, violation(foreignKeyViolation, "fk_lecturer_user")
+	}
+
+	now := time.Now()
+	l := models.Lecturer{
+		ID:         r.m.newID(),
+		UserID:     req.UserID,
+		NIDN:       req.NIDN,
+		FullName:   req.FullName,
+		Phone:      req.Phone,
+		Department: req.Department,
+		IsActive:   true,
+		Version:    1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	r.m.lecturers[l.ID] = l
+	return l.ID, nil
+}
+
+func (r memLecturers) Update(ctx context.Context, id int, req models.UpdateLecturerRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	l, ok := r.m.lecturers[id]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, l.Version); err != nil {
+		return 0, err
+	}
+	for _, other := range r.m.lecturers {
+		if other.ID != id && other.NIDN == req.NIDN {
+			return 0, violation(uniqueViolation, "idx_lecturer_nidn")
+		}
+	}
+
+	l.NIDN, l.FullName, l.Phone, l.Department = req.NIDN, req.FullName, req.Phone, req.Department
+	if req.IsActive != nil {
+		l.IsActive = *req.IsActive
+	}
+	l.UpdatedAt = time.Now()
+	l.Version++
+	r.m.lecturers[id] = l
+	return l.Version, nil
+}
+
+func (r memLecturers) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	l, ok := r.m.lecturers[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, l.Version); err != nil {
+		return err
+	}
+	for _, p := range r.m.programs {
+		if p.LecturerID == id {
+			return violation(foreignKeyViolation, "fk_program_lecturer")
+		}
+	}
+	delete(r.m.lecturers, id)
+	return nil
+}
+
+func (r memLecturers) IsLecturerUser(ctx context.Context, userID int) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[userID]
+	return ok && u.Role == models.RoleLecturer, nil
+}
+
+type memEnrollments struct{ m *Memory }
+
+func (r memEnrollments) List(ctx context.Context, filter EnrollmentFilter, opts ListOptions) (Page[models.Enrollment], error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	enrollments := []models.Enrollment{}
+	for _, e := range r.m.enrollments {
+		if filter.StudentID != nil && e.StudentID != *filter.StudentID {
+			continue
+		}
+		if filter.ProgramID != nil && e.ProgramID != *filter.ProgramID {
+			continue
+		}
+		if filter.Status != "" && e.Status != filter.Status {
+			continue
+		}
+		if filter.TaughtBy != nil && !r.m.taughtBy(e.ProgramID, *filter.TaughtBy) {
+			continue
+		}
+		enrollments = append(enrollments, e)
+	}
+	return memList(enrollments, enrollmentSorts, opts)
+}
+
+func (m *Memory) taughtBy(programID, userID int) bool {
+	lecturerUserID := m.programLecturerUser(programID)
+	return lecturerUserID != nil && *lecturerUserID == userID
+}
+
+func (m *Memory) programLecturerUser(programID int) *int {
+	p, ok := m.programs[programID]
+	if !ok {
+		return nil
+	}
+	l, ok := m.lecturers[p.LecturerID]
+	if !ok {
+		return nil
+	}
+	return &l.UserID
+}
+
+func (r memEnrollments) Get(ctx context.Context, id int) (models.Enrollment, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	e, ok := r.m.enrollments[id]
+	if !ok {
+		return models.Enrollment{}, pgx.ErrNoRows
+	}
+	return e, nil
+}
+
+func (r memEnrollments) Create(ctx context.Context, req models.CreateEnrollmentRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, e := range r.m.enrollments {
+		if e.StudentID == req.StudentID && e.ProgramID == req.ProgramID {
+			return 0, violation(uniqueViolation, "idx_student_program")
+		}
+	}
+	if _, ok := r.m.users[req.StudentID]; !ok {
+		return 0, violation(foreignKeyViolation, "fk_enrollment_student")
+	}
+	if _, ok := r.m.programs[req.ProgramID]; !ok {
+		return 0, violation(foreignKeyViolation, "fk_enrollment_program")
+	}
+
+	now := time.Now()
+	e := models.Enrollment{
+		ID:         r.m.newID(),
+		StudentID:  req.StudentID,
+		ProgramID:  req.ProgramID,
+		Status:     models.EnrollmentStatusEnrolled,
+		EnrolledAt: now,
+		Version:    1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	r.m.enrollments[e.ID] = e
+	return e.ID, nil
+}
+
+func (r memEnrollments) UpdateStatus(ctx context.Context, id int, status string) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	e, ok := r.m.enrollments[id]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, e.Version); err != nil {
+		return 0, err
+	}
+	if !models.IsValidEnrollmentStatus(status) {
+		return 0, violation(checkViolation, "chk_enrollment_status")
+	}
+
+	e.Status = status
+	e.UpdatedAt = time.Now()
+	e.Version++
+	r.m.enrollments[id] = e
+	return e.Version, nil
+}
+
+func (r memEnrollments) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	e, ok := r.m.enrollments[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, e.Version); err != nil {
+		return err
+	}
+	for _, a := range r.m.assessments {
+		if a.EnrollmentID == id {
+			return violation(foreignKeyViolation, "fk_assessment_enrollment")
+		}
+	}
+	delete(r.m.enrollments, id)
+	return nil
+}
+
+type memAssessments struct{ m *Memory }
+
+func (r memAssessments) ListByEnrollment(ctx context.Context, enrollmentID int) ([]models.Assessment, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	assessments := []models.Assessment{}
+	for _, a := range r.m.assessments {
+		if a.EnrollmentID == enrollmentID {
+			assessments = append(assessments, a)
+		}
+	}
+	sort.Slice(assessments, newestFirst(func(i int) time.Time { return assessments[i].CreatedAt }, func(i int) int { return assessments[i].ID }))
+	return assessments, nil
+}
+
+func (r memAssessments) Get(ctx context.Context, id int) (models.Assessment, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.assessments[id]
+	if !ok {
+		return models.Assessment{}, pgx.ErrNoRows
+	}
+	return a, nil
+}
+
+func checkScores(score, maxScore, weight float64) error {
+	if maxScore <= 0 {
+		return violation(checkViolation, "chk_assessment_max_score")
+	}
+	if score < 0 || score > maxScore {
+		return violation(checkViolation, "chk_assessment_score")
+	}
+	if weight < 0 || weight > 100 {
+		return violation(checkViolation, "chk_assessment_weight")
+	}
+	return nil
+}
+
+func (r memAssessments) Create(ctx context.Context, req models.CreateAssessmentRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	e, ok := r.m.enrollments[req.EnrollmentID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if err := checkScores(req.Score, req.MaxScore, req.Weight); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	a := models.Assessment{
+		ID:           r.m.newID(),
+		EnrollmentID: e.ID,
+		StudentID:    e.StudentID,
+		ProgramID:    e.ProgramID,
+		Category:     req.Category,
+		Score:        req.Score,
+		MaxScore:     req.MaxScore,
+		Weight:       req.Weight,
+		Notes:        req.Notes,
+		Version:      1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	r.m.assessments[a.ID] = a
+	return a.ID, nil
+}
+
+func (r memAssessments) Update(ctx context.Context, id int, req models.UpdateAssessmentRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.assessments[id]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, a.Version); err != nil {
+		return 0, err
+	}
+	if err := checkScores(req.Score, req.MaxScore, req.Weight); err != nil {
+		return 0, err
+	}
+
+	a.Score, a.MaxScore, a.Weight, a.Notes = req.Score, req.MaxScore, req.Weight, req.Notes
+	a.UpdatedAt = time.Now()
+	a.Version++
+	r.m.assessments[id] = a
+	return a.Version, nil
+}
+
+func (r memAssessments) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.assessments[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if err := MatchVersion(ctx, a.Version); err != nil {
+		return err
+	}
+	delete(r.m.assessments, id)
+	return nil
+}
+
+type memOwnership struct{ m *Memory }
+
+func (r memOwnership) LecturerIDForUser(ctx context.Context, userID int) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, l := range r.m.lecturers {
+		if l.UserID == userID {
+			return l.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r memOwnership) ProgramLecturerUser(ctx context.Context, programID int) (*int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.programs[programID]; !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return r.m.programLecturerUser(programID), nil
+}
+
+func (r memOwnership) EnrollmentOwners(ctx context.Context, enrollmentID int) (int, *int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	e, ok := r.m.enrollments[enrollmentID]
+	if !ok {
+		return 0, nil, pgx.ErrNoRows
+	}
+	return e.StudentID, r.m.programLecturerUser(e.ProgramID), nil
+}
+
+func (r memOwnership) AssessmentLecturerUser(ctx context.Context, assessmentID int) (*int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.assessments[assessmentID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return r.m.programLecturerUser(a.ProgramID), nil
+}
+
+type memPrincipals struct{ m *Memory }
+
+func (r memPrincipals) SessionActive(ctx context.Context, sessionID, userID, subjectID int) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	s, ok := r.m.sessions[sessionID]
+	live := ok && s.UserID == userID && s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+	return live && r.m.users[userID].IsActive && r.m.users[subjectID].IsActive, nil
+}
+
+func (r memPrincipals) APIKey(ctx context.Context, prefix string) (APIKeyCredential, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	k, ok := r.m.apiKeys[prefix]
+	if !ok {
+		return APIKeyCredential{}, pgx.ErrNoRows
+	}
+	return k, nil
+}
+
+func (r memPrincipals) TouchAPIKey(ctx context.Context, id int, ip string) error {
+	return nil
+}
+
+func (r memPrincipals) RolePermissions(ctx context.Context) (map[string][]string, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	roles := map[string][]string{}
+	for role, permissions := range r.m.roles {
+		roles[role] = slices.Clone(permissions)
+	}
+	return roles, nil
+}
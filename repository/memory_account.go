@@ -0,0 +1,570 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type memUsers struct{ m *Memory }
+
+func (r memUsers) List(ctx context.Context, filter UserFilter, opts ListOptions) (Page[models.User], error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	users := []models.User{}
+	for _, u := range r.m.users {
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.IsActive != nil && u.IsActive != *filter.IsActive {
+			continue
+		}
+		users = append(users, u)
+	}
+	return memList(users, userSorts, opts)
+}
+
+func (r memUsers) find(match func(u models.User) bool) (models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, u := range r.m.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return models.User{}, pgx.ErrNoRows
+}
+
+func (r memUsers) Get(ctx context.Context, id int) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.ID == id })
+}
+
+func (r memUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(u models.User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+func (r memUsers) GetByOIDC(ctx context.Context, issuer, subject string) (models.User, error) {
+	return r.find(func(u models.User) bool {
+		return u.OIDCIssuer != nil && u.OIDCSubject != nil && *u.OIDCIssuer == issuer && *u.OIDCSubject == subject
+	})
+}
+
+// check enforces the unique indexes of the user table for u.
+func (r memUsers) check(u models.User) error {
+	for _, other := range r.m.users {
+		if other.ID == u.ID {
+			continue
+		}
+		if other.Username == u.Username {
+			return violation(uniqueViolation, "idx_user_username")
+		}
+		if other.Email == u.Email {
+			return violation(uniqueViolation, "idx_user_email")
+		}
+		if u.OIDCIssuer != nil && u.OIDCSubject != nil && other.OIDCIssuer != nil && other.OIDCSubject != nil &&
+			*u.OIDCIssuer == *other.OIDCIssuer && *u.OIDCSubject == *other.OIDCSubject {
+			return violation(uniqueViolation, "idx_user_oidc_identity")
+		}
+	}
+	return nil
+}
+
+func (r memUsers) Create(ctx context.Context, u models.User) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if err := r.check(u); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	u.ID = r.m.newID()
+	u.IsActive, u.MustChangePassword, u.TOTPEnabled, u.TOTPSecret, u.TOTPLastStep = true, false, false, "", 0
+	u.CreatedAt, u.UpdatedAt = now, now
+	r.m.users[u.ID] = u
+	return u.ID, nil
+}
+
+// change applies fn to user id and checks the unique indexes afterwards.
+func (r memUsers) change(id int, fn func(u *models.User)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	fn(&u)
+	if err := r.check(u); err != nil {
+		return err
+	}
+	u.UpdatedAt = time.Now()
+	r.m.users[id] = u
+	return nil
+}
+
+func (r memUsers) Update(ctx context.Context, id int, req models.UpdateUserRequest) error {
+	return r.change(id, func(u *models.User) {
+		if req.Username != nil {
+			u.Username = *req.Username
+		}
+		if req.Email != nil {
+			u.Email = *req.Email
+		}
+		if req.FullName != nil {
+			u.FullName = *req.FullName
+		}
+		if req.Phone != nil {
+			u.Phone = *req.Phone
+		}
+	})
+}
+
+func (r memUsers) SetRole(ctx context.Context, id int, role string) error {
+	return r.change(id, func(u *models.User) { u.Role = role })
+}
+
+func (r memUsers) SetActive(ctx context.Context, id int, active bool) error {
+	return r.change(id, func(u *models.User) { u.IsActive = active })
+}
+
+func (r memUsers) SetPassword(ctx context.Context, id int, hash string, mustChange bool) error {
+	return r.change(id, func(u *models.User) { u.PasswordHash, u.MustChangePassword = hash, mustChange })
+}
+
+func (r memUsers) LinkOIDC(ctx context.Context, id int, issuer, subject string) error {
+	return r.change(id, func(u *models.User) { u.OIDCIssuer, u.OIDCSubject = &issuer, &subject })
+}
+
+func (r memUsers) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	return r.change(id, func(u *models.User) { u.TOTPSecret, u.TOTPLastStep = secret, 0 })
+}
+
+func (r memUsers) EnableTOTP(ctx context.Context, id int, step int64) error {
+	return r.change(id, func(u *models.User) { u.TOTPEnabled, u.TOTPLastStep = true, step })
+}
+
+func (r memUsers) DisableTOTP(ctx context.Context, id int) error {
+	err := r.change(id, func(u *models.User) { u.TOTPEnabled, u.TOTPSecret, u.TOTPLastStep = false, "", 0 })
+	if err != nil {
+		return err
+	}
+
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for codeID, c := range r.m.recoveryCodes {
+		if c.UserID == id {
+			delete(r.m.recoveryCodes, codeID)
+		}
+	}
+	return nil
+}
+
+func (r memUsers) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[id]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	r.m.users[id] = u
+	return true, nil
+}
+
+func (r memUsers) ReplaceRecoveryCodes(ctx context.Context, id int, hashes []string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for codeID, c := range r.m.recoveryCodes {
+		if c.UserID == id {
+			delete(r.m.recoveryCodes, codeID)
+		}
+	}
+	for _, hash := range hashes {
+		c := models.RecoveryCode{ID: r.m.newID(), UserID: id, CodeHash: hash, CreatedAt: time.Now()}
+		r.m.recoveryCodes[c.ID] = c
+	}
+	return nil
+}
+
+func (r memUsers) UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for codeID, c := range r.m.recoveryCodes {
+		if c.UserID == id && c.CodeHash == hash && c.UsedAt == nil {
+			now := time.Now()
+			c.UsedAt = &now
+			r.m.recoveryCodes[codeID] = c
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memUsers) RecordImpersonation(ctx context.Context, id int, details any) error {
+	return nil
+}
+
+type memSessions struct{ m *Memory }
+
+func (r memSessions) Create(ctx context.Context, s models.Session) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[s.UserID]; !ok {
+		return 0, violation(foreignKeyViolation, "fk_user_session_user")
+	}
+	for _, other := range r.m.sessions {
+		if other.RefreshTokenHash == s.RefreshTokenHash {
+			return 0, violation(uniqueViolation, "idx_user_session_refresh_token_hash")
+		}
+	}
+
+	now := time.Now()
+	s.ID = r.m.newID()
+	s.LastUsedAt, s.RevokedAt = nil, nil
+	s.CreatedAt, s.UpdatedAt = now, now
+	r.m.sessions[s.ID] = s
+	return s.ID, nil
+}
+
+func (r memSessions) ByRefreshToken(ctx context.Context, hash string) (models.Session, models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, s := range r.m.sessions {
+		if s.RefreshTokenHash == hash && s.RevokedAt == nil && s.ExpiresAt.After(time.Now()) {
+			return s, r.m.users[s.UserID], nil
+		}
+	}
+	return models.Session{}, models.User{}, pgx.ErrNoRows
+}
+
+func (r memSessions) Rotate(ctx context.Context, id int, oldHash, newHash string) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	s, ok := r.m.sessions[id]
+	if !ok || s.RefreshTokenHash != oldHash || s.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	s.RefreshTokenHash, s.LastUsedAt, s.UpdatedAt = newHash, &now, now
+	r.m.sessions[id] = s
+	return true, nil
+}
+
+func (r memSessions) Revoke(ctx context.Context, userID, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if s, ok := r.m.sessions[id]; ok && s.UserID == userID {
+		r.m.revoke(s)
+	}
+	return nil
+}
+
+func (r memSessions) RevokeAll(ctx context.Context, userID, keep int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var revoked int64
+	for _, s := range r.m.sessions {
+		if s.UserID == userID && s.ID != keep && r.m.revoke(s) {
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+// revoke ends s unless it has ended already and reports whether it did.
+func (m *Memory) revoke(s models.Session) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	now := time.Now()
+	s.RevokedAt, s.UpdatedAt = &now, now
+	m.sessions[s.ID] = s
+	return true
+}
+
+type memPasswordResets struct{ m *Memory }
+
+func (r memPasswordResets) Issue(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[userID]; !ok {
+		return violation(foreignKeyViolation, "fk_password_reset_token_user")
+	}
+
+	now := time.Now()
+	for id, t := range r.m.resetTokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &now
+			r.m.resetTokens[id] = t
+		}
+	}
+	t := models.PasswordResetToken{ID: r.m.newID(), UserID: userID, TokenHash: hash, ExpiresAt: expiresAt, CreatedAt: now}
+	r.m.resetTokens[t.ID] = t
+	return nil
+}
+
+func (r memPasswordResets) Redeem(ctx context.Context, hash string) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.m.resetTokens {
+		if t.TokenHash == hash && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			r.m.resetTokens[id] = t
+			return t.UserID, nil
+		}
+	}
+	return 0, pgx.ErrNoRows
+}
+
+type memInvitations struct{ m *Memory }
+
+func pending(i models.Invitation) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil
+}
+
+func (r memInvitations) ListPending(ctx context.Context) ([]models.Invitation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	invitations := []models.Invitation{}
+	for _, i := range r.m.invitations {
+		if pending(i) {
+			invitations = append(invitations, i)
+		}
+	}
+	sort.Slice(invitations, newestFirst(func(i int) time.Time { return invitations[i].CreatedAt }, func(i int) int { return invitations[i].ID }))
+	return invitations, nil
+}
+
+func (r memInvitations) Create(ctx context.Context, inv models.Invitation) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for id, i := range r.m.invitations {
+		if i.TokenHash == inv.TokenHash {
+			return 0, violation(uniqueViolation, "idx_user_invitation_token_hash")
+		}
+		if i.Email == inv.Email && pending(i) {
+			i.RevokedAt, i.UpdatedAt = &now, now
+			r.m.invitations[id] = i
+		}
+	}
+
+	inv.ID = r.m.newID()
+	inv.AcceptedAt, inv.AcceptedUserID, inv.RevokedAt = nil, nil, nil
+	inv.CreatedAt, inv.UpdatedAt = now, now
+	r.m.invitations[inv.ID] = inv
+	return inv.ID, nil
+}
+
+func (r memInvitations) Revoke(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	i, ok := r.m.invitations[id]
+	if !ok || !pending(i) {
+		return pgx.ErrNoRows
+	}
+	now := time.Now()
+	i.RevokedAt, i.UpdatedAt = &now, now
+	r.m.invitations[id] = i
+	return nil
+}
+
+func (r memInvitations) Pending(ctx context.Context, hash string) (models.Invitation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, i := range r.m.invitations {
+		if i.TokenHash == hash && pending(i) && i.ExpiresAt.After(time.Now()) {
+			return i, nil
+		}
+	}
+	return models.Invitation{}, pgx.ErrNoRows
+}
+
+func (r memInvitations) Accept(ctx context.Context, id, userID int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	i, ok := r.m.invitations[id]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	i.AcceptedAt, i.AcceptedUserID, i.UpdatedAt = &now, &userID, now
+	r.m.invitations[id] = i
+	return nil
+}
+
+type memLoginThrottles struct{ m *Memory }
+
+func (r memLoginThrottles) List(ctx context.Context, lockedOnly bool) ([]models.LoginThrottle, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	throttles := []models.LoginThrottle{}
+	for _, l := range r.m.throttles {
+		if lockedOnly && (l.LockedUntil == nil || !l.LockedUntil.After(time.Now())) {
+			continue
+		}
+		throttles = append(throttles, l)
+	}
+	sort.Slice(throttles, newestFirst(func(i int) time.Time { return throttles[i].LastFailedAt }, func(i int) int { return throttles[i].ID }))
+	return throttles, nil
+}
+
+func (r memLoginThrottles) LockedUntil(ctx context.Context, email, ip string) (*time.Time, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var latest *time.Time
+	for _, l := range r.m.throttles {
+		if !(l.Kind == models.ThrottleKindEmail && l.Value == email) && !(l.Kind == models.ThrottleKindIP && l.Value == ip) {
+			continue
+		}
+		if l.LockedUntil != nil && l.LockedUntil.After(time.Now()) && (latest == nil || l.LockedUntil.After(*latest)) {
+			latest = l.LockedUntil
+		}
+	}
+	return latest, nil
+}
+
+// throttle returns the ID of the counter of kind and value, or 0.
+func (m *Memory) throttle(kind, value string) int {
+	for id, l := range m.throttles {
+		if l.Kind == kind && l.Value == value {
+			return id
+		}
+	}
+	return 0
+}
+
+func (r memLoginThrottles) Fail(ctx context.Context, kind, value string, since time.Time) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if kind != models.ThrottleKindEmail && kind != models.ThrottleKindIP {
+		return 0, violation(checkViolation, "chk_login_throttle_kind")
+	}
+
+	now := time.Now()
+	l, ok := r.m.throttles[r.m.throttle(kind, value)]
+	switch {
+	case !ok:
+		l = models.LoginThrottle{ID: r.m.newID(), Kind: kind, Value: value, FailedAttempts: 1, CreatedAt: now}
+	case l.LastFailedAt.Before(since):
+		l.FailedAttempts = 1
+	default:
+		l.FailedAttempts++
+	}
+	l.LastFailedAt, l.UpdatedAt = now, now
+	r.m.throttles[l.ID] = l
+	return l.FailedAttempts, nil
+}
+
+func (r memLoginThrottles) Lock(ctx context.Context, kind, value string, until time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if l, ok := r.m.throttles[r.m.throttle(kind, value)]; ok {
+		l.LockedUntil = &until
+		r.m.throttles[l.ID] = l
+	}
+	return nil
+}
+
+func (r memLoginThrottles) Clear(ctx context.Context, kind, value string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.throttles, r.m.throttle(kind, value))
+	return nil
+}
+
+func (r memLoginThrottles) Delete(ctx context.Context, id int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.throttles[id]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(r.m.throttles, id)
+	return nil
+}
+
+type memOIDCRequests struct{ m *Memory }
+
+func (r memOIDCRequests) Create(ctx context.Context, req models.OIDCAuthRequest) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for id, other := range r.m.oidcRequests {
+		if other.ExpiresAt.Before(now) {
+			delete(r.m.oidcRequests, id)
+		} else if other.StateHash == req.StateHash {
+			return violation(uniqueViolation, "idx_oidc_auth_request_state_hash")
+		}
+	}
+
+	req.ID, req.CreatedAt = r.m.newID(), now
+	r.m.oidcRequests[req.ID] = req
+	return nil
+}
+
+func (r memOIDCRequests) Take(ctx context.Context, stateHash string) (models.OIDCAuthRequest, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id, req := range r.m.oidcRequests {
+		if req.StateHash == stateHash && req.ExpiresAt.After(time.Now()) {
+			delete(r.m.oidcRequests, id)
+			return req, nil
+		}
+	}
+	return models.OIDCAuthRequest{}, pgx.ErrNoRows
+}
+
+type memPermissions struct{ m *Memory }
+
+func (r memPermissions) List(ctx context.Context) ([]models.Permission, error) {
+	return slices.SortedFunc(slices.Values(models.PermissionCatalog), func(a, b models.Permission) int {
+		return cmp.Compare(a.Name, b.Name)
+	}), nil
+}
+
+func (r memPermissions) SetRole(ctx context.Context, role string, permissions []string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return violation(foreignKeyViolation, "fk_role_permission_permission")
+		}
+	}
+	r.m.roles[role] = slices.Clone(permissions)
+	return nil
+}
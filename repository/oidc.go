@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+)
+
+type pgOIDCRequests struct {
+	db *database.Database
+}
+
+func (r *pgOIDCRequests) Create(ctx context.Context, req models.OIDCAuthRequest) error {
+	conn := r.db.Conn(ctx)
+
+	// Sweeping here saves a separate job; a failed sweep is retried by the
+	// next login.
+	conn.Exec(ctx, `DELETE FROM "oidc_auth_request" WHERE expires_at < CURRENT_TIMESTAMP`)
+
+	query := `
+		INSERT INTO "oidc_auth_request" (state_hash, nonce, code_verifier, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+	`
+	_, err := conn.Exec(ctx, query, req.StateHash, req.Nonce, req.CodeVerifier, req.IPAddress, req.ExpiresAt)
+	return err
+}
+
+func (r *pgOIDCRequests) Take(ctx context.Context, stateHash string) (models.OIDCAuthRequest, error) {
+	req := models.OIDCAuthRequest{StateHash: stateHash}
+	query := `
+		DELETE FROM "oidc_auth_request"
+		WHERE state_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, nonce, code_verifier, COALESCE(ip_address, ''), expires_at
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, stateHash).Scan(&req.ID, &req.Nonce, &req.CodeVerifier, &req.IPAddress, &req.ExpiresAt)
+	return req, err
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"mbkm-api/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgOwnership struct {
+	db *database.Database
+}
+
+func (r *pgOwnership) LecturerIDForUser(ctx context.Context, userID int) (int, error) {
+	var id int
+	err := r.db.Conn(ctx).QueryRow(ctx, `SELECT id FROM "lecturer" WHERE user_id = $1`, userID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+func (r *pgOwnership) ProgramLecturerUser(ctx context.Context, programID int) (*int, error) {
+	var lecturerUserID *int
+	query := `SELECT l.user_id FROM "program" p LEFT JOIN "lecturer" l ON l.id = p.lecturer_id WHERE p.id = $1`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, programID).Scan(&lecturerUserID)
+	return lecturerUserID, err
+}
+
+func (r *pgOwnership) EnrollmentOwners(ctx context.Context, enrollmentID int) (int, *int, error) {
+	var studentID int
+	var lecturerUserID *int
+	query := `
+		SELECT e.student_id, l.user_id FROM "enrollment" e
+		JOIN "program" p ON p.id = e.program_id
+		LEFT JOIN "lecturer" l ON l.id = p.lecturer_id
+		WHERE e.id = $1
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, enrollmentID).Scan(&studentID, &lecturerUserID)
+	return studentID, lecturerUserID, err
+}
+
+func (r *pgOwnership) AssessmentLecturerUser(ctx context.Context, assessmentID int) (*int, error) {
+	var lecturerUserID *int
+	query := `
+		SELECT l.user_id FROM "assessment" a
+		JOIN "program" p ON p.id = a.program_id
+		LEFT JOIN "lecturer" l ON l.id = p.lecturer_id
+		WHERE a.id = $1
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, assessmentID).Scan(&lecturerUserID)
+	return lecturerUserID, err
+}
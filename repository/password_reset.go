@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgPasswordResets struct {
+	db *database.Database
+}
+
+func (r *pgPasswordResets) Issue(ctx context.Context, userID int, hash string, expiresAt time.Time) error {
+	return pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE "password_reset_token" SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL`, userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO "password_reset_token" (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, CURRENT_TIMESTAMP)`, userID, hash, expiresAt)
+		return err
+	})
+}
+
+func (r *pgPasswordResets) Redeem(ctx context.Context, hash string) (int, error) {
+	// Matching on used_at IS NULL makes a concurrent second use of the same
+	// token find nothing.
+	var userID int
+	query := `
+		UPDATE "password_reset_token" SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, hash).Scan(&userID)
+	return userID, err
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgPermissions struct {
+	db *database.Database
+}
+
+func (r *pgPermissions) List(ctx context.Context) ([]models.Permission, error) {
+	rows, err := r.db.Conn(ctx).Query(ctx, `SELECT name, COALESCE(description, '') FROM "permission" ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []models.Permission{}
+	for rows.Next() {
+		var p models.Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *pgPermissions) SetRole(ctx context.Context, role string, permissions []string) error {
+	return pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM "role_permission" WHERE role = $1 AND permission <> ALL($2)`, role, permissions); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO "role_permission" (role, permission, created_at)
+			SELECT $1, p, CURRENT_TIMESTAMP FROM UNNEST($2::text[]) AS p
+			ON CONFLICT (role, permission) DO NOTHING
+		`, role, permissions)
+		return err
+	})
+}
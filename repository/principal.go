@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+)
+
+// APIKeyCredential is what authentication needs to know about a live API
+// key: its hash to compare against and the service account behind it.
+type APIKeyCredential struct {
+	ID               int
+	Hash             string
+	Scopes           []string
+	ServiceAccountID int
+	Role             string
+}
+
+type pgPrincipals struct {
+	db *database.Database
+}
+
+func (r *pgPrincipals) SessionActive(ctx context.Context, sessionID, userID, subjectID int) (bool, error) {
+	var active bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM "user_session" s
+			JOIN "user" u ON u.id = s.user_id
+			JOIN "user" t ON t.id = $3
+			WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP AND u.is_active = true AND t.is_active = true
+		)
+	`
+	err := r.db.Pool.QueryRow(ctx, query, sessionID, userID, subjectID).Scan(&active)
+	return active, err
+}
+
+func (r *pgPrincipals) APIKey(ctx context.Context, prefix string) (APIKeyCredential, error) {
+	var k APIKeyCredential
+	query := `
+		SELECT k.id, k.key_hash, k.scopes, sa.id, sa.role
+		FROM "api_key" k
+		JOIN "service_account" sa ON sa.id = k.service_account_id
+		WHERE k.prefix = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP) AND sa.is_active = true
+	`
+	err := r.db.Pool.QueryRow(ctx, query, prefix).Scan(&k.ID, &k.Hash, &k.Scopes, &k.ServiceAccountID, &k.Role)
+	return k, err
+}
+
+func (r *pgPrincipals) TouchAPIKey(ctx context.Context, id int, ip string) error {
+	// Only touch last_used_at once a minute so busy integrations do not turn
+	// every read into a write.
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE "api_key" SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`, id, ip)
+	return err
+}
+
+func (r *pgPrincipals) RolePermissions(ctx context.Context) (map[string][]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT role, permission FROM "role_permission"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := map[string][]string{}
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		roles[role] = append(roles[role], permission)
+	}
+	return roles, rows.Err()
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgPrograms struct {
+	db *database.Database
+}
+
+const programColumns = `id, code, name, COALESCE(description, ''), credits, semester, lecturer_id, is_active, version, created_at, updated_at`
+
+func scanProgram(row pgx.Row, p *models.Program) error {
+	return row.Scan(&p.ID, &p.Code, &p.Name, &p.Description, &p.Credits, &p.Semester, &p.LecturerID, &p.IsActive, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+}
+
+var programSorts = sortSpec[models.Program]{
+	fields: map[string]sortField[models.Program]{
+		"created_at": {"created_at", sortTime, func(p models.Program) any { return p.CreatedAt }},
+		"code":       {"code", sortString, func(p models.Program) any { return p.Code }},
+		"name":       {"name", sortString, func(p models.Program) any { return p.Name }},
+		"semester":   {"semester", sortInt, func(p models.Program) any { return p.Semester }},
+		"credits":    {"credits", sortInt, func(p models.Program) any { return p.Credits }},
+	},
+	def: "-created_at",
+	id:  func(p models.Program) int { return p.ID },
+}
+
+// where appends the filter's conditions, numbering placeholders after args.
+func (f ProgramFilter) where(args []any) ([]string, []any) {
+	where := []string{}
+	if f.Semester != nil {
+		args = append(args, *f.Semester)
+		where = append(where, "semester = $"+strconv.Itoa(len(args)))
+	}
+	if f.IsActive != nil {
+		args = append(args, *f.IsActive)
+		where = append(where, "is_active = $"+strconv.Itoa(len(args)))
+	}
+	if f.LecturerID != nil {
+		args = append(args, *f.LecturerID)
+		where = append(where, "lecturer_id = $"+strconv.Itoa(len(args)))
+	}
+	return where, args
+}
+
+func (r *pgPrograms) List(ctx context.Context, filter ProgramFilter, opts ListOptions) (Page[models.Program], error) {
+	where, args := filter.where(nil)
+	return pgList(ctx, r.db.Conn(ctx), "program", programColumns, where, args, programSorts, opts, scanProgram)
+}
+
+// Highlighted terms are delimited with private use characters so the excerpt
+// can be HTML-escaped before they become <mark> tags.
+const (
+	markStart = "\ue000"
+	markStop  = "\ue001"
+)
+
+var (
+	nameHeadline        = "StartSel=" + markStart + ", StopSel=" + markStop + ", HighlightAll=true"
+	descriptionHeadline = "StartSel=" + markStart + ", StopSel=" + markStop + ", MaxFragments=2, MinWords=8, MaxWords=25, FragmentDelimiter=\" … \""
+	markTags            = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+)
+
+func highlight(excerpt string) string {
+	return markTags.Replace(html.EscapeString(excerpt))
+}
+
+func (r *pgPrograms) Search(ctx context.Context, q string, filter ProgramFilter, opts ListOptions) (ProgramSearch, error) {
+	limit, offset, err := rankedPage(opts)
+	if err != nil {
+		return ProgramSearch{}, err
+	}
+
+	where, args := filter.where([]any{q})
+	from := `FROM "program", websearch_to_tsquery('mbkm_indonesian', $1) AS query WHERE ` +
+		strings.Join(append([]string{"search_vector @@ query"}, where...), " AND ")
+	conn := r.db.Conn(ctx)
+
+	// One row per semester, one per credits value and one grand total.
+	rows, err := conn.Query(ctx, `SELECT GROUPING(semester), GROUPING(credits), semester, credits, COUNT(*) `+from+
+		` GROUP BY GROUPING SETS ((semester), (credits), ()) ORDER BY 3, 4`, args...)
+	if err != nil {
+		return ProgramSearch{}, err
+	}
+	var result ProgramSearch
+	result.Facets = models.ProgramFacets{Semester: []models.FacetCount{}, Credits: []models.FacetCount{}}
+	for rows.Next() {
+		var noSemester, noCredits, count int
+		var semester, credits *int
+		if err := rows.Scan(&noSemester, &noCredits, &semester, &credits, &count); err != nil {
+			rows.Close()
+			return ProgramSearch{}, err
+		}
+		switch {
+		case noSemester == 1 && noCredits == 1:
+			result.Total = count
+		case noSemester == 0 && semester != nil:
+			result.Facets.Semester = append(result.Facets.Semester, models.FacetCount{Value: *semester, Count: count})
+		case noCredits == 0 && credits != nil:
+			result.Facets.Credits = append(result.Facets.Credits, models.FacetCount{Value: *credits, Count: count})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ProgramSearch{}, err
+	}
+
+	args = append(args, nameHeadline, descriptionHeadline, limit, offset)
+	n := len(args)
+	rows, err = conn.Query(ctx, fmt.Sprintf(`SELECT %s, ts_rank_cd(search_vector, query)::float8,
+		ts_headline('mbkm_indonesian', name, query, $%d),
+		ts_headline('mbkm_indonesian', COALESCE(description, ''), query, $%d)
+		%s ORDER BY 12 DESC, id LIMIT $%d OFFSET $%d`, programColumns, n-3, n-2, from, n-1, n), args...)
+	if err != nil {
+		return ProgramSearch{}, err
+	}
+	defer rows.Close()
+
+	result.Items = []models.ProgramSearchHit{}
+	for rows.Next() {
+		var h models.ProgramSearchHit
+		p := &h.Program
+		if err := rows.Scan(&p.ID, &p.Code, &p.Name, &p.Description, &p.Credits, &p.Semester, &p.LecturerID, &p.IsActive, &p.Version, &p.CreatedAt, &p.UpdatedAt,
+			&h.Rank, &h.Highlights.Name, &h.Highlights.Description); err != nil {
+			return ProgramSearch{}, err
+		}
+		h.Highlights.Name, h.Highlights.Description = highlight(h.Highlights.Name), highlight(h.Highlights.Description)
+		result.Items = append(result.Items, h)
+	}
+	if err := rows.Err(); err != nil {
+		return ProgramSearch{}, err
+	}
+	return result, nil
+}
+
+func (r *pgPrograms) Get(ctx context.Context, id int) (models.Program, error) {
+	var p models.Program
+	err := scanProgram(r.db.Conn(ctx).QueryRow(ctx, `SELECT `+programColumns+` FROM "program" WHERE id = $1`, id), &p)
+	return p, err
+}
+
+func (r *pgPrograms) Create(ctx context.Context, req models.CreateProgramRequest) (int, error) {
+	return insert(ctx, r.db, "program", func(tx pgx.Tx) (int, error) {
+		var id int
+		query := `INSERT INTO "program" (code, name, description, credits, semester, lecturer_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) RETURNING id`
+		err := tx.QueryRow(ctx, query, req.Code, req.Name, req.Description, req.Credits, req.Semester, req.LecturerID).Scan(&id)
+		return id, err
+	})
+}
+
+func (r *pgPrograms) Update(ctx context.Context, id int, req models.UpdateProgramRequest) (int, error) {
+	var version int
+	err := mutate(ctx, r.db, models.AuditActionUpdate, "program", id, func(tx pgx.Tx) error {
+		query := `UPDATE "program" SET code = $1, name = $2, description = NULLIF($3, ''), credits = $4, semester = $5, is_active = COALESCE($6, is_active), version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $7 RETURNING version`
+		return tx.QueryRow(ctx, query, req.Code, req.Name, req.Description, req.Credits, req.Semester, req.IsActive, id).Scan(&version)
+	})
+	return version, err
+}
+
+func (r *pgPrograms) Delete(ctx context.Context, id int) error {
+	return remove(ctx, r.db, "program", id)
+}
@@ -0,0 +1,345 @@
+// Package repository is the data access layer for the academic records:
+// programs, lecturers, enrollments and assessments, for user accounts and
+// their credentials, plus the lookups that authenticate the principals
+// touching them. Handlers and middleware depend
+// on the interfaces here; NewPostgres backs them with pgx and NewMemory with maps,
+// so handlers can be exercised without a database.
+//
+// Both implementations report failures the way Postgres does, pgx.ErrNoRows
+// for a missing row and *pgconn.PgError for constraint violations, so
+// utils.DBError translates them identically.
+package repository
+
+import (
+	"context"
+	"mbkm-api/audit"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ProgramFilter narrows List. Nil fields do not filter.
+type ProgramFilter struct {
+	Semester   *int
+	IsActive   *bool
+	LecturerID *int
+}
+
+// ProgramSearch is one page of search hits, best match first, with facet
+// counts over every match.
+type ProgramSearch struct {
+	Page[models.ProgramSearchHit]
+	Facets models.ProgramFacets
+}
+
+type ProgramRepository interface {
+	// List sorts by created_at (default, newest first), code, name, semester
+	// or credits.
+	List(ctx context.Context, filter ProgramFilter, opts ListOptions) (Page[models.Program], error)
+	// Search matches q, in web search syntax, against code, name and
+	// description. Hits are ordered by relevance and paged by number only.
+	Search(ctx context.Context, q string, filter ProgramFilter, opts ListOptions) (ProgramSearch, error)
+	Get(ctx context.Context, id int) (models.Program, error)
+	Create(ctx context.Context, req models.CreateProgramRequest) (int, error)
+	// Update replaces every field but the lecturer; a nil IsActive keeps the
+	// flag as it is. It returns the program's new version.
+	Update(ctx context.Context, id int, req models.UpdateProgramRequest) (int, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// LecturerFilter narrows List. Empty fields do not filter.
+type LecturerFilter struct {
+	Department string
+}
+
+type LecturerRepository interface {
+	// List sorts by full_name (default), nidn or created_at.
+	List(ctx context.Context, filter LecturerFilter, opts ListOptions) (Page[models.Lecturer], error)
+	Get(ctx context.Context, id int) (models.Lecturer, error)
+	Create(ctx context.Context, req models.CreateLecturerRequest) (int, error)
+	// Update replaces every field; a nil IsActive keeps the flag as it is.
+	// It returns the lecturer's new version.
+	Update(ctx context.Context, id int, req models.UpdateLecturerRequest) (int, error)
+	Delete(ctx context.Context, id int) error
+	// IsLecturerUser reports whether userID is a user with the lecturer role.
+	IsLecturerUser(ctx context.Context, userID int) (bool, error)
+}
+
+// EnrollmentFilter narrows List. Nil and empty fields do not filter.
+type EnrollmentFilter struct {
+	StudentID *int
+	ProgramID *int
+	Status    string
+	// TaughtBy keeps enrollments in programs taught by this lecturer user.
+	TaughtBy *int
+}
+
+type EnrollmentRepository interface {
+	// List sorts by created_at (default, newest first), enrolled_at or status.
+	List(ctx context.Context, filter EnrollmentFilter, opts ListOptions) (Page[models.Enrollment], error)
+	Get(ctx context.Context, id int) (models.Enrollment, error)
+	Create(ctx context.Context, req models.CreateEnrollmentRequest) (int, error)
+	// UpdateStatus returns the enrollment's new version.
+	UpdateStatus(ctx context.Context, id int, status string) (int, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type AssessmentRepository interface {
+	ListByEnrollment(ctx context.Context, enrollmentID int) ([]models.Assessment, error)
+	Get(ctx context.Context, id int) (models.Assessment, error)
+	// Create copies the student and program from the enrollment and returns
+	// pgx.ErrNoRows if the enrollment does not exist.
+	Create(ctx context.Context, req models.CreateAssessmentRequest) (int, error)
+	// Update returns the assessment's new version.
+	Update(ctx context.Context, id int, req models.UpdateAssessmentRequest) (int, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// OwnershipRepository answers who a record belongs to for the access policy.
+// Lecturer user IDs are nil when the program has no lecturer account.
+type OwnershipRepository interface {
+	// LecturerIDForUser returns the lecturer record of a user, or 0.
+	LecturerIDForUser(ctx context.Context, userID int) (int, error)
+	ProgramLecturerUser(ctx context.Context, programID int) (*int, error)
+	EnrollmentOwners(ctx context.Context, enrollmentID int) (studentID int, lecturerUserID *int, err error)
+	AssessmentLecturerUser(ctx context.Context, assessmentID int) (*int, error)
+}
+
+// PrincipalRepository answers who may call the API at all: whether a
+// session is still usable, which key an API key prefix belongs to and what
+// each role is allowed.
+type PrincipalRepository interface {
+	// SessionActive reports whether session sessionID of userID is neither
+	// revoked nor expired and both userID and subjectID are active users.
+	// subjectID differs from userID while impersonating.
+	SessionActive(ctx context.Context, sessionID, userID, subjectID int) (bool, error)
+	// APIKey returns the unrevoked, unexpired key with prefix of an active
+	// service account, or pgx.ErrNoRows.
+	APIKey(ctx context.Context, prefix string) (APIKeyCredential, error)
+	// TouchAPIKey records a use of key id from ip, at most once a minute.
+	TouchAPIKey(ctx context.Context, id int, ip string) error
+	// RolePermissions lists the permissions of every role.
+	RolePermissions(ctx context.Context) (map[string][]string, error)
+}
+
+// UserRepository stores user accounts with their password, TOTP and SSO
+// credentials. Users not found are pgx.ErrNoRows.
+type UserRepository interface {
+	// List sorts by id (default), username, full_name or created_at.
+	List(ctx context.Context, filter UserFilter, opts ListOptions) (Page[models.User], error)
+	// Get returns the user including its credentials.
+	Get(ctx context.Context, id int) (models.User, error)
+	// GetByEmail matches email case-insensitively.
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// GetByOIDC returns the user linked to subject at issuer.
+	GetByOIDC(ctx context.Context, issuer, subject string) (models.User, error)
+	// Create stores username, email, password hash, profile, role and SSO
+	// link of u; the account starts active.
+	Create(ctx context.Context, u models.User) (int, error)
+	// Update changes the fields of req that are not nil.
+	Update(ctx context.Context, id int, req models.UpdateUserRequest) error
+	SetRole(ctx context.Context, id int, role string) error
+	SetActive(ctx context.Context, id int, active bool) error
+	SetPassword(ctx context.Context, id int, hash string, mustChange bool) error
+	LinkOIDC(ctx context.Context, id int, issuer, subject string) error
+	// SetTOTPSecret stores a secret for an enrollment that is not confirmed
+	// yet.
+	SetTOTPSecret(ctx context.Context, id int, secret string) error
+	// EnableTOTP turns 2FA on with step as the last TOTP step used.
+	EnableTOTP(ctx context.Context, id int, step int64) error
+	// DisableTOTP turns 2FA off and drops the secret and recovery codes.
+	DisableTOTP(ctx context.Context, id int) error
+	// UseTOTPStep records step as used. It reports false when step or a
+	// later one was used already.
+	UseTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	// ReplaceRecoveryCodes replaces the user's recovery codes by hashes.
+	ReplaceRecoveryCodes(ctx context.Context, id int, hashes []string) error
+	// UseRecoveryCode marks the unused code with hash used. It reports
+	// false when there is none.
+	UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error)
+	// RecordImpersonation writes the start of an impersonation of user id to
+	// the audit log, attributed to the actor in ctx.
+	RecordImpersonation(ctx context.Context, id int, details any) error
+}
+
+// SessionRepository stores the refresh-token sessions of users.
+type SessionRepository interface {
+	// Create stores the user, refresh token hash, client and expiry of s.
+	Create(ctx context.Context, s models.Session) (int, error)
+	// ByRefreshToken returns the live session with refresh token hash and
+	// its user, or pgx.ErrNoRows.
+	ByRefreshToken(ctx context.Context, hash string) (models.Session, models.User, error)
+	// Rotate replaces the refresh token hash of session id. It reports false
+	// when oldHash is no longer the current one or the session was revoked.
+	Rotate(ctx context.Context, id int, oldHash, newHash string) (bool, error)
+	// Revoke ends session id of userID.
+	Revoke(ctx context.Context, userID, id int) error
+	// RevokeAll ends every live session of userID but keep, which may be 0,
+	// and returns how many it ended.
+	RevokeAll(ctx context.Context, userID, keep int) (int64, error)
+}
+
+// PasswordResetRepository stores single-use password reset tokens.
+type PasswordResetRepository interface {
+	// Issue invalidates the user's unused tokens, so only the newest link
+	// works, and stores the one with hash.
+	Issue(ctx context.Context, userID int, hash string, expiresAt time.Time) error
+	// Redeem marks the unused, unexpired token with hash used and returns
+	// its user, or pgx.ErrNoRows.
+	Redeem(ctx context.Context, hash string) (int, error)
+}
+
+// InvitationRepository stores single-use invitations to create an account.
+type InvitationRepository interface {
+	// ListPending returns invitations neither accepted nor revoked, newest
+	// first.
+	ListPending(ctx context.Context) ([]models.Invitation, error)
+	// Create revokes the pending invitations for the same email and stores
+	// the email, role, token hash, inviter and expiry of inv.
+	Create(ctx context.Context, inv models.Invitation) (int, error)
+	// Revoke revokes pending invitation id, or returns pgx.ErrNoRows.
+	Revoke(ctx context.Context, id int) error
+	// Pending returns the pending, unexpired invitation with token hash and
+	// locks it until the unit of work ends, or returns pgx.ErrNoRows.
+	Pending(ctx context.Context, hash string) (models.Invitation, error)
+	// Accept marks invitation id as redeemed by userID.
+	Accept(ctx context.Context, id, userID int) error
+}
+
+// LoginThrottleRepository counts failed logins per kind and value, an email
+// address or a client IP.
+type LoginThrottleRepository interface {
+	// List returns the counters, most recent failure first; lockedOnly
+	// keeps those still locked.
+	List(ctx context.Context, lockedOnly bool) ([]models.LoginThrottle, error)
+	// LockedUntil returns the latest running lockout of the email or the
+	// IP, or nil.
+	LockedUntil(ctx context.Context, email, ip string) (*time.Time, error)
+	// Fail counts a failure and returns the count, which restarts at 1 when
+	// the previous failure is older than since.
+	Fail(ctx context.Context, kind, value string, since time.Time) (int, error)
+	Lock(ctx context.Context, kind, value string, until time.Time) error
+	// Clear drops the counter of kind and value.
+	Clear(ctx context.Context, kind, value string) error
+	// Delete drops counter id, or returns pgx.ErrNoRows.
+	Delete(ctx context.Context, id int) error
+}
+
+// OIDCRequestRepository keeps the secrets of single sign-on logins between
+// the redirect to the identity provider and its callback.
+type OIDCRequestRepository interface {
+	// Create stores r and sweeps expired requests left by abandoned logins.
+	Create(ctx context.Context, r models.OIDCAuthRequest) error
+	// Take removes and returns the unexpired request with state hash, or
+	// pgx.ErrNoRows, so every state is used once.
+	Take(ctx context.Context, stateHash string) (models.OIDCAuthRequest, error)
+}
+
+// PermissionRepository edits the role to permission mapping, which
+// PrincipalRepository.RolePermissions reads.
+type PermissionRepository interface {
+	// List returns every permission, by name.
+	List(ctx context.Context) ([]models.Permission, error)
+	// SetRole grants role exactly permissions.
+	SetRole(ctx context.Context, role string, permissions []string) error
+}
+
+// UnitOfWork runs fn atomically. Repository calls made with the ctx passed to
+// fn take part in the unit of work; if fn fails, none of their changes stay.
+// fn may run more than once, see database.InTx.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Repositories bundles one implementation of every repository.
+type Repositories struct {
+	Programs    ProgramRepository
+	Lecturers   LecturerRepository
+	Enrollments EnrollmentRepository
+	Assessments AssessmentRepository
+	Owners      OwnershipRepository
+	Principals  PrincipalRepository
+
+	Users          UserRepository
+	Sessions       SessionRepository
+	PasswordResets PasswordResetRepository
+	Invitations    InvitationRepository
+	LoginThrottles LoginThrottleRepository
+	OIDCRequests   OIDCRequestRepository
+	Permissions    PermissionRepository
+
+	Tx UnitOfWork
+}
+
+func NewPostgres(db *database.Database) *Repositories {
+	return &Repositories{
+		Programs:    &pgPrograms{db: db},
+		Lecturers:   &pgLecturers{db: db},
+		Enrollments: &pgEnrollments{db: db},
+		Assessments: &pgAssessments{db: db},
+		Owners:      &pgOwnership{db: db},
+		Principals:  &pgPrincipals{db: db},
+
+		Users:          &pgUsers{db: db},
+		Sessions:       &pgSessions{db: db},
+		PasswordResets: &pgPasswordResets{db: db},
+		Invitations:    &pgInvitations{db: db},
+		LoginThrottles: &pgLoginThrottles{db: db},
+		OIDCRequests:   &pgOIDCRequests{db: db},
+		Permissions:    &pgPermissions{db: db},
+
+		Tx: &pgUnitOfWork{db: db},
+	}
+}
+
+type pgUnitOfWork struct {
+	db *database.Database
+}
+
+func (u *pgUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.db.InTx(ctx, fn)
+}
+
+// insert runs fn in a transaction, a savepoint inside a unit of work, and
+// records the new row of table in the audit log, attributed to the actor in
+// ctx.
+func insert(ctx context.Context, db *database.Database, table string, fn func(tx pgx.Tx) (int, error)) (int, error) {
+	var id int
+	err := pgx.BeginFunc(ctx, db.Conn(ctx), func(tx pgx.Tx) error {
+		var err error
+		if id, err = fn(tx); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, models.AuditActionCreate, table, id, nil)
+	})
+	return id, err
+}
+
+// mutate locks and snapshots row id of table, runs fn and records the change
+// in the audit log, all in one transaction. A missing row is pgx.ErrNoRows,
+// one at a version ctx does not expect ErrVersionMismatch.
+func mutate(ctx context.Context, db *database.Database, action, table string, id int, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, db.Conn(ctx), func(tx pgx.Tx) error {
+		before, err := audit.Snapshot(ctx, tx, table, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(ctx, before); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, action, table, id, before)
+	})
+}
+
+// remove deletes row id of table through mutate.
+func remove(ctx context.Context, db *database.Database, table string, id int) error {
+	return mutate(ctx, db, models.AuditActionDelete, table, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM "`+table+`" WHERE id = $1`, id)
+		return err
+	})
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/database"
+	"mbkm-api/models"
+)
+
+type pgSessions struct {
+	db *database.Database
+}
+
+func (r *pgSessions) Create(ctx context.Context, s models.Session) (int, error) {
+	var id int
+	query := `
+		INSERT INTO "user_session" (user_id, refresh_token_hash, user_agent, ip_address, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, s.UserID, s.RefreshTokenHash, s.UserAgent, s.IPAddress, s.ExpiresAt).Scan(&id)
+	return id, err
+}
+
+func (r *pgSessions) ByRefreshToken(ctx context.Context, hash string) (models.Session, models.User, error) {
+	var s models.Session
+	var u models.User
+	query := `
+		SELECT s.id, s.user_id, s.expires_at, u.id, u.username, u.email, u.role, u.is_active, u.totp_enabled
+		FROM "user_session" s
+		JOIN "user" u ON u.id = s.user_id
+		WHERE s.refresh_token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+	`
+	err := r.db.Conn(ctx).QueryRow(ctx, query, hash).Scan(&s.ID, &s.UserID, &s.ExpiresAt, &u.ID, &u.Username, &u.Email, &u.Role, &u.IsActive, &u.TOTPEnabled)
+	return s, u, err
+}
+
+func (r *pgSessions) Rotate(ctx context.Context, id int, oldHash, newHash string) (bool, error) {
+	result, err := r.db.Conn(ctx).Exec(ctx, `
+		UPDATE "user_session"
+		SET refresh_token_hash = $1, last_used_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND refresh_token_hash = $3 AND revoked_at IS NULL
+	`, newHash, id, oldHash)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *pgSessions) Revoke(ctx context.Context, userID, id int) error {
+	query := `UPDATE "user_session" SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.Conn(ctx).Exec(ctx, query, id, userID)
+	return err
+}
+
+func (r *pgSessions) RevokeAll(ctx context.Context, userID, keep int) (int64, error) {
+	query := `UPDATE "user_session" SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+	result, err := r.db.Conn(ctx).Exec(ctx, query, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"mbkm-api/audit"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type pgUsers struct {
+	db *database.Database
+}
+
+// userColumns are the columns scanUser reads, in order; credentials are
+// only read by Get and the other single-user lookups.
+const (
+	userColumns           = `id, username, email, COALESCE(full_name, ''), COALESCE(phone, ''), role, is_active, must_change_password, totp_enabled, created_at, updated_at`
+	userCredentialColumns = userColumns + `, password_hash, COALESCE(totp_secret, ''), totp_last_step, oidc_issuer, oidc_subject`
+)
+
+func scanUser(row pgx.Row, u *models.User) error {
+	return row.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.Phone, &u.Role, &u.IsActive, &u.MustChangePassword, &u.TOTPEnabled, &u.CreatedAt, &u.UpdatedAt)
+}
+
+func scanUserCredentials(row pgx.Row, u *models.User) error {
+	return row.Scan(&u.ID, &u.Username, &u.Email, &u.FullName, &u.Phone, &u.Role, &u.IsActive, &u.MustChangePassword, &u.TOTPEnabled, &u.CreatedAt, &u.UpdatedAt,
+		&u.PasswordHash, &u.TOTPSecret, &u.TOTPLastStep, &u.OIDCIssuer, &u.OIDCSubject)
+}
+
+// UserFilter narrows UserRepository.List. Nil and empty fields do not
+// filter.
+type UserFilter struct {
+	Role     string
+	IsActive *bool
+}
+
+var userSorts = sortSpec[models.User]{
+	fields: map[string]sortField[models.User]{
+		"id":         {"id", sortInt, func(u models.User) any { return u.ID }},
+		"username":   {"username", sortString, func(u models.User) any { return u.Username }},
+		"full_name":  {"full_name", sortString, func(u models.User) any { return u.FullName }},
+		"created_at": {"created_at", sortTime, func(u models.User) any { return u.CreatedAt }},
+	},
+	def: "id",
+	id:  func(u models.User) int { return u.ID },
+}
+
+func (r *pgUsers) List(ctx context.Context, filter UserFilter, opts ListOptions) (Page[models.User], error) {
+	where := []string{}
+	args := []any{}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		where = append(where, "role = $"+strconv.Itoa(len(args)))
+	}
+	if filter.IsActive != nil {
+		args = append(args, *filter.IsActive)
+		where = append(where, "is_active = $"+strconv.Itoa(len(args)))
+	}
+
+	return pgList(ctx, r.db.Conn(ctx), "user", userColumns, where, args, userSorts, opts, scanUser)
+}
+
+func (r *pgUsers) get(ctx context.Context, where string, args ...any) (models.User, error) {
+	var u models.User
+	err := scanUserCredentials(r.db.Conn(ctx).QueryRow(ctx, `SELECT `+userCredentialColumns+` FROM "user" WHERE `+where, args...), &u)
+	return u, err
+}
+
+func (r *pgUsers) Get(ctx context.Context, id int) (models.User, error) {
+	return r.get(ctx, "id = $1", id)
+}
+
+func (r *pgUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.get(ctx, "LOWER(email) = LOWER($1)", email)
+}
+
+func (r *pgUsers) GetByOIDC(ctx context.Context, issuer, subject string) (models.User, error) {
+	return r.get(ctx, "oidc_issuer = $1 AND oidc_subject = $2", issuer, subject)
+}
+
+func (r *pgUsers) Create(ctx context.Context, u models.User) (int, error) {
+	// A savepoint keeps a unit of work usable after a duplicate username,
+	// which callers may retry with another one.
+	var id int
+	err := pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO "user" (username, email, password_hash, full_name, phone, role, oidc_issuer, oidc_subject, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+			RETURNING id
+		`
+		return tx.QueryRow(ctx, query, u.Username, u.Email, u.PasswordHash, u.FullName, u.Phone, u.Role, u.OIDCIssuer, u.OIDCSubject).Scan(&id)
+	})
+	return id, err
+}
+
+// exec runs a statement on one user and turns "no row" into pgx.ErrNoRows.
+func (r *pgUsers) exec(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.Conn(ctx).Exec(ctx, query, args...)
+	if err == nil && result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return err
+}
+
+func (r *pgUsers) Update(ctx context.Context, id int, req models.UpdateUserRequest) error {
+	query := `
+		UPDATE "user" SET
+			username = COALESCE($1, username),
+			email = COALESCE($2, email),
+			full_name = COALESCE($3, full_name),
+			phone = COALESCE($4, phone),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`
+	return r.exec(ctx, query, req.Username, req.Email, req.FullName, req.Phone, id)
+}
+
+func (r *pgUsers) SetRole(ctx context.Context, id int, role string) error {
+	return r.exec(ctx, `UPDATE "user" SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, role, id)
+}
+
+func (r *pgUsers) SetActive(ctx context.Context, id int, active bool) error {
+	return r.exec(ctx, `UPDATE "user" SET is_active = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, active, id)
+}
+
+func (r *pgUsers) SetPassword(ctx context.Context, id int, hash string, mustChange bool) error {
+	return r.exec(ctx, `UPDATE "user" SET password_hash = $1, must_change_password = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`, hash, mustChange, id)
+}
+
+func (r *pgUsers) LinkOIDC(ctx context.Context, id int, issuer, subject string) error {
+	return r.exec(ctx, `UPDATE "user" SET oidc_issuer = $1, oidc_subject = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`, issuer, subject, id)
+}
+
+func (r *pgUsers) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	return r.exec(ctx, `UPDATE "user" SET totp_secret = $1, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, secret, id)
+}
+
+func (r *pgUsers) EnableTOTP(ctx context.Context, id int, step int64) error {
+	return r.exec(ctx, `UPDATE "user" SET totp_enabled = true, totp_last_step = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, step, id)
+}
+
+func (r *pgUsers) DisableTOTP(ctx context.Context, id int) error {
+	return pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `UPDATE "user" SET totp_enabled = false, totp_secret = NULL, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM "user_recovery_code" WHERE user_id = $1`, id)
+		return err
+	})
+}
+
+func (r *pgUsers) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	result, err := r.db.Conn(ctx).Exec(ctx, `UPDATE "user" SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`, step, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *pgUsers) ReplaceRecoveryCodes(ctx context.Context, id int, hashes []string) error {
+	return pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM "user_recovery_code" WHERE user_id = $1`, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO "user_recovery_code" (user_id, code_hash, created_at)
+			SELECT $1, h, CURRENT_TIMESTAMP FROM UNNEST($2::text[]) AS h
+		`, id, hashes)
+		return err
+	})
+}
+
+func (r *pgUsers) UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error) {
+	result, err := r.db.Conn(ctx).Exec(ctx, `UPDATE "user_recovery_code" SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, id, hash)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *pgUsers) RecordImpersonation(ctx context.Context, id int, details any) error {
+	return pgx.BeginFunc(ctx, r.db.Conn(ctx), func(tx pgx.Tx) error {
+		return audit.RecordEvent(ctx, tx, models.AuditActionImpersonate, "user", id, details)
+	})
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// ErrVersionMismatch is returned by an update or delete whose context
+// expects versions (WithVersions) the row is no longer at.
+var ErrVersionMismatch = errors.New("row version does not match")
+
+type versionKey struct{}
+
+// WithVersions makes updates and deletes made with ctx fail with
+// ErrVersionMismatch unless the row is at one of versions. It is meant for
+// the single record a request addresses.
+func WithVersions(ctx context.Context, versions ...int) context.Context {
+	return context.WithValue(ctx, versionKey{}, versions)
+}
+
+// checkVersion compares the version of a row, as its snapshot holds it,
+// with the versions ctx expects.
+func checkVersion(ctx context.Context, snapshot json.RawMessage) error {
+	if ctx.Value(versionKey{}) == nil {
+		return nil
+	}
+	var row struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(snapshot, &row); err != nil {
+		return err
+	}
+	return MatchVersion(ctx, row.Version)
+}
+
+// MatchVersion is checkVersion for a row already in hand, such as one a
+// caller decided not to write.
+func MatchVersion(ctx context.Context, version int) error {
+	versions, ok := ctx.Value(versionKey{}).([]int)
+	if ok && !slices.Contains(versions, version) {
+		return ErrVersionMismatch
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package routes_test
+
+import (
+	"context"
+	"mbkm-api/models"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	everyone = []principal{asAdmin, asLecturer, asStudent, asAPIKey, asImpersonate}
+	// public also lets anonymous callers through.
+	public = append([]principal{anonymous}, everyone...)
+)
+
+// access is who gets past authentication, permission, scope and ownership
+// checks on one route. The request targets records the lecturer teaches and
+// the student is enrolled in, so it is only the route that decides.
+type access struct {
+	path    func(f *fixture) string
+	body    func(f *fixture) string
+	allowed []principal
+}
+
+func fixed(p string) func(f *fixture) string {
+	return func(*fixture) string { return p }
+}
+
+func withID(format string, id func(f *fixture) int) func(f *fixture) string {
+	return func(f *fixture) string { return path(format, id(f)) }
+}
+
+// routeAccess covers every route SetupRoutes registers, keyed by method and
+// path as Fiber lists them. The API key belongs to an admin service account
+// scoped to programs:read and enrollments:*; the impersonation token is the
+// admin viewing the API as the student.
+var routeAccess = map[string]access{
+	// Public.
+	"GET /health":                          {path: fixed("/health"), allowed: public},
+	"GET /.well-known/jwks.json":           {path: fixed("/.well-known/jwks.json"), allowed: public},
+	"GET /swagger/*":                       {path: fixed("/swagger/doc.json"), allowed: public},
+	"POST /api/v1/auth/register":           {path: fixed("/api/v1/auth/register"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/login":              {path: fixed("/api/v1/auth/login"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/login/2fa":          {path: fixed("/api/v1/auth/login/2fa"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/refresh":            {path: fixed("/api/v1/auth/refresh"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/invitations/accept": {path: fixed("/api/v1/auth/invitations/accept"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/forgot-password":    {path: fixed("/api/v1/auth/forgot-password"), body: fixed(`{}`), allowed: public},
+	"POST /api/v1/auth/reset-password":     {path: fixed("/api/v1/auth/reset-password"), body: fixed(`{}`), allowed: public},
+	"GET /api/v1/auth/me":                  {path: fixed("/api/v1/auth/me"), allowed: []principal{asAdmin, asLecturer, asStudent, asImpersonate}},
+	"POST /api/v1/auth/logout":             {path: fixed("/api/v1/auth/logout"), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/logout-all":         {path: fixed("/api/v1/auth/logout-all"), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/2fa/setup":          {path: fixed("/api/v1/auth/2fa/setup"), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/2fa/confirm":        {path: fixed("/api/v1/auth/2fa/confirm"), body: fixed(`{}`), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"PUT /api/v1/auth/me":                  {path: fixed("/api/v1/auth/me"), body: fixed(`{}`), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/me/password":        {path: fixed("/api/v1/auth/me/password"), body: fixed(`{}`), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/2fa/disable":        {path: fixed("/api/v1/auth/2fa/disable"), body: fixed(`{}`), allowed: []principal{asAdmin, asLecturer, asStudent}},
+	"POST /api/v1/auth/2fa/recovery-codes": {path: fixed("/api/v1/auth/2fa/recovery-codes"), body: fixed(`{}`), allowed: []principal{asAdmin, asLecturer, asStudent}},
+
+	// Administration. The API key lacks the users and lockouts scopes and the
+	// rest is closed to API keys altogether.
+	"GET /api/v1/users/":                              {path: fixed("/api/v1/users"), allowed: []principal{asAdmin}},
+	"POST /api/v1/users/":                             {path: fixed("/api/v1/users"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"GET /api/v1/users/invitations":                   {path: fixed("/api/v1/users/invitations"), allowed: []principal{asAdmin}},
+	"POST /api/v1/users/invitations":                  {path: fixed("/api/v1/users/invitations"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"DELETE /api/v1/users/invitations/:id":            {path: fixed("/api/v1/users/invitations/1"), allowed: []principal{asAdmin}},
+	"GET /api/v1/users/:id":                           {path: fixed("/api/v1/users/5"), allowed: []principal{asAdmin}},
+	"PUT /api/v1/users/:id":                           {path: fixed("/api/v1/users/5"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"PUT /api/v1/users/:id/role":                      {path: fixed("/api/v1/users/5/role"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"PUT /api/v1/users/:id/status":                    {path: fixed("/api/v1/users/5/status"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"POST /api/v1/users/:id/reset-password":           {path: fixed("/api/v1/users/5/reset-password"), allowed: []principal{asAdmin}},
+	"POST /api/v1/users/:id/impersonate":              {path: fixed("/api/v1/users/5/impersonate"), allowed: []principal{asAdmin}},
+	"GET /api/v1/lockouts/":                           {path: fixed("/api/v1/lockouts"), allowed: []principal{asAdmin}},
+	"DELETE /api/v1/lockouts/:id":                     {path: fixed("/api/v1/lockouts/1"), allowed: []principal{asAdmin}},
+	"GET /api/v1/service-accounts/":                   {path: fixed("/api/v1/service-accounts"), allowed: []principal{asAdmin}},
+	"POST /api/v1/service-accounts/":                  {path: fixed("/api/v1/service-accounts"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"PUT /api/v1/service-accounts/:id":                {path: fixed("/api/v1/service-accounts/1"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"GET /api/v1/service-accounts/:id/keys":           {path: fixed("/api/v1/service-accounts/1/keys"), allowed: []principal{asAdmin}},
+	"POST /api/v1/service-accounts/:id/keys":          {path: fixed("/api/v1/service-accounts/1/keys"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"DELETE /api/v1/service-accounts/:id/keys/:keyId": {path: fixed("/api/v1/service-accounts/1/keys/1"), allowed: []principal{asAdmin}},
+	"GET /api/v1/permissions/":                        {path: fixed("/api/v1/permissions"), allowed: []principal{asAdmin}},
+	"GET /api/v1/roles/":                              {path: fixed("/api/v1/roles"), allowed: []principal{asAdmin}},
+	"PUT /api/v1/roles/:role/permissions":             {path: fixed("/api/v1/roles/kaprodi/permissions"), body: fixed(`{}`), allowed: []principal{asAdmin}},
+	"GET /api/v1/audit-logs/":                         {path: fixed("/api/v1/audit-logs"), allowed: []principal{asAdmin}},
+	"GET /api/v1/audit-logs/verify":                   {path: fixed("/api/v1/audit-logs/verify"), allowed: []principal{asAdmin}},
+	"GET /api/v1/audit-logs/:id":                      {path: fixed("/api/v1/audit-logs/1"), allowed: []principal{asAdmin}},
+
+	// Academic records.
+	"GET /api/v1/programs/":                      {path: fixed("/api/v1/programs"), allowed: everyone},
+	"GET /api/v1/programs/search":                {path: fixed("/api/v1/programs/search?q=magang"), allowed: everyone},
+	"GET /api/v1/programs/:id":                   {path: withID("/api/v1/programs/{id}", func(f *fixture) int { return f.program }), allowed: everyone},
+	"POST /api/v1/programs/":                     {path: fixed("/api/v1/programs"), body: programBody("MBKM-09", func(f *fixture) int { return f.lecturer }), allowed: []principal{asAdmin, asLecturer}},
+	"PUT /api/v1/programs/:id":                   {path: withID("/api/v1/programs/{id}", func(f *fixture) int { return f.program }), body: fixed(`{"code":"MBKM-01","name":"Magang","credits":20,"semester":5}`), allowed: []principal{asAdmin, asLecturer}},
+	"PATCH /api/v1/programs/:id":                 {path: withID("/api/v1/programs/{id}", func(f *fixture) int { return f.program }), body: fixed(`{"name":"Magang"}`), allowed: []principal{asAdmin, asLecturer}},
+	"DELETE /api/v1/programs/:id":                {path: withID("/api/v1/programs/{id}", func(f *fixture) int { return f.spareProgram }), allowed: []principal{asAdmin}},
+	"GET /api/v1/lecturers/":                     {path: fixed("/api/v1/lecturers"), allowed: []principal{asAdmin, asLecturer, asStudent, asImpersonate}},
+	"GET /api/v1/lecturers/:id":                  {path: withID("/api/v1/lecturers/{id}", func(f *fixture) int { return f.lecturer }), allowed: []principal{asAdmin, asLecturer, asStudent, asImpersonate}},
+	"POST /api/v1/lecturers/":                    {path: fixed("/api/v1/lecturers"), body: fixed(`{"user_id":6,"nidn":"0066","full_name":"Dr. Citra"}`), allowed: []principal{asAdmin}},
+	"PUT /api/v1/lecturers/:id":                  {path: withID("/api/v1/lecturers/{id}", func(f *fixture) int { return f.lecturer }), body: fixed(`{"nidn":"0011","full_name":"Dr. Ani"}`), allowed: []principal{asAdmin}},
+	"PATCH /api/v1/lecturers/:id":                {path: withID("/api/v1/lecturers/{id}", func(f *fixture) int { return f.lecturer }), body: fixed(`{"phone":"0812"}`), allowed: []principal{asAdmin}},
+	"DELETE /api/v1/lecturers/:id":               {path: withID("/api/v1/lecturers/{id}", func(f *fixture) int { return f.lecturer }), allowed: []principal{asAdmin}},
+	"GET /api/v1/enrollments/":                   {path: fixed("/api/v1/enrollments"), allowed: []principal{asAdmin, asLecturer, asAPIKey}},
+	"GET /api/v1/enrollments/student/:studentId": {path: withID("/api/v1/enrollments/student/{id}", func(*fixture) int { return studentID }), allowed: everyone},
+	"GET /api/v1/enrollments/:id":                {path: withID("/api/v1/enrollments/{id}", func(f *fixture) int { return f.enrollment }), allowed: everyone},
+	"POST /api/v1/enrollments/": {
+		path: fixed("/api/v1/enrollments"),
+		body: func(f *fixture) string {
+			return `{"student_id":` + strconv.Itoa(studentID) + `,"program_id":` + strconv.Itoa(f.spareProgram) + `}`
+		},
+		allowed: []principal{asAdmin, asLecturer, asStudent, asAPIKey},
+	},
+	"PUT /api/v1/enrollments/:id/status":               {path: withID("/api/v1/enrollments/{id}/status", func(f *fixture) int { return f.enrollment }), body: fixed(`{"status":"active"}`), allowed: []principal{asAdmin, asLecturer, asAPIKey}},
+	"DELETE /api/v1/enrollments/:id":                   {path: withID("/api/v1/enrollments/{id}", func(f *fixture) int { return f.enrollment }), allowed: []principal{asAdmin, asAPIKey}},
+	"GET /api/v1/assessments/enrollment/:enrollmentId": {path: withID("/api/v1/assessments/enrollment/{id}", func(f *fixture) int { return f.enrollment }), allowed: []principal{asAdmin, asLecturer, asStudent, asImpersonate}},
+	"GET /api/v1/assessments/:id":                      {path: withID("/api/v1/assessments/{id}", func(f *fixture) int { return f.assessment }), allowed: []principal{asAdmin, asLecturer, asStudent, asImpersonate}},
+	"POST /api/v1/assessments/": {
+		path: fixed("/api/v1/assessments"),
+		body: func(f *fixture) string {
+			return `{"enrollment_id":` + strconv.Itoa(f.enrollment) + `,"category":"final","score":90,"max_score":100}`
+		},
+		allowed: []principal{asAdmin, asLecturer},
+	},
+	"PUT /api/v1/assessments/:id":    {path: withID("/api/v1/assessments/{id}", func(f *fixture) int { return f.assessment }), body: fixed(`{"score":85,"max_score":100}`), allowed: []principal{asAdmin, asLecturer}},
+	"DELETE /api/v1/assessments/:id": {path: withID("/api/v1/assessments/{id}", func(f *fixture) int { return f.assessment }), allowed: []principal{asAdmin, asLecturer}},
+}
+
+func programBody(code string, lecturer func(f *fixture) int) func(f *fixture) string {
+	return func(f *fixture) string {
+		return `{"code":"` + code + `","name":"Program","credits":10,"semester":5,"lecturer_id":` + strconv.Itoa(lecturer(f)) + `}`
+	}
+}
+
+// isDenial reports whether status is an authentication or authorization
+// failure rather than anything the handler itself answered.
+func isDenial(status int) bool {
+	return status == fiber.StatusUnauthorized || status == fiber.StatusForbidden
+}
+
+func TestRouteAccess(t *testing.T) {
+	registered := map[string]bool{}
+	for _, r := range newFixture(t).app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if _, ok := routeAccess[key]; !ok {
+			t.Errorf("%s has no access expectation", key)
+		}
+	}
+	for key := range routeAccess {
+		if !registered[key] {
+			t.Errorf("%s is expected but not registered", key)
+		}
+	}
+
+	for key, rule := range routeAccess {
+		method, _, _ := strings.Cut(key, " ")
+		for _, p := range public {
+			want := slices.Contains(rule.allowed, p)
+			who := string(p)
+			if p == anonymous {
+				who = "anonymous"
+			}
+			t.Run(key+" as "+who, func(t *testing.T) {
+				f := newFixture(t)
+				req := request{as: p, method: method, path: rule.path(f)}
+				if rule.body != nil {
+					req.body = rule.body(f)
+				}
+
+				res := f.do(t, req)
+				if got := !isDenial(res.status); got != want {
+					t.Errorf("status %d (%s), allowed = %v, want %v", res.status, res.Message, got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestOwnershipDenials sends requests the route permits for the role but
+// that target someone else's records.
+func TestOwnershipDenials(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name: "student reads another student's enrollments",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/enrollments/student/{id}", otherStudentID)}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "student reads another student's enrollment",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/enrollments/{id}", f.otherEnrollment)}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "student reads another student's assessments",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/assessments/enrollment/{id}", f.otherEnrollment)}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "impersonated student reads another student's enrollments",
+			req: func(f *fixture) request {
+				return request{as: asImpersonate, method: "GET", path: path("/api/v1/enrollments/student/{id}", otherStudentID)}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "student enrolls another student",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "POST", path: "/api/v1/enrollments", body: `{"student_id":5,"program_id":` + strconv.Itoa(f.spareProgram) + `}`}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer grades in a program they do not teach",
+			req: func(f *fixture) request {
+				body := `{"enrollment_id":` + strconv.Itoa(f.otherEnrollment) + `,"category":"quiz","score":1,"max_score":10}`
+				return request{as: asLecturer, method: "POST", path: "/api/v1/assessments", body: body}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer changes an enrollment they do not teach",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/enrollments/{id}/status", f.otherEnrollment), body: `{"status":"dropped"}`}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer enrolls into a program they do not teach",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "POST", path: "/api/v1/enrollments", body: `{"student_id":4,"program_id":` + strconv.Itoa(f.otherProgram) + `}`}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer edits a program they do not teach",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PATCH", path: path("/api/v1/programs/{id}", f.otherProgram), body: `{"name":"Diambil alih"}`}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer creates a program for someone else",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "POST", path: "/api/v1/programs", body: programBody("MBKM-09", func(f *fixture) int { return f.otherLecturer })(f)}
+			},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name: "lecturer sees only enrollments they teach",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "GET", path: path("/api/v1/enrollments/student/{id}", otherStudentID)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				if res.Pagination.Total != 0 {
+					t.Errorf("lecturer sees %d enrollments of a student they do not teach", res.Pagination.Total)
+				}
+			},
+		},
+	})
+}
+
+func TestRegradeOwnership(t *testing.T) {
+	f := newFixture(t)
+	id, err := f.repos.Assessments.Create(context.Background(), models.CreateAssessmentRequest{EnrollmentID: f.otherEnrollment, Category: "quiz", Score: 5, MaxScore: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []request{
+		{as: asLecturer, method: "PUT", path: path("/api/v1/assessments/{id}", id), body: `{"score":10,"max_score":10}`},
+		{as: asLecturer, method: "DELETE", path: path("/api/v1/assessments/{id}", id)},
+	} {
+		if res := f.do(t, r); res.status != fiber.StatusForbidden {
+			t.Errorf("%s %s: status %d (%s), want 403", r.method, r.path, res.status, res.Message)
+		}
+	}
+}
+
+// TestDeleteOwnership grants lecturers the delete permissions, which they do
+// not have by default, to reach the ownership checks behind them.
+func TestDeleteOwnership(t *testing.T) {
+	cases := []struct {
+		name   string
+		path   func(f *fixture) string
+		status int
+	}{
+		{"program taught by someone else", withID("/api/v1/programs/{id}", func(f *fixture) int { return f.otherProgram }), fiber.StatusForbidden},
+		{"own program", withID("/api/v1/programs/{id}", func(f *fixture) int { return f.spareProgram }), fiber.StatusOK},
+		{"enrollment in someone else's program", withID("/api/v1/enrollments/{id}", func(f *fixture) int { return f.otherEnrollment }), fiber.StatusForbidden},
+		{"enrollment in own program", withID("/api/v1/enrollments/{id}", func(f *fixture) int { return f.enrollment }), fiber.StatusConflict},
+		{"another lecturer", withID("/api/v1/lecturers/{id}", func(f *fixture) int { return f.otherLecturer }), fiber.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.mem.Grant(models.RoleLecturer, models.PermProgramDelete, models.PermEnrollmentDelete, models.PermLecturerManage)
+
+			res := f.do(t, request{as: asLecturer, method: "DELETE", path: tc.path(f)})
+			if res.status != tc.status {
+				t.Fatalf("status %d (%s), want %d", res.status, res.Message, tc.status)
+			}
+		})
+	}
+
+	t.Run("another lecturer's record", func(t *testing.T) {
+		f := newFixture(t)
+		f.mem.Grant(models.RoleLecturer, models.PermLecturerManage)
+
+		res := f.do(t, request{as: asLecturer, method: "PUT", path: path("/api/v1/lecturers/{id}", f.otherLecturer), body: `{"nidn":"0022","full_name":"Budi"}`})
+		if res.status != fiber.StatusForbidden {
+			t.Fatalf("status %d (%s), want 403", res.status, res.Message)
+		}
+	})
+}
@@ -0,0 +1,478 @@
+package routes_test
+
+import (
+	"context"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// login signs in through the API and returns the token pair.
+func (f *fixture) login(t *testing.T, email, password string) (models.LoginResponse, response) {
+	t.Helper()
+	res := f.do(t, request{method: "POST", path: "/api/v1/auth/login", body: `{"email":"` + email + `","password":"` + password + `"}`})
+	var login models.LoginResponse
+	if res.status == fiber.StatusOK {
+		res.decode(t, &login)
+	}
+	return login, res
+}
+
+// bearer is the header of a request made with token.
+func bearer(token string) []string {
+	return []string{fiber.HeaderAuthorization, "Bearer " + token}
+}
+
+func TestSessions(t *testing.T) {
+	f := newFixture(t)
+
+	login, res := f.login(t, "citra@univ.ac.id", password)
+	if res.status != fiber.StatusOK || login.User.ID != studentID || login.Token == "" || login.RefreshToken == "" {
+		t.Fatalf("login: status %d (%s), %+v", res.status, res.Message, login.User)
+	}
+
+	res = f.do(t, request{method: "GET", path: "/api/v1/auth/me", header: bearer(login.Token)})
+	var me models.User
+	res.decode(t, &me)
+	if res.status != fiber.StatusOK || me.Username != "citra" {
+		t.Fatalf("me: status %d, %+v", res.status, me)
+	}
+
+	refresh := func(token string) response {
+		return f.do(t, request{method: "POST", path: "/api/v1/auth/refresh", body: `{"refresh_token":"` + token + `"}`})
+	}
+	res = refresh(login.RefreshToken)
+	var rotated models.TokenResponse
+	res.decode(t, &rotated)
+	if res.status != fiber.StatusOK || rotated.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh: status %d (%s)", res.status, res.Message)
+	}
+	if res := refresh(login.RefreshToken); res.status != fiber.StatusUnauthorized {
+		t.Errorf("reused refresh token: status %d, want 401", res.status)
+	}
+
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/logout", header: bearer(rotated.Token)}); res.status != fiber.StatusOK {
+		t.Fatalf("logout: status %d (%s)", res.status, res.Message)
+	}
+	if res := refresh(rotated.RefreshToken); res.status != fiber.StatusUnauthorized {
+		t.Errorf("refresh after logout: status %d, want 401", res.status)
+	}
+	if res := f.do(t, request{method: "GET", path: "/api/v1/auth/me", header: bearer(rotated.Token)}); res.status != fiber.StatusUnauthorized {
+		t.Errorf("access token after logout: status %d, want 401", res.status)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	f := newFixture(t)
+
+	for i := 0; i < 3; i++ {
+		if _, res := f.login(t, "ani@univ.ac.id", "salah"); res.status != fiber.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i+1, res.status)
+		}
+	}
+	_, res := f.login(t, "ani@univ.ac.id", password)
+	if res.status != fiber.StatusTooManyRequests || res.header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Fatalf("locked login: status %d, Retry-After %q", res.status, res.header.Get(fiber.HeaderRetryAfter))
+	}
+
+	res = f.do(t, request{as: asAdmin, method: "GET", path: "/api/v1/lockouts"})
+	var lockouts []models.LoginThrottle
+	res.decode(t, &lockouts)
+	if len(lockouts) != 1 || lockouts[0].Value != "ani@univ.ac.id" {
+		t.Fatalf("lockouts = %+v", lockouts)
+	}
+
+	if res := f.do(t, request{as: asAdmin, method: "DELETE", path: path("/api/v1/lockouts/{id}", lockouts[0].ID)}); res.status != fiber.StatusOK {
+		t.Fatalf("clear: status %d (%s)", res.status, res.Message)
+	}
+	if _, res := f.login(t, "ani@univ.ac.id", password); res.status != fiber.StatusOK {
+		t.Errorf("login after clearing: status %d (%s)", res.status, res.Message)
+	}
+	if res := f.do(t, request{as: asAdmin, method: "DELETE", path: path("/api/v1/lockouts/{id}", lockouts[0].ID)}); res.status != fiber.StatusNotFound {
+		t.Errorf("clearing twice: status %d, want 404", res.status)
+	}
+}
+
+func TestLoginLockoutIgnoresEmailCase(t *testing.T) {
+	f := newFixture(t)
+
+	for _, email := range []string{"ANI@univ.ac.id", "Ani@Univ.ac.id", "ani@UNIV.AC.ID"} {
+		if _, res := f.login(t, email, "salah"); res.status != fiber.StatusUnauthorized {
+			t.Fatalf("%s: status %d, want 401", email, res.status)
+		}
+	}
+	for _, email := range []string{"ani@univ.ac.id", "ANI@UNIV.AC.ID"} {
+		if _, res := f.login(t, email, password); res.status != fiber.StatusTooManyRequests {
+			t.Errorf("%s after three failures: status %d, want 429", email, res.status)
+		}
+	}
+}
+
+type brokenThrottles struct {
+	repository.LoginThrottleRepository
+}
+
+func (brokenThrottles) Fail(context.Context, string, string, time.Time) (int, error) {
+	return 0, errDatabaseDown
+}
+
+func TestLoginFailureNotRecorded(t *testing.T) {
+	for _, email := range []string{"ani@univ.ac.id", "siapa@univ.ac.id"} {
+		t.Run(email, func(t *testing.T) {
+			f := newFixture(t)
+			f.repos.LoginThrottles = brokenThrottles{f.repos.LoginThrottles}
+
+			_, res := f.login(t, email, "salah")
+			if res.status != fiber.StatusServiceUnavailable {
+				t.Fatalf("status %d (%s), want 503", res.status, res.Message)
+			}
+		})
+	}
+}
+
+// revoked reports whether the student's session no longer authenticates.
+func revoked(t *testing.T, f *fixture) bool {
+	t.Helper()
+	return f.do(t, request{as: asStudent, method: "GET", path: "/api/v1/programs"}).status == fiber.StatusUnauthorized
+}
+
+func TestUsers(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name: "list",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "GET", path: "/api/v1/users?role=student"}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var users []models.User
+				res.decode(t, &users)
+				if len(users) != 2 || res.Pagination.Total != 2 {
+					t.Errorf("got %d students, pagination %+v", len(users), res.Pagination)
+				}
+			},
+		},
+		{
+			name: "get",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "GET", path: path("/api/v1/users/{id}", studentID)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var u models.User
+				res.decode(t, &u)
+				if u.Email != "citra@univ.ac.id" || u.Role != models.RoleStudent {
+					t.Errorf("got %+v", u)
+				}
+			},
+		},
+		{
+			name:    "get missing",
+			req:     func(f *fixture) request { return request{as: asAdmin, method: "GET", path: "/api/v1/users/999"} },
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "create",
+			req: func(f *fixture) request {
+				body := `{"username":"fajar","email":"fajar@univ.ac.id","password":"Rahasia123","role":"kaprodi"}`
+				return request{as: asAdmin, method: "POST", path: "/api/v1/users", body: body}
+			},
+			status: fiber.StatusCreated,
+			check: func(t *testing.T, f *fixture, res response) {
+				if login, res := f.login(t, "fajar@univ.ac.id", password); res.status != fiber.StatusOK || login.User.Role != models.RoleKaprodi {
+					t.Errorf("login as the new user: status %d, role %q", res.status, login.User.Role)
+				}
+			},
+		},
+		{
+			name: "create duplicate",
+			req: func(f *fixture) request {
+				body := `{"username":"citra","email":"fajar@univ.ac.id","password":"Rahasia123","role":"student"}`
+				return request{as: asAdmin, method: "POST", path: "/api/v1/users", body: body}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeAlreadyExists,
+		},
+		{
+			name: "update",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/users/{id}", studentID), body: `{"full_name":"Citra Lestari"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				u, _ := f.repos.Users.Get(context.Background(), studentID)
+				if u.FullName != "Citra Lestari" || u.Username != "citra" {
+					t.Errorf("stored %+v", u)
+				}
+			},
+		},
+		{
+			name: "update to a taken email",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/users/{id}", studentID), body: `{"email":"dewi@univ.ac.id"}`}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeAlreadyExists,
+		},
+		{
+			name: "update missing",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: "/api/v1/users/999", body: `{"full_name":"Siapa"}`}
+			},
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "change role",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/users/{id}/role", studentID), body: `{"role":"tim_akreditasi"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				u, _ := f.repos.Users.Get(context.Background(), studentID)
+				if u.Role != models.RoleTimAkreditasi || !revoked(t, f) {
+					t.Errorf("role %q, sessions revoked %v", u.Role, revoked(t, f))
+				}
+			},
+		},
+		{
+			name: "change own role",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/users/{id}/role", adminID), body: `{"role":"student"}`}
+			},
+			status: fiber.StatusBadRequest,
+		},
+		{
+			name: "deactivate",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/users/{id}/status", studentID), body: `{"is_active":false}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				if !revoked(t, f) {
+					t.Error("deactivated student still signed in")
+				}
+				if _, res := f.login(t, "citra@univ.ac.id", password); res.status != fiber.StatusForbidden {
+					t.Errorf("login while inactive: status %d, want 403", res.status)
+				}
+			},
+		},
+		{
+			name: "deactivate missing",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: "/api/v1/users/999/status", body: `{"is_active":false}`}
+			},
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "reset password",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: path("/api/v1/users/{id}/reset-password", studentID)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				u, _ := f.repos.Users.Get(context.Background(), studentID)
+				if !u.MustChangePassword || !revoked(t, f) {
+					t.Errorf("must change password %v, sessions revoked %v", u.MustChangePassword, revoked(t, f))
+				}
+				if _, res := f.login(t, "citra@univ.ac.id", password); res.status != fiber.StatusUnauthorized {
+					t.Errorf("login with the old password: status %d, want 401", res.status)
+				}
+			},
+		},
+		{
+			name: "impersonate",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: path("/api/v1/users/{id}/impersonate", studentID), body: `{"reason":"Tiket #12"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var imp models.ImpersonationResponse
+				res.decode(t, &imp)
+				if imp.User.ID != studentID || imp.Token == "" {
+					t.Errorf("got %+v", imp)
+				}
+			},
+		},
+	})
+}
+
+func TestInvitations(t *testing.T) {
+	f := newFixture(t)
+
+	res := f.do(t, request{as: asAdmin, method: "POST", path: "/api/v1/users/invitations", body: `{"email":"gita@univ.ac.id","role":"lecturer"}`})
+	var invitation struct {
+		ID    int
+		Token string
+	}
+	res.decode(t, &invitation)
+	if res.status != fiber.StatusCreated || invitation.Token == "" {
+		t.Fatalf("invite: status %d (%s)", res.status, res.Message)
+	}
+
+	res = f.do(t, request{as: asAdmin, method: "GET", path: "/api/v1/users/invitations"})
+	var pending []models.Invitation
+	res.decode(t, &pending)
+	if len(pending) != 1 || pending[0].Email != "gita@univ.ac.id" || pending[0].InvitedBy == nil || *pending[0].InvitedBy != adminID {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	accept := `{"token":"` + invitation.Token + `","username":"gita","password":"Rahasia123"}`
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/invitations/accept", body: accept}); res.status != fiber.StatusCreated {
+		t.Fatalf("accept: status %d (%s)", res.status, res.Message)
+	}
+	if login, res := f.login(t, "gita@univ.ac.id", password); res.status != fiber.StatusOK || login.User.Role != models.RoleLecturer {
+		t.Errorf("login as the invited user: status %d, role %q", res.status, login.User.Role)
+	}
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/invitations/accept", body: accept}); res.status != fiber.StatusBadRequest {
+		t.Errorf("accepting twice: status %d, want 400", res.status)
+	}
+	if res := f.do(t, request{as: asAdmin, method: "DELETE", path: path("/api/v1/users/invitations/{id}", invitation.ID)}); res.status != fiber.StatusNotFound {
+		t.Errorf("revoking an accepted invitation: status %d, want 404", res.status)
+	}
+
+	res = f.do(t, request{as: asAdmin, method: "POST", path: "/api/v1/users/invitations", body: `{"email":"gita@univ.ac.id","role":"lecturer"}`})
+	if res.status != fiber.StatusConflict {
+		t.Errorf("inviting a registered email: status %d, want 409", res.status)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/forgot-password", body: `{"email":"citra@univ.ac.id"}`}); res.status != fiber.StatusOK {
+		t.Fatalf("forgot password: status %d (%s)", res.status, res.Message)
+	}
+
+	// The mailed token is not observable; issue a known one instead.
+	if err := f.repos.PasswordResets.Issue(ctx, studentID, utils.HashToken("reset-token"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	reset := `{"token":"reset-token","new_password":"Baru12345"}`
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/reset-password", body: reset}); res.status != fiber.StatusOK {
+		t.Fatalf("reset: status %d (%s)", res.status, res.Message)
+	}
+	if !revoked(t, f) {
+		t.Error("sessions survived the reset")
+	}
+	if _, res := f.login(t, "citra@univ.ac.id", "Baru12345"); res.status != fiber.StatusOK {
+		t.Errorf("login with the new password: status %d (%s)", res.status, res.Message)
+	}
+	if res := f.do(t, request{method: "POST", path: "/api/v1/auth/reset-password", body: reset}); res.status != fiber.StatusBadRequest {
+		t.Errorf("reusing the token: status %d, want 400", res.status)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	f := newFixture(t)
+
+	wrong := `{"current_password":"salah","new_password":"Baru12345"}`
+	if res := f.do(t, request{as: asLecturer, method: "POST", path: "/api/v1/auth/me/password", body: wrong}); res.status != fiber.StatusUnauthorized {
+		t.Errorf("wrong current password: status %d, want 401", res.status)
+	}
+
+	body := `{"current_password":"` + password + `","new_password":"Baru12345"}`
+	if res := f.do(t, request{as: asLecturer, method: "POST", path: "/api/v1/auth/me/password", body: body}); res.status != fiber.StatusOK {
+		t.Fatalf("change: status %d (%s)", res.status, res.Message)
+	}
+	if _, res := f.login(t, "ani@univ.ac.id", "Baru12345"); res.status != fiber.StatusOK {
+		t.Errorf("login with the new password: status %d (%s)", res.status, res.Message)
+	}
+	// The session that changed the password stays signed in.
+	if res := f.do(t, request{as: asLecturer, method: "GET", path: "/api/v1/auth/me"}); res.status != fiber.StatusOK {
+		t.Errorf("own session after the change: status %d, want 200", res.status)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	f := newFixture(t)
+
+	res := f.do(t, request{as: asAdmin, method: "PUT", path: "/api/v1/roles/kaprodi/permissions", body: `{"permissions":["program.read","program.read"]}`})
+	if res.status != fiber.StatusOK {
+		t.Fatalf("update: status %d (%s)", res.status, res.Message)
+	}
+
+	res = f.do(t, request{as: asAdmin, method: "GET", path: "/api/v1/roles"})
+	var roles []models.RolePermissionsResponse
+	res.decode(t, &roles)
+	for _, r := range roles {
+		if r.Role == models.RoleKaprodi && (len(r.Permissions) != 1 || r.Permissions[0] != models.PermProgramRead) {
+			t.Errorf("kaprodi has %v", r.Permissions)
+		}
+	}
+
+	res = f.do(t, request{as: asAdmin, method: "GET", path: "/api/v1/permissions"})
+	var catalog []models.Permission
+	res.decode(t, &catalog)
+	if len(catalog) != len(models.PermissionCatalog) {
+		t.Errorf("got %d permissions, want %d", len(catalog), len(models.PermissionCatalog))
+	}
+
+	for _, body := range []string{`{"permissions":["program.fly"]}`, `{"permissions":[]}`} {
+		if res := f.do(t, request{as: asAdmin, method: "PUT", path: "/api/v1/roles/admin/permissions", body: body}); res.status != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, res.status)
+		}
+	}
+}
+
+// errDatabaseDown is what pgx returns while the server is shutting down.
+var errDatabaseDown = &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+
+type brokenUsers struct{ repository.UserRepository }
+
+func (brokenUsers) GetByEmail(context.Context, string) (models.User, error) {
+	return models.User{}, errDatabaseDown
+}
+
+type brokenResets struct {
+	repository.PasswordResetRepository
+}
+
+func (brokenResets) Redeem(context.Context, string) (int, error) { return 0, errDatabaseDown }
+
+// TestAccountDatabaseErrors checks that lookups which answer "not found"
+// quietly still report a failing database.
+func TestAccountDatabaseErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		fail func(repos *repository.Repositories)
+		req  request
+	}{
+		{
+			name: "forgot password",
+			fail: func(repos *repository.Repositories) { repos.Users = brokenUsers{repos.Users} },
+			req:  request{method: "POST", path: "/api/v1/auth/forgot-password", body: `{"email":"citra@univ.ac.id"}`},
+		},
+		{
+			name: "reset password",
+			fail: func(repos *repository.Repositories) { repos.PasswordResets = brokenResets{repos.PasswordResets} },
+			req:  request{method: "POST", path: "/api/v1/auth/reset-password", body: `{"token":"reset-token","new_password":"Baru12345"}`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t)
+			tc.fail(f.repos)
+			res := f.do(t, tc.req)
+			if res.status != fiber.StatusServiceUnavailable || res.Error != utils.ErrCodeUnavailable {
+				t.Fatalf("status %d (%s), want 503", res.status, res.Error)
+			}
+		})
+	}
+
+	t.Run("unknown email", func(t *testing.T) {
+		f := newFixture(t)
+		if res := f.do(t, request{method: "POST", path: "/api/v1/auth/forgot-password", body: `{"email":"siapa@univ.ac.id"}`}); res.status != fiber.StatusOK {
+			t.Errorf("status %d, want 200", res.status)
+		}
+	})
+}
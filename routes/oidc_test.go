@@ -0,0 +1,259 @@
+package routes_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"mbkm-api/config"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcClientID     = "mbkm-api"
+	oidcClientSecret = "s3cret"
+	oidcRedirectURL  = "http://localhost:8080/api/v1/auth/oidc/callback"
+)
+
+// mockProvider is an identity provider that approves every authorization
+// request and signs ID tokens with claims for the current test.
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims
+	codes  map[string]string // code -> nonce
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, codes: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := rand.Text()
+		m.mu.Lock()
+		m.codes[code] = q.Get("nonce")
+		m.mu.Unlock()
+		back := url.Values{"code": {code}, "state": {q.Get("state")}}
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+back.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		nonce := m.codes[r.PostFormValue("code")]
+		claims := jwt.MapClaims{"iss": m.URL, "aud": oidcClientID, "nonce": nonce, "exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		m.mu.Unlock()
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(m.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test-key", "use": "sig", "alg": "RS256",
+			"n": b64(m.key.N.Bytes()), "e": b64(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// configure points the fixture at the provider.
+func (m *mockProvider) configure(cfg *config.Config) {
+	cfg.OIDCIssuerURL = m.URL
+	cfg.OIDCClientID = oidcClientID
+	cfg.OIDCClientSecret = oidcClientSecret
+	cfg.OIDCRedirectURL = oidcRedirectURL
+	cfg.OIDCRoleClaim = "groups"
+	cfg.OIDCRoleMapping = []config.OIDCRoleRule{{Value: "dosen", Role: models.RoleLecturer}}
+	cfg.OIDCDefaultRole = models.RoleStudent
+	cfg.OIDCAutoProvision = true
+	cfg.OIDCAuthRequestTimeout = 600
+}
+
+// ssoLogin plays the browser through /auth/oidc/login and the provider and
+// returns the answer of the callback.
+func (f *fixture) ssoLogin(t *testing.T, m *mockProvider, claims jwt.MapClaims) response {
+	t.Helper()
+	m.mu.Lock()
+	m.claims = claims
+	m.mu.Unlock()
+
+	res := f.do(t, request{method: "GET", path: "/api/v1/auth/oidc/login"})
+	if res.status != fiber.StatusFound {
+		t.Fatalf("login: status %d (%s)", res.status, res.Message)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := browser.Get(res.header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f.do(t, request{method: "GET", path: "/api/v1/auth/oidc/callback?" + callback.RawQuery})
+}
+
+func TestOIDCLogin(t *testing.T) {
+	m := newMockProvider(t)
+	f := newFixture(t, m.configure)
+
+	claims := jwt.MapClaims{"sub": "mhs-2021001", "email": "Rina@Student.Univ.ac.id", "email_verified": true, "name": "Rina Wati"}
+	res := f.ssoLogin(t, m, claims)
+	var login models.LoginResponse
+	res.decode(t, &login)
+	if res.status != fiber.StatusOK || login.User.Role != models.RoleStudent {
+		t.Fatalf("callback: status %d (%s), %+v", res.status, res.Message, login.User)
+	}
+
+	// The provisioned account has no phone; reading it back must not fail.
+	res = f.do(t, request{method: "GET", path: "/api/v1/auth/me", header: bearer(login.Token)})
+	var me models.User
+	res.decode(t, &me)
+	if res.status != fiber.StatusOK || me.Email != "rina@student.univ.ac.id" || me.Username != "rina" || me.Phone != "" || me.FullName != "Rina Wati" {
+		t.Fatalf("me: status %d (%s), %+v", res.status, res.Message, me)
+	}
+	if res := f.do(t, request{as: asAdmin, method: "GET", path: "/api/v1/users"}); res.status != fiber.StatusOK || res.Pagination.Total != 7 {
+		t.Errorf("users: status %d (%s), pagination %+v", res.status, res.Message, res.Pagination)
+	}
+
+	// Signing in again finds the linked account instead of provisioning.
+	res = f.ssoLogin(t, m, claims)
+	var again models.LoginResponse
+	res.decode(t, &again)
+	if res.status != fiber.StatusOK || again.User.ID != login.User.ID {
+		t.Errorf("second login: status %d, user %d, want %d", res.status, again.User.ID, login.User.ID)
+	}
+
+	// The state is single-use.
+	if res := f.do(t, request{method: "GET", path: "/api/v1/auth/oidc/callback?state=unknown&code=x"}); res.status != fiber.StatusBadRequest {
+		t.Errorf("unknown state: status %d, want 400", res.status)
+	}
+}
+
+type brokenOIDCRequests struct {
+	repository.OIDCRequestRepository
+}
+
+func (brokenOIDCRequests) Take(context.Context, string) (models.OIDCAuthRequest, error) {
+	return models.OIDCAuthRequest{}, errDatabaseDown
+}
+
+func TestOIDCCallbackDatabaseDown(t *testing.T) {
+	m := newMockProvider(t)
+	f := newFixture(t, m.configure)
+	f.repos.OIDCRequests = brokenOIDCRequests{f.repos.OIDCRequests}
+
+	res := f.do(t, request{method: "GET", path: "/api/v1/auth/oidc/callback?state=s&code=c"})
+	if res.status != fiber.StatusServiceUnavailable || res.Error != utils.ErrCodeUnavailable {
+		t.Fatalf("status %d (%s), want 503", res.status, res.Error)
+	}
+}
+
+func TestOIDCLinking(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims jwt.MapClaims
+		status int
+		check  func(t *testing.T, user models.User)
+	}{
+		{
+			name:   "verified email links the existing account",
+			claims: jwt.MapClaims{"sub": "dsn-0011", "email": "ANI@univ.ac.id", "email_verified": true, "groups": []string{"dosen"}},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, user models.User) {
+				if user.ID != lecturerID {
+					t.Errorf("signed in as %d, want %d", user.ID, lecturerID)
+				}
+			},
+		},
+		{
+			name:   "unverified email is not linked",
+			claims: jwt.MapClaims{"sub": "dsn-0011", "email": "ani@univ.ac.id", "email_verified": false},
+			status: fiber.StatusForbidden,
+		},
+		{
+			name:   "taken username gets a suffix",
+			claims: jwt.MapClaims{"sub": "mhs-2021002", "email": "citra.baru@univ.ac.id", "email_verified": true, "preferred_username": "citra"},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, user models.User) {
+				if user.ID == studentID || user.Username == "citra" || len(user.Username) <= len("citra-") {
+					t.Errorf("provisioned %+v", user)
+				}
+			},
+		},
+		{
+			name:   "mapped group decides the role",
+			claims: jwt.MapClaims{"sub": "dsn-0099", "email": "hana@univ.ac.id", "email_verified": true, "groups": []string{"dosen"}},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, user models.User) {
+				if user.Role != models.RoleLecturer {
+					t.Errorf("role = %q, want lecturer", user.Role)
+				}
+			},
+		},
+	}
+
+	m := newMockProvider(t)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, m.configure)
+			res := f.ssoLogin(t, m, tc.claims)
+			if res.status != tc.status {
+				t.Fatalf("status %d (%s), want %d", res.status, res.Message, tc.status)
+			}
+			if tc.check != nil {
+				var login models.LoginResponse
+				res.decode(t, &login)
+				tc.check(t, login.User)
+			}
+		})
+	}
+}
@@ -4,24 +4,47 @@ import (
 	"mbkm-api/config"
 	"mbkm-api/database"
 	"mbkm-api/handlers"
+	"mbkm-api/mailer"
 	"mbkm-api/middleware"
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+	"mbkm-api/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/swagger"
 )
 
-func SetupRoutes(app *fiber.App, db *database.Database, cfg *config.Config) {
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	programHandler := handlers.NewProgramHandler(db)
-	enrollmentHandler := handlers.NewEnrollmentHandler(db)
-	assessmentHandler := handlers.NewAssessmentHandler(db)
-	lecturerHandler := handlers.NewLecturerHandler(db)
+// SetupRoutes mounts the API on app. Everything goes through repos except
+// service accounts and the audit log, which still use db directly.
+func SetupRoutes(app *fiber.App, db *database.Database, repos *repository.Repositories, cfg *config.Config, tokens *utils.TokenManager) {
+	mail := mailer.New(cfg)
+	perms := policy.NewResolver(repos.Principals, time.Duration(cfg.PermissionCacheTTL)*time.Second)
+	conditional := middleware.ConditionalMiddleware(cfg.RequireIfMatch)
+
+	authHandler := handlers.NewAuthHandler(repos, cfg, tokens, mail)
+	programHandler := handlers.NewProgramHandler(repos)
+	enrollmentHandler := handlers.NewEnrollmentHandler(repos)
+	assessmentHandler := handlers.NewAssessmentHandler(repos)
+	lecturerHandler := handlers.NewLecturerHandler(repos)
+	userHandler := handlers.NewUserHandler(repos, cfg, mail)
+	lockoutHandler := handlers.NewLockoutHandler(repos)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(db)
+	permissionHandler := handlers.NewPermissionHandler(repos, perms)
+	auditLogHandler := handlers.NewAuditLogHandler(db)
 
 	api := app.Group("/api/v1")
 
 	// Swagger documentation
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
+	// Public verification keys for services that consume our tokens
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(tokens.JWKS())
+	})
+
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
 			"status":  "ok",
@@ -29,38 +52,107 @@ func SetupRoutes(app *fiber.App, db *database.Database, cfg *config.Config) {
 		})
 	})
 
+	authLimit := middleware.RateLimit(cfg.LoginRateLimit, time.Minute)
+
 	auth := api.Group("/auth")
-	auth.Post("/register", authHandler.Register)
-	auth.Post("/login", authHandler.Login)
+	auth.Post("/register", authLimit, authHandler.Register)
+	auth.Post("/login", authLimit, authHandler.Login)
+	auth.Post("/login/2fa", authLimit, authHandler.LoginTwoFactor)
+	auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/invitations/accept", authHandler.AcceptInvitation)
+	auth.Post("/forgot-password", authLimit, authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
+
+	if cfg.OIDCEnabled() {
+		auth.Get("/oidc/login", authLimit, authHandler.OIDCLogin)
+		auth.Get("/oidc/callback", authLimit, authHandler.OIDCCallback)
+	}
 
-	protected := api.Use(middleware.AuthMiddleware(cfg))
+	protected := api.Use(middleware.AuthMiddleware(tokens, repos.Principals, perms))
 
+	// Profile, session and 2FA endpoints belong to a human user.
+	protected.Use("/auth", middleware.UserOnlyMiddleware())
+
+	// Reachable while a mandatory 2FA enrollment is still pending.
 	protected.Get("/auth/me", authHandler.GetMe)
+	protected.Post("/auth/logout", authHandler.Logout)
+	protected.Post("/auth/logout-all", authHandler.LogoutAll)
+	protected.Post("/auth/2fa/setup", authHandler.SetupTwoFactor)
+	protected.Post("/auth/2fa/confirm", authHandler.ConfirmTwoFactor)
+
+	protected.Use(middleware.MFAEnrollmentMiddleware())
+
+	protected.Put("/auth/me", authHandler.UpdateMe)
+	protected.Post("/auth/me/password", authHandler.ChangePassword)
+	protected.Post("/auth/2fa/disable", authHandler.DisableTwoFactor)
+	protected.Post("/auth/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+	users := protected.Group("/users", middleware.RequirePermission(models.PermUserManage), middleware.ScopeMiddleware("users"))
+	users.Get("/", userHandler.GetAll)
+	users.Post("/", userHandler.Create)
+	users.Get("/invitations", userHandler.GetInvitations)
+	users.Post("/invitations", userHandler.CreateInvitation)
+	users.Delete("/invitations/:id", userHandler.RevokeInvitation)
+	users.Get("/:id", userHandler.GetByID)
+	users.Put("/:id", userHandler.Update)
+	users.Put("/:id/role", userHandler.UpdateRole)
+	users.Put("/:id/status", userHandler.UpdateStatus)
+	users.Post("/:id/reset-password", userHandler.ResetPassword)
+	users.Post("/:id/impersonate", middleware.UserOnlyMiddleware(), middleware.RequirePermission(models.PermUserImpersonate), authHandler.Impersonate)
+
+	lockouts := protected.Group("/lockouts", middleware.RequirePermission(models.PermLockoutManage), middleware.ScopeMiddleware("lockouts"))
+	lockouts.Get("/", lockoutHandler.GetAll)
+	lockouts.Delete("/:id", lockoutHandler.Delete)
+
+	serviceAccounts := protected.Group("/service-accounts", middleware.UserOnlyMiddleware(), middleware.RequirePermission(models.PermServiceAccountManage))
+	serviceAccounts.Get("/", serviceAccountHandler.GetAll)
+	serviceAccounts.Post("/", serviceAccountHandler.Create)
+	serviceAccounts.Put("/:id", serviceAccountHandler.Update)
+	serviceAccounts.Get("/:id/keys", serviceAccountHandler.GetKeys)
+	serviceAccounts.Post("/:id/keys", serviceAccountHandler.CreateKey)
+	serviceAccounts.Delete("/:id/keys/:keyId", serviceAccountHandler.RevokeKey)
+
+	permissions := protected.Group("/permissions", middleware.UserOnlyMiddleware(), middleware.RequirePermission(models.PermPermissionManage))
+	permissions.Get("/", permissionHandler.GetPermissions)
+
+	roles := protected.Group("/roles", middleware.UserOnlyMiddleware(), middleware.RequirePermission(models.PermPermissionManage))
+	roles.Get("/", permissionHandler.GetRoles)
+	roles.Put("/:role/permissions", permissionHandler.UpdateRolePermissions)
+
+	auditLogs := protected.Group("/audit-logs", middleware.UserOnlyMiddleware(), middleware.RequirePermission(models.PermAuditRead))
+	auditLogs.Get("/", auditLogHandler.GetAll)
+	auditLogs.Get("/verify", auditLogHandler.Verify)
+	auditLogs.Get("/:id", auditLogHandler.GetByID)
+
+	programs := protected.Group("/programs", middleware.ScopeMiddleware("programs"), conditional)
+	programs.Get("/", middleware.RequirePermission(models.PermProgramRead), programHandler.GetAll)
+	programs.Get("/search", middleware.RequirePermission(models.PermProgramRead), programHandler.Search)
+	programs.Get("/:id", middleware.RequirePermission(models.PermProgramRead), programHandler.GetByID)
+	programs.Post("/", middleware.RequirePermission(models.PermProgramCreate), programHandler.Create)
+	programs.Put("/:id", middleware.RequirePermission(models.PermProgramUpdate), programHandler.Update)
+	programs.Patch("/:id", middleware.RequirePermission(models.PermProgramUpdate), programHandler.Patch)
+	programs.Delete("/:id", middleware.RequirePermission(models.PermProgramDelete), programHandler.Delete)
+
+	lecturers := protected.Group("/lecturers", middleware.ScopeMiddleware("lecturers"), conditional)
+	lecturers.Get("/", middleware.RequirePermission(models.PermLecturerRead), lecturerHandler.GetAll)
+	lecturers.Get("/:id", middleware.RequirePermission(models.PermLecturerRead), lecturerHandler.GetByID)
+	lecturers.Post("/", middleware.RequirePermission(models.PermLecturerManage), lecturerHandler.Create)
+	lecturers.Put("/:id", middleware.RequirePermission(models.PermLecturerManage), lecturerHandler.Update)
+	lecturers.Patch("/:id", middleware.RequirePermission(models.PermLecturerManage), lecturerHandler.Patch)
+	lecturers.Delete("/:id", middleware.RequirePermission(models.PermLecturerManage), lecturerHandler.Delete)
+
+	enrollments := protected.Group("/enrollments", middleware.ScopeMiddleware("enrollments"), conditional)
+	enrollments.Get("/", middleware.RequirePermission(models.PermEnrollmentList), enrollmentHandler.GetAll)
+	enrollments.Get("/student/:studentId", middleware.RequirePermission(models.PermEnrollmentRead), enrollmentHandler.GetByStudent)
+	enrollments.Get("/:id", middleware.RequirePermission(models.PermEnrollmentRead), enrollmentHandler.GetByID)
+	enrollments.Post("/", middleware.RequirePermission(models.PermEnrollmentCreate), enrollmentHandler.Create)
+	enrollments.Put("/:id/status", middleware.RequirePermission(models.PermEnrollmentUpdateStatus), enrollmentHandler.UpdateStatus)
+	enrollments.Delete("/:id", middleware.RequirePermission(models.PermEnrollmentDelete), enrollmentHandler.Delete)
 
-	programs := protected.Group("/programs")
-	programs.Get("/", programHandler.GetAll)
-	programs.Get("/:id", programHandler.GetByID)
-	programs.Post("/", middleware.RoleMiddleware("admin", "lecturer"), programHandler.Create)
-	programs.Put("/:id", middleware.RoleMiddleware("admin", "lecturer"), programHandler.Update)
-	programs.Delete("/:id", middleware.RoleMiddleware("admin"), programHandler.Delete)
-
-	lecturers := protected.Group("/lecturers")
-	lecturers.Get("/", lecturerHandler.GetAll)
-	lecturers.Get("/:id", lecturerHandler.GetByID)
-	lecturers.Post("/", middleware.RoleMiddleware("admin"), lecturerHandler.Create)
-	lecturers.Put("/:id", middleware.RoleMiddleware("admin"), lecturerHandler.Update)
-	lecturers.Delete("/:id", middleware.RoleMiddleware("admin"), lecturerHandler.Delete)
-
-	enrollments := protected.Group("/enrollments")
-	enrollments.Get("/", middleware.RoleMiddleware("admin", "lecturer"), enrollmentHandler.GetAll)
-	enrollments.Get("/student/:studentId", enrollmentHandler.GetByStudent)
-	enrollments.Post("/", middleware.RoleMiddleware("admin", "lecturer", "student"), enrollmentHandler.Create)
-	enrollments.Put("/:id/status", middleware.RoleMiddleware("admin", "lecturer"), enrollmentHandler.UpdateStatus)
-	enrollments.Delete("/:id", middleware.RoleMiddleware("admin"), enrollmentHandler.Delete)
-
-	assessments := protected.Group("/assessments")
-	assessments.Get("/enrollment/:enrollmentId", assessmentHandler.GetByEnrollment)
-	assessments.Post("/", middleware.RoleMiddleware("admin", "lecturer"), assessmentHandler.Create)
-	assessments.Put("/:id", middleware.RoleMiddleware("admin", "lecturer"), assessmentHandler.Update)
-	assessments.Delete("/:id", middleware.RoleMiddleware("admin", "lecturer"), assessmentHandler.Delete)
+	assessments := protected.Group("/assessments", middleware.ScopeMiddleware("assessments"), conditional)
+	assessments.Get("/enrollment/:enrollmentId", middleware.RequirePermission(models.PermAssessmentRead), assessmentHandler.GetByEnrollment)
+	assessments.Get("/:id", middleware.RequirePermission(models.PermAssessmentRead), assessmentHandler.GetByID)
+	assessments.Post("/", middleware.RequirePermission(models.PermAssessmentGrade), assessmentHandler.Create)
+	assessments.Put("/:id", middleware.RequirePermission(models.PermAssessmentGrade), assessmentHandler.Update)
+	assessments.Delete("/:id", middleware.RequirePermission(models.PermAssessmentGrade), assessmentHandler.Delete)
 }
@@ -0,0 +1,962 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mbkm-api/config"
+	"mbkm-api/database"
+	"mbkm-api/models"
+	"mbkm-api/repository"
+	"mbkm-api/routes"
+	"mbkm-api/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Users of the fixture. Each lecturer teaches one program, each student is
+// enrolled in one of them.
+const (
+	adminID = iota + 1
+	lecturerID
+	otherLecturerID
+	studentID
+	otherStudentID
+	// newLecturerID has the lecturer role but no lecturer record yet.
+	newLecturerID
+)
+
+// password is the password of every fixture user.
+const password = "Rahasia123"
+
+// passwordHash is computed once; bcrypt is too slow to run per fixture.
+var passwordHash = sync.OnceValue(func() string {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// fixture is the full route table on in-memory repositories. Service
+// accounts and the audit log still query Postgres directly and reach a pool
+// without a server behind it.
+type fixture struct {
+	app    *fiber.App
+	mem    *repository.Memory
+	repos  *repository.Repositories
+	tokens *utils.TokenManager
+	apiKey string
+
+	lecturer, otherLecturer int
+	// program and otherProgram have an enrollment each, spareProgram none.
+	program, otherProgram, spareProgram int
+	// enrollment is graded with assessment, otherEnrollment is not.
+	enrollment, otherEnrollment int
+	assessment                  int
+}
+
+// newFixture builds a fixture; configure adjusts the configuration before
+// the routes are set up.
+func newFixture(t *testing.T, configure ...func(cfg *config.Config)) *fixture {
+	t.Helper()
+
+	cfg := &config.Config{
+		AppBaseURL:              "http://localhost:3000",
+		JWTExpiration:           1,
+		RefreshTokenExpiration:  24,
+		InvitationExpiration:    72,
+		PasswordResetExpiration: 30,
+		PasswordMinLength:       8,
+		LoginMaxAttempts:        3,
+		LoginIPMaxAttempts:      100,
+		LoginAttemptWindow:      900,
+		LoginLockoutBase:        60,
+		LoginLockoutMax:         3600,
+		LoginRateLimit:          1000,
+		PermissionCacheTTL:      60,
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	tokens, err := utils.NewTokenManager(utils.TokenManagerConfig{Secret: "test-secret", Issuer: "mbkm-api", Audience: "mbkm-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := pgxpool.New(context.Background(), "postgres://mbkm@127.0.0.1:1/mbkm?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	f := &fixture{mem: repository.NewMemory(), tokens: tokens}
+	f.repos = f.mem.Repositories()
+	f.seed(t)
+
+	f.app = fiber.New(fiber.Config{ErrorHandler: utils.ErrorHandler})
+	routes.SetupRoutes(f.app, &database.Database{Pool: pool}, f.repos, cfg, tokens)
+	return f
+}
+
+func (f *fixture) seed(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	must := func(id int, err error) int {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	for _, u := range []models.User{
+		{ID: adminID, Username: "admin", Role: models.RoleAdmin},
+		{ID: lecturerID, Username: "ani", Role: models.RoleLecturer},
+		{ID: otherLecturerID, Username: "budi", Role: models.RoleLecturer},
+		{ID: studentID, Username: "citra", Role: models.RoleStudent},
+		{ID: otherStudentID, Username: "dewi", Role: models.RoleStudent},
+		{ID: newLecturerID, Username: "eko", Role: models.RoleLecturer},
+	} {
+		u.Email = u.Username + "@univ.ac.id"
+		u.PasswordHash = passwordHash()
+		u.IsActive = true
+		f.mem.AddUser(u)
+		f.mem.AddSession(u.ID, u.ID)
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.apiKey = key
+	f.mem.AddAPIKey(prefix, repository.APIKeyCredential{
+		ID: 1, Hash: utils.HashToken(key), Scopes: []string{"programs:read", "enrollments:*"},
+		ServiceAccountID: 1, Role: models.RoleAdmin,
+	})
+
+	f.lecturer = must(f.repos.Lecturers.Create(ctx, models.CreateLecturerRequest{UserID: lecturerID, NIDN: "0011", FullName: "Dr. Ani", Department: "Informatika"}))
+	f.otherLecturer = must(f.repos.Lecturers.Create(ctx, models.CreateLecturerRequest{UserID: otherLecturerID, NIDN: "0022", FullName: "Dr. Budi", Department: "Sistem Informasi"}))
+
+	f.program = must(f.repos.Programs.Create(ctx, models.CreateProgramRequest{Code: "MBKM-01", Name: "Magang Industri", Credits: 20, Semester: 5, LecturerID: f.lecturer}))
+	f.otherProgram = must(f.repos.Programs.Create(ctx, models.CreateProgramRequest{Code: "MBKM-02", Name: "Studi Independen", Credits: 20, Semester: 6, LecturerID: f.otherLecturer}))
+	f.spareProgram = must(f.repos.Programs.Create(ctx, models.CreateProgramRequest{Code: "MBKM-03", Name: "Kampus Mengajar", Credits: 12, Semester: 5, LecturerID: f.lecturer}))
+
+	f.enrollment = must(f.repos.Enrollments.Create(ctx, models.CreateEnrollmentRequest{StudentID: studentID, ProgramID: f.program}))
+	f.otherEnrollment = must(f.repos.Enrollments.Create(ctx, models.CreateEnrollmentRequest{StudentID: otherStudentID, ProgramID: f.otherProgram}))
+
+	f.assessment = must(f.repos.Assessments.Create(ctx, models.CreateAssessmentRequest{EnrollmentID: f.enrollment, Category: "quiz", Score: 80, MaxScore: 100, Weight: 10}))
+}
+
+// principal names who sends a request.
+type principal string
+
+const (
+	anonymous     principal = ""
+	asAdmin       principal = "admin"
+	asLecturer    principal = "lecturer"
+	asStudent     principal = "student"
+	asAPIKey      principal = "api key"
+	asImpersonate principal = "impersonating"
+)
+
+// authorization returns the Authorization header of p.
+func (f *fixture) authorization(t *testing.T, p principal) string {
+	t.Helper()
+
+	claims := utils.Claims{}
+	switch p {
+	case anonymous:
+		return ""
+	case asAPIKey:
+		return "ApiKey " + f.apiKey
+	case asAdmin:
+		claims = utils.Claims{UserID: adminID, SessionID: adminID, Role: models.RoleAdmin}
+	case asLecturer:
+		claims = utils.Claims{UserID: lecturerID, SessionID: lecturerID, Role: models.RoleLecturer}
+	case asStudent:
+		claims = utils.Claims{UserID: studentID, SessionID: studentID, Role: models.RoleStudent}
+	case asImpersonate:
+		// The admin looking at the API as the student, on the admin's session.
+		claims = utils.Claims{UserID: studentID, SessionID: adminID, Role: models.RoleStudent, Act: &utils.ActorClaim{UserID: adminID}}
+	default:
+		t.Fatalf("unknown principal %q", p)
+	}
+
+	token, err := f.tokens.Generate(claims, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// request is one call against the fixture. Header holds extra headers as
+// name, value pairs.
+type request struct {
+	as     principal
+	method string
+	path   string
+	body   string
+	header []string
+}
+
+// response is a decoded API envelope.
+type response struct {
+	status int
+	header http.Header
+
+	Success    bool              `json:"success"`
+	Message    string            `json:"message"`
+	Error      string            `json:"error"`
+	Details    []json.RawMessage `json:"details"`
+	Data       json.RawMessage   `json:"data"`
+	Pagination *utils.Pagination `json:"pagination"`
+}
+
+func (f *fixture) do(t *testing.T, r request) response {
+	t.Helper()
+
+	var body io.Reader
+	if r.body != "" {
+		body = strings.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, r.path, body)
+	if r.body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if auth := f.authorization(t, r.as); auth != "" {
+		req.Header.Set(fiber.HeaderAuthorization, auth)
+	}
+	for i := 0; i+1 < len(r.header); i += 2 {
+		req.Header.Set(r.header[i], r.header[i+1])
+	}
+
+	resp, err := f.app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("%s %s: %v", r.method, r.path, err)
+	}
+	defer resp.Body.Close()
+
+	out := response{status: resp.StatusCode, header: resp.Header}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", r.method, r.path, raw, err)
+		}
+	}
+	return out
+}
+
+// decode unmarshals the data member of res into v.
+func (res response) decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(res.Data, v); err != nil {
+		t.Fatalf("decoding data %s: %v", res.Data, err)
+	}
+}
+
+// crudCase is one request on a fresh fixture and what it must answer.
+type crudCase struct {
+	name   string
+	req    func(f *fixture) request
+	status int
+	// errCode is the error member of a failure envelope.
+	errCode string
+	check   func(t *testing.T, f *fixture, res response)
+}
+
+func runCases(t *testing.T, cases []crudCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t)
+			req := tc.req(f)
+			res := f.do(t, req)
+			if res.status != tc.status {
+				t.Fatalf("%s %s: status %d (%s), want %d", req.method, req.path, res.status, res.Message, tc.status)
+			}
+			if tc.errCode != "" && res.Error != tc.errCode {
+				t.Errorf("error = %q, want %q", res.Error, tc.errCode)
+			}
+			if tc.check != nil {
+				tc.check(t, f, res)
+			}
+		})
+	}
+}
+
+func path(format string, id int) string {
+	return strings.Replace(format, "{id}", strconv.Itoa(id), 1)
+}
+
+func TestPrograms(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name:   "list",
+			req:    func(f *fixture) request { return request{as: asStudent, method: "GET", path: "/api/v1/programs"} },
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var programs []models.Program
+				res.decode(t, &programs)
+				if len(programs) != 3 || res.Pagination == nil || res.Pagination.Total != 3 {
+					t.Errorf("got %d programs, pagination %+v", len(programs), res.Pagination)
+				}
+			},
+		},
+		{
+			name: "get",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/programs/{id}", f.program)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var p models.Program
+				res.decode(t, &p)
+				if p.Code != "MBKM-01" || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("got %s with ETag %s", p.Code, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "get unchanged",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/programs/{id}", f.program), header: []string{fiber.HeaderIfNoneMatch, `"1"`}}
+			},
+			status: fiber.StatusNotModified,
+		},
+		{
+			name:    "get missing",
+			req:     func(f *fixture) request { return request{as: asStudent, method: "GET", path: "/api/v1/programs/999"} },
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name:   "get malformed id",
+			req:    func(f *fixture) request { return request{as: asStudent, method: "GET", path: "/api/v1/programs/abc"} },
+			status: fiber.StatusBadRequest,
+		},
+		{
+			name: "search",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: "/api/v1/programs/search?q=magang"}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var search struct{ Hits []models.ProgramSearchHit }
+				res.decode(t, &search)
+				if len(search.Hits) != 1 || search.Hits[0].ID != f.program {
+					t.Errorf("got hits %+v", search.Hits)
+				}
+			},
+		},
+		{
+			name: "create",
+			req: func(f *fixture) request {
+				body := `{"code":"MBKM-04","name":"Riset","credits":10,"semester":7,"lecturer_id":` + strconv.Itoa(f.otherLecturer) + `}`
+				return request{as: asAdmin, method: "POST", path: "/api/v1/programs", body: body}
+			},
+			status: fiber.StatusCreated,
+			check: func(t *testing.T, f *fixture, res response) {
+				var created struct{ ID int }
+				res.decode(t, &created)
+				p, err := f.repos.Programs.Get(context.Background(), created.ID)
+				if err != nil || p.Code != "MBKM-04" || p.LecturerID != f.otherLecturer {
+					t.Errorf("stored %+v, %v", p, err)
+				}
+			},
+		},
+		{
+			name: "create invalid",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/programs", body: `{"name":"Riset","credits":0,"semester":7,"lecturer_id":1}`}
+			},
+			status:  fiber.StatusUnprocessableEntity,
+			errCode: utils.ErrCodeValidation,
+			check: func(t *testing.T, f *fixture, res response) {
+				if len(res.Details) != 2 {
+					t.Errorf("details = %s, want code and credits", res.Details)
+				}
+			},
+		},
+		{
+			name: "create duplicate code",
+			req: func(f *fixture) request {
+				body := `{"code":"MBKM-01","name":"Riset","credits":10,"semester":7,"lecturer_id":` + strconv.Itoa(f.lecturer) + `}`
+				return request{as: asAdmin, method: "POST", path: "/api/v1/programs", body: body}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeAlreadyExists,
+		},
+		{
+			name: "create malformed body",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/programs", body: `{"code":`}
+			},
+			status: fiber.StatusBadRequest,
+		},
+		{
+			name: "update",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/programs/{id}", f.program), body: `{"code":"MBKM-01","name":"Magang","credits":18,"semester":5}`, header: []string{fiber.HeaderIfMatch, `"1"`}}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				p, _ := f.repos.Programs.Get(context.Background(), f.program)
+				if p.Name != "Magang" || p.Credits != 18 || res.header.Get(fiber.HeaderETag) != `"2"` {
+					t.Errorf("stored %+v, ETag %s", p, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "update stale",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/programs/{id}", f.program), body: `{"code":"MBKM-01","name":"Magang","credits":18,"semester":5}`, header: []string{fiber.HeaderIfMatch, `"7"`}}
+			},
+			status:  fiber.StatusPreconditionFailed,
+			errCode: utils.ErrCodePreconditionFailed,
+		},
+		{
+			name: "patch",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{"description":"Semester di industri"}`, header: []string{fiber.HeaderContentType, "application/merge-patch+json"}}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				p, _ := f.repos.Programs.Get(context.Background(), f.program)
+				if p.Description != "Semester di industri" || p.Name != "Magang Industri" || res.header.Get(fiber.HeaderETag) != `"2"` {
+					t.Errorf("stored %+v", p)
+				}
+			},
+		},
+		{
+			name: "patch empty",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				p, _ := f.repos.Programs.Get(context.Background(), f.program)
+				if p.Version != 1 || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("version %d, ETag %s", p.Version, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "patch without changes",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{"name":"Magang Industri","is_active":true}`, header: []string{fiber.HeaderIfMatch, `"1"`}}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				p, _ := f.repos.Programs.Get(context.Background(), f.program)
+				if p.Version != 1 || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("version %d, ETag %s", p.Version, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "patch without changes stale",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{}`, header: []string{fiber.HeaderIfMatch, `"7"`}}
+			},
+			status:  fiber.StatusPreconditionFailed,
+			errCode: utils.ErrCodePreconditionFailed,
+		},
+		{
+			name: "patch unknown field",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{"lecturer_id":2}`}
+			},
+			status: fiber.StatusBadRequest,
+		},
+		{
+			name: "patch wrong media type",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/programs/{id}", f.program), body: `{"name":"x"}`, header: []string{fiber.HeaderContentType, "text/plain"}}
+			},
+			status: fiber.StatusUnsupportedMediaType,
+		},
+		{
+			name: "delete",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: path("/api/v1/programs/{id}", f.spareProgram)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				if _, err := f.repos.Programs.Get(context.Background(), f.spareProgram); err == nil {
+					t.Error("program still exists")
+				}
+			},
+		},
+		{
+			name: "delete with enrollments",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: path("/api/v1/programs/{id}", f.program)}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeReferenceViolation,
+		},
+	})
+}
+
+func TestProgramPagination(t *testing.T) {
+	f := newFixture(t)
+	for i := 4; i <= 7; i++ {
+		req := models.CreateProgramRequest{Code: "MBKM-0" + strconv.Itoa(i), Name: "Program " + strconv.Itoa(i), Credits: 10, Semester: 5, LecturerID: f.lecturer}
+		if _, err := f.repos.Programs.Create(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	codes := func(res response) []string {
+		var programs []models.Program
+		res.decode(t, &programs)
+		out := []string{}
+		for _, p := range programs {
+			out = append(out, p.Code)
+		}
+		return out
+	}
+
+	first := f.do(t, request{as: asStudent, method: "GET", path: "/api/v1/programs?sort=code&limit=3"})
+	if first.status != fiber.StatusOK || strings.Join(codes(first), ",") != "MBKM-01,MBKM-02,MBKM-03" {
+		t.Fatalf("first page: %d %v", first.status, codes(first))
+	}
+	if p := first.Pagination; p.Total != 7 || p.Page != 1 || !p.HasMore || p.NextCursor == "" {
+		t.Fatalf("first page pagination %+v", p)
+	}
+
+	second := f.do(t, request{as: asStudent, method: "GET", path: "/api/v1/programs?sort=code&limit=3&cursor=" + first.Pagination.NextCursor})
+	if strings.Join(codes(second), ",") != "MBKM-04,MBKM-05,MBKM-06" || second.Pagination.Page != 0 {
+		t.Fatalf("cursor page: %v %+v", codes(second), second.Pagination)
+	}
+
+	last := f.do(t, request{as: asStudent, method: "GET", path: "/api/v1/programs?sort=code&limit=3&page=3"})
+	if strings.Join(codes(last), ",") != "MBKM-07" || last.Pagination.HasMore || last.Pagination.NextCursor != "" {
+		t.Fatalf("last page: %v %+v", codes(last), last.Pagination)
+	}
+
+	for _, query := range []string{
+		"sort=code&page=2&cursor=" + first.Pagination.NextCursor,
+		"sort=name&cursor=" + first.Pagination.NextCursor,
+		"cursor=not-a-cursor",
+		"sort=password",
+		"semester=five",
+	} {
+		if res := f.do(t, request{as: asStudent, method: "GET", path: "/api/v1/programs?" + query}); res.status != fiber.StatusBadRequest {
+			t.Errorf("?%s: status %d, want 400", query, res.status)
+		}
+	}
+}
+
+func TestLecturers(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name: "list by department",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: "/api/v1/lecturers?department=Informatika"}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var lecturers []models.Lecturer
+				res.decode(t, &lecturers)
+				if len(lecturers) != 1 || lecturers[0].ID != f.lecturer {
+					t.Errorf("got %+v", lecturers)
+				}
+			},
+		},
+		{
+			name: "get",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/lecturers/{id}", f.otherLecturer)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var l models.Lecturer
+				res.decode(t, &l)
+				if l.NIDN != "0022" {
+					t.Errorf("got %+v", l)
+				}
+			},
+		},
+		{
+			name: "create",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/lecturers", body: `{"user_id":6,"nidn":"0066","full_name":"Dr. Citra"}`}
+			},
+			status: fiber.StatusCreated,
+		},
+		{
+			name: "create for a student",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/lecturers", body: `{"user_id":4,"nidn":"0044","full_name":"Dewi"}`}
+			},
+			status: fiber.StatusBadRequest,
+		},
+		{
+			name: "create duplicate nidn",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/lecturers", body: `{"user_id":6,"nidn":"0011","full_name":"Dr. Citra"}`}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeAlreadyExists,
+		},
+		{
+			name: "update",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PUT", path: path("/api/v1/lecturers/{id}", f.lecturer), body: `{"nidn":"0011","full_name":"Prof. Ani","department":"Informatika"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var data struct{ Version int }
+				res.decode(t, &data)
+				if data.Version != 2 || res.header.Get(fiber.HeaderETag) != `"2"` {
+					t.Errorf("version %d, ETag %s", data.Version, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "patch clears department",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/lecturers/{id}", f.lecturer), body: `{"department":null}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				l, _ := f.repos.Lecturers.Get(context.Background(), f.lecturer)
+				if l.Department != "" || l.FullName != "Dr. Ani" {
+					t.Errorf("stored %+v", l)
+				}
+			},
+		},
+		{
+			name: "patch without changes",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/lecturers/{id}", f.lecturer), body: `{"full_name":"Dr. Ani"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				l, _ := f.repos.Lecturers.Get(context.Background(), f.lecturer)
+				if l.Version != 1 || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("version %d, ETag %s", l.Version, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "patch null name",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "PATCH", path: path("/api/v1/lecturers/{id}", f.lecturer), body: `{"full_name":null}`}
+			},
+			status:  fiber.StatusUnprocessableEntity,
+			errCode: utils.ErrCodeValidation,
+		},
+		{
+			name: "delete teaching",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: path("/api/v1/lecturers/{id}", f.lecturer)}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeReferenceViolation,
+		},
+		{
+			name:    "delete missing",
+			req:     func(f *fixture) request { return request{as: asAdmin, method: "DELETE", path: "/api/v1/lecturers/999"} },
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+	})
+}
+
+func TestEnrollments(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name: "list",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "GET", path: "/api/v1/enrollments?status=enrolled"}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				if res.Pagination.Total != 2 {
+					t.Errorf("total %d, want 2", res.Pagination.Total)
+				}
+			},
+		},
+		{
+			name:   "list as lecturer",
+			req:    func(f *fixture) request { return request{as: asLecturer, method: "GET", path: "/api/v1/enrollments"} },
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var enrollments []models.Enrollment
+				res.decode(t, &enrollments)
+				if len(enrollments) != 1 || enrollments[0].ID != f.enrollment {
+					t.Errorf("lecturer sees %+v", enrollments)
+				}
+			},
+		},
+		{
+			name: "list own",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/enrollments/student/{id}", studentID)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var enrollments []models.Enrollment
+				res.decode(t, &enrollments)
+				if len(enrollments) != 1 || enrollments[0].Version != 1 {
+					t.Errorf("got %+v", enrollments)
+				}
+			},
+		},
+		{
+			name: "get",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/enrollments/{id}", f.enrollment)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var e models.Enrollment
+				res.decode(t, &e)
+				if e.ID != f.enrollment || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("got %+v with ETag %s", e, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "get unchanged",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/enrollments/{id}", f.enrollment), header: []string{fiber.HeaderIfNoneMatch, `"1"`}}
+			},
+			status: fiber.StatusNotModified,
+		},
+		{
+			name:    "get missing",
+			req:     func(f *fixture) request { return request{as: asAdmin, method: "GET", path: "/api/v1/enrollments/999"} },
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "enroll self",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "POST", path: "/api/v1/enrollments", body: `{"student_id":4,"program_id":` + strconv.Itoa(f.otherProgram) + `}`}
+			},
+			status: fiber.StatusCreated,
+		},
+		{
+			name: "enroll twice",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "POST", path: "/api/v1/enrollments", body: `{"student_id":4,"program_id":` + strconv.Itoa(f.program) + `}`}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeAlreadyExists,
+		},
+		{
+			name: "enroll in missing program",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/enrollments", body: `{"student_id":4,"program_id":999}`}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeReferenceViolation,
+		},
+		{
+			name: "update status",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/enrollments/{id}/status", f.enrollment), body: `{"status":"active"}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				if res.header.Get(fiber.HeaderETag) != `"2"` {
+					t.Errorf("ETag %s, want \"2\"", res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "update stale",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/enrollments/{id}/status", f.enrollment), body: `{"status":"active"}`, header: []string{fiber.HeaderIfMatch, `"7"`}}
+			},
+			status:  fiber.StatusPreconditionFailed,
+			errCode: utils.ErrCodePreconditionFailed,
+		},
+		{
+			name: "update to unknown status",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/enrollments/{id}/status", f.enrollment), body: `{"status":"graduated"}`}
+			},
+			status:  fiber.StatusUnprocessableEntity,
+			errCode: utils.ErrCodeValidation,
+		},
+		{
+			name: "delete",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: path("/api/v1/enrollments/{id}", f.otherEnrollment)}
+			},
+			status: fiber.StatusOK,
+		},
+		{
+			name: "delete graded",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: path("/api/v1/enrollments/{id}", f.enrollment)}
+			},
+			status:  fiber.StatusConflict,
+			errCode: utils.ErrCodeReferenceViolation,
+		},
+	})
+}
+
+func TestAssessments(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name: "list",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/assessments/enrollment/{id}", f.enrollment)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var assessments []models.Assessment
+				res.decode(t, &assessments)
+				if len(assessments) != 1 || assessments[0].Score != 80 {
+					t.Errorf("got %+v", assessments)
+				}
+			},
+		},
+		{
+			name: "get",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/assessments/{id}", f.assessment)}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				var a models.Assessment
+				res.decode(t, &a)
+				if a.Score != 80 || res.header.Get(fiber.HeaderETag) != `"1"` {
+					t.Errorf("got %+v with ETag %s", a, res.header.Get(fiber.HeaderETag))
+				}
+			},
+		},
+		{
+			name: "get unchanged",
+			req: func(f *fixture) request {
+				return request{as: asStudent, method: "GET", path: path("/api/v1/assessments/{id}", f.assessment), header: []string{fiber.HeaderIfNoneMatch, `"1"`}}
+			},
+			status: fiber.StatusNotModified,
+		},
+		{
+			name:    "get missing",
+			req:     func(f *fixture) request { return request{as: asAdmin, method: "GET", path: "/api/v1/assessments/999"} },
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "create",
+			req: func(f *fixture) request {
+				body := `{"enrollment_id":` + strconv.Itoa(f.enrollment) + `,"category":"final","score":90,"max_score":100,"weight":40}`
+				return request{as: asLecturer, method: "POST", path: "/api/v1/assessments", body: body}
+			},
+			status: fiber.StatusCreated,
+		},
+		{
+			name: "create above max score",
+			req: func(f *fixture) request {
+				body := `{"enrollment_id":` + strconv.Itoa(f.enrollment) + `,"category":"final","score":120,"max_score":100,"weight":40}`
+				return request{as: asLecturer, method: "POST", path: "/api/v1/assessments", body: body}
+			},
+			status:  fiber.StatusUnprocessableEntity,
+			errCode: utils.ErrCodeValidation,
+		},
+		{
+			name: "create for missing enrollment",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "POST", path: "/api/v1/assessments", body: `{"enrollment_id":999,"category":"quiz","score":1,"max_score":10}`}
+			},
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+		{
+			name: "update",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/assessments/{id}", f.assessment), body: `{"score":85,"max_score":100,"weight":10}`}
+			},
+			status: fiber.StatusOK,
+			check: func(t *testing.T, f *fixture, res response) {
+				assessments, _ := f.repos.Assessments.ListByEnrollment(context.Background(), f.enrollment)
+				if assessments[0].Score != 85 || assessments[0].Version != 2 || res.header.Get(fiber.HeaderETag) != `"2"` {
+					t.Errorf("stored %+v", assessments[0])
+				}
+			},
+		},
+		{
+			name: "update stale",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "PUT", path: path("/api/v1/assessments/{id}", f.assessment), body: `{"score":85,"max_score":100,"weight":10}`, header: []string{fiber.HeaderIfMatch, `"7"`}}
+			},
+			status:  fiber.StatusPreconditionFailed,
+			errCode: utils.ErrCodePreconditionFailed,
+		},
+		{
+			name: "delete",
+			req: func(f *fixture) request {
+				return request{as: asLecturer, method: "DELETE", path: path("/api/v1/assessments/{id}", f.assessment)}
+			},
+			status: fiber.StatusOK,
+		},
+		{
+			name: "delete missing",
+			req: func(f *fixture) request {
+				return request{as: asAdmin, method: "DELETE", path: "/api/v1/assessments/999"}
+			},
+			status:  fiber.StatusNotFound,
+			errCode: utils.ErrCodeNotFound,
+		},
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	runCases(t, []crudCase{
+		{
+			name:   "health is public",
+			req:    func(f *fixture) request { return request{method: "GET", path: "/health"} },
+			status: fiber.StatusOK,
+		},
+		{
+			name:   "missing token",
+			req:    func(f *fixture) request { return request{method: "GET", path: "/api/v1/programs"} },
+			status: fiber.StatusUnauthorized,
+		},
+		{
+			name: "malformed token",
+			req: func(f *fixture) request {
+				return request{method: "GET", path: "/api/v1/programs", header: []string{fiber.HeaderAuthorization, "Bearer not-a-jwt"}}
+			},
+			status: fiber.StatusUnauthorized,
+		},
+		{
+			name: "unknown API key",
+			req: func(f *fixture) request {
+				return request{method: "GET", path: "/api/v1/programs", header: []string{"X-API-Key", f.apiKey + "x"}}
+			},
+			status: fiber.StatusUnauthorized,
+		},
+	})
+}
+
+func TestRevokedSession(t *testing.T) {
+	f := newFixture(t)
+	token, err := f.tokens.Generate(utils.Claims{UserID: studentID, SessionID: 99, Role: models.RoleStudent}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := f.do(t, request{method: "GET", path: "/api/v1/programs", header: []string{fiber.HeaderAuthorization, "Bearer " + token}})
+	if res.status != fiber.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", res.status)
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+)
+
+type AssessmentService struct {
+	base
+}
+
+func NewAssessmentService(repos *repository.Repositories) *AssessmentService {
+	return &AssessmentService{newBase(repos)}
+}
+
+// Create grades an enrollment the actor manages. The student and program are
+// taken from the enrollment in the same transaction as the check.
+func (s *AssessmentService) Create(ctx context.Context, actor policy.Actor, req models.CreateAssessmentRequest) (int, error) {
+	var id int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageEnrollment(ctx, actor, req.EnrollmentID); err != nil {
+			return err
+		}
+
+		var err error
+		id, err = s.repos.Assessments.Create(ctx, req)
+		return err
+	})
+	return id, err
+}
+
+// Update returns the assessment's new version.
+func (s *AssessmentService) Update(ctx context.Context, actor policy.Actor, id int, req models.UpdateAssessmentRequest) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageAssessment(ctx, actor, id); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = s.repos.Assessments.Update(ctx, id, req)
+		return err
+	})
+	return version, err
+}
+
+func (s *AssessmentService) Delete(ctx context.Context, actor policy.Actor, id int) error {
+	return s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageAssessment(ctx, actor, id); err != nil {
+			return err
+		}
+		return s.repos.Assessments.Delete(ctx, id)
+	})
+}
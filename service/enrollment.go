@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+)
+
+type EnrollmentService struct {
+	base
+}
+
+func NewEnrollmentService(repos *repository.Repositories) *EnrollmentService {
+	return &EnrollmentService{newBase(repos)}
+}
+
+func (s *EnrollmentService) Create(ctx context.Context, actor policy.Actor, req models.CreateEnrollmentRequest) (int, error) {
+	var id int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanEnroll(ctx, actor, req.StudentID, req.ProgramID); err != nil {
+			return err
+		}
+
+		var err error
+		id, err = s.repos.Enrollments.Create(ctx, req)
+		return err
+	})
+	return id, err
+}
+
+// UpdateStatus returns the enrollment's new version.
+func (s *EnrollmentService) UpdateStatus(ctx context.Context, actor policy.Actor, id int, status string) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageEnrollment(ctx, actor, id); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = s.repos.Enrollments.UpdateStatus(ctx, id, status)
+		return err
+	})
+	return version, err
+}
+
+func (s *EnrollmentService) Delete(ctx context.Context, actor policy.Actor, id int) error {
+	return s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageEnrollment(ctx, actor, id); err != nil {
+			return err
+		}
+		return s.repos.Enrollments.Delete(ctx, id)
+	})
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+)
+
+type LecturerService struct {
+	base
+}
+
+func NewLecturerService(repos *repository.Repositories) *LecturerService {
+	return &LecturerService{newBase(repos)}
+}
+
+// Create links a lecturer record to a user that has the lecturer role.
+func (s *LecturerService) Create(ctx context.Context, req models.CreateLecturerRequest) (int, error) {
+	var id int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		isLecturer, err := s.repos.Lecturers.IsLecturerUser(ctx, req.UserID)
+		if err != nil {
+			return err
+		}
+		if !isLecturer {
+			return ErrNotLecturerUser
+		}
+
+		id, err = s.repos.Lecturers.Create(ctx, req)
+		return err
+	})
+	return id, err
+}
+
+// Update returns the lecturer's new version.
+func (s *LecturerService) Update(ctx context.Context, actor policy.Actor, id int, req models.UpdateLecturerRequest) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageLecturer(ctx, actor, id); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = s.repos.Lecturers.Update(ctx, id, req)
+		return err
+	})
+	return version, err
+}
+
+// Patch applies a merge patch to the lecturer as it is inside the unit of
+// work. It returns the lecturer's new version, or the current one if the
+// patch changes nothing.
+func (s *LecturerService) Patch(ctx context.Context, actor policy.Actor, id int, patch models.LecturerPatch) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageLecturer(ctx, actor, id); err != nil {
+			return err
+		}
+		current, err := s.repos.Lecturers.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		req := patch.Apply(current)
+		if !req.Changes(current) {
+			version = current.Version
+			return repository.MatchVersion(ctx, current.Version)
+		}
+		version, err = s.repos.Lecturers.Update(ctx, id, req)
+		return err
+	})
+	return version, err
+}
+
+func (s *LecturerService) Delete(ctx context.Context, actor policy.Actor, id int) error {
+	return s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageLecturer(ctx, actor, id); err != nil {
+			return err
+		}
+		return s.repos.Lecturers.Delete(ctx, id)
+	})
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"mbkm-api/models"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ProgramService struct {
+	base
+}
+
+func NewProgramService(repos *repository.Repositories) *ProgramService {
+	return &ProgramService{newBase(repos)}
+}
+
+// Create checks that the lecturer is active and may be assigned by actor,
+// then creates the program.
+func (s *ProgramService) Create(ctx context.Context, actor policy.Actor, req models.CreateProgramRequest) (int, error) {
+	var id int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		lecturer, err := s.repos.Lecturers.Get(ctx, req.LecturerID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !lecturer.IsActive) {
+			return ErrInvalidLecturer
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.policy.CanAssignLecturer(ctx, actor, req.LecturerID); err != nil {
+			return err
+		}
+
+		id, err = s.repos.Programs.Create(ctx, req)
+		return err
+	})
+	return id, err
+}
+
+// Update returns the program's new version.
+func (s *ProgramService) Update(ctx context.Context, actor policy.Actor, id int, req models.UpdateProgramRequest) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageProgram(ctx, actor, id); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = s.repos.Programs.Update(ctx, id, req)
+		return err
+	})
+	return version, err
+}
+
+// Patch applies a merge patch to the program as it is inside the unit of
+// work, so concurrent patches to different fields do not undo each other.
+// It returns the program's new version; a patch that changes nothing is not
+// written and returns the current one.
+func (s *ProgramService) Patch(ctx context.Context, actor policy.Actor, id int, patch models.ProgramPatch) (int, error) {
+	var version int
+	err := s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageProgram(ctx, actor, id); err != nil {
+			return err
+		}
+		current, err := s.repos.Programs.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		req := patch.Apply(current)
+		if !req.Changes(current) {
+			version = current.Version
+			return repository.MatchVersion(ctx, current.Version)
+		}
+		version, err = s.repos.Programs.Update(ctx, id, req)
+		return err
+	})
+	return version, err
+}
+
+func (s *ProgramService) Delete(ctx context.Context, actor policy.Actor, id int) error {
+	return s.repos.Tx.Do(ctx, func(ctx context.Context) error {
+		if err := s.policy.CanManageProgram(ctx, actor, id); err != nil {
+			return err
+		}
+		return s.repos.Programs.Delete(ctx, id)
+	})
+}
@@ -0,0 +1,30 @@
+// Package service holds the academic write flows that take more than one
+// step, e.g. checking a lecturer and then creating a program. Each flow runs
+// as one repository.UnitOfWork so a concurrent change cannot slip in between
+// the check and the write; on Postgres a flow that loses a serialization
+// conflict is retried as a whole.
+//
+// Errors are policy.ErrForbidden / policy.ErrNotFound for access decisions,
+// the sentinels below for failed preconditions and otherwise whatever the
+// repositories return.
+package service
+
+import (
+	"errors"
+	"mbkm-api/policy"
+	"mbkm-api/repository"
+)
+
+var (
+	ErrInvalidLecturer = errors.New("lecturer does not exist or is inactive")
+	ErrNotLecturerUser = errors.New("user does not exist or is not a lecturer")
+)
+
+type base struct {
+	repos  *repository.Repositories
+	policy *policy.Policy
+}
+
+func newBase(repos *repository.Repositories) base {
+	return base{repos: repos, policy: policy.New(repos.Owners)}
+}
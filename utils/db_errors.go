@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes the API reacts to.
+const (
+	PgUniqueViolation      = "23505"
+	PgForeignKeyViolation  = "23503"
+	PgCheckViolation       = "23514"
+	PgNotNullViolation     = "23502"
+	PgStringTooLong        = "22001"
+	PgNumericOutOfRange    = "22003"
+	PgSerializationFailure = "40001"
+	PgDeadlockDetected     = "40P01"
+	PgQueryCanceled        = "57014"
+)
+
+// checkMessages explains each CHECK constraint of the schema to API clients.
+var checkMessages = map[string]string{
+	"chk_program_credits":      "Credits must be between 1 and 24",
+	"chk_program_semester":     "Semester must be between 1 and 14",
+	"chk_enrollment_status":    "Status must be one of enrolled, active, completed, dropped",
+	"chk_assessment_score":     "Score must be between 0 and max score",
+	"chk_assessment_max_score": "Max score must be greater than 0",
+	"chk_assessment_weight":    "Weight must be between 0 and 100",
+}
+
+// DBError translates a non-nil error from pgx into an AppError. Errors the
+// client can act on get a specific status and code; everything else is a
+// 500 with fallback as the message, a 503 when the database is down and a
+// 504 when the request deadline or statement_timeout is hit.
+func DBError(err error, fallback string) *AppError {
+	appErr := &AppError{Status: fiber.StatusInternalServerError, Code: ErrCodeInternal, Message: fallback, Err: err}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusNotFound, ErrCodeNotFound, "Resource not found"
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusGatewayTimeout, ErrCodeTimeout, "Database did not respond in time"
+		return appErr
+	}
+	// The request was abandoned, e.g. the server is shutting down.
+	if errors.Is(err, context.Canceled) {
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusServiceUnavailable, ErrCodeUnavailable, "Request was canceled"
+		return appErr
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		var connectErr *pgconn.ConnectError
+		if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+			appErr.Status, appErr.Code, appErr.Message = fiber.StatusServiceUnavailable, ErrCodeUnavailable, "Database is unavailable"
+		}
+		return appErr
+	}
+
+	switch {
+	case pgErr.Code == PgUniqueViolation:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusConflict, ErrCodeAlreadyExists, "Resource already exists"
+	case pgErr.Code == PgForeignKeyViolation:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusConflict, ErrCodeReferenceViolation, "Resource is referenced by or refers to missing data"
+	case pgErr.Code == PgCheckViolation:
+		msg, ok := checkMessages[pgErr.ConstraintName]
+		if !ok {
+			msg = "Value violates constraint " + pgErr.ConstraintName
+		}
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusUnprocessableEntity, ErrCodeConstraint, msg
+	case pgErr.Code == PgNotNullViolation:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusUnprocessableEntity, ErrCodeConstraint, pgErr.ColumnName+" is required"
+	case pgErr.Code == PgStringTooLong || pgErr.Code == PgNumericOutOfRange:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusUnprocessableEntity, ErrCodeConstraint, "Value is too long or out of range"
+	case pgErr.Code == PgSerializationFailure || pgErr.Code == PgDeadlockDetected:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusConflict, ErrCodeSerialization, "Request conflicted with a concurrent change, please retry"
+	case pgErr.Code == PgQueryCanceled:
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusGatewayTimeout, ErrCodeTimeout, "Database did not respond in time"
+	// Class 08 is connection trouble, 53 exhausted resources and 57P0x a
+	// server shutting down or starting up.
+	case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P0"):
+		appErr.Status, appErr.Code, appErr.Message = fiber.StatusServiceUnavailable, ErrCodeUnavailable, "Database is unavailable"
+	}
+
+	return appErr
+}
+
+// IsDBError reports whether err carries the given SQLSTATE.
+func IsDBError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// IsConstraintViolation reports whether err was raised by the named
+// constraint or unique index.
+func IsConstraintViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Machine-readable error codes sent in Response.Error. Clients should branch
+// on these rather than on Message, which is meant for people.
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeConflict           = "conflict"
+	ErrCodeAlreadyExists      = "already_exists"
+	ErrCodeReferenceViolation = "reference_violation"
+	ErrCodeConstraint         = "constraint_violation"
+	ErrCodeUnprocessable      = "unprocessable_entity"
+	ErrCodeValidation         = "validation_failed"
+	ErrCodeSerialization      = "serialization_failure"
+	ErrCodeTooManyRequests    = "too_many_requests"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeUnavailable        = "service_unavailable"
+	ErrCodeTimeout            = "timeout"
+	ErrCodePreconditionFailed = "precondition_failed"
+	ErrCodePreconditionNeeded = "precondition_required"
+)
+
+var statusErrorCodes = map[int]string{
+	fiber.StatusBadRequest:           ErrCodeBadRequest,
+	fiber.StatusUnauthorized:         ErrCodeUnauthorized,
+	fiber.StatusForbidden:            ErrCodeForbidden,
+	fiber.StatusNotFound:             ErrCodeNotFound,
+	fiber.StatusConflict:             ErrCodeConflict,
+	fiber.StatusUnprocessableEntity:  ErrCodeUnprocessable,
+	fiber.StatusPreconditionFailed:   ErrCodePreconditionFailed,
+	fiber.StatusPreconditionRequired: ErrCodePreconditionNeeded,
+	fiber.StatusTooManyRequests:      ErrCodeTooManyRequests,
+	fiber.StatusServiceUnavailable:   ErrCodeUnavailable,
+	fiber.StatusGatewayTimeout:       ErrCodeTimeout,
+}
+
+// StatusErrorCode returns the generic error code for an HTTP status.
+func StatusErrorCode(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= fiber.StatusInternalServerError {
+		return ErrCodeInternal
+	}
+	return ErrCodeBadRequest
+}
+
+// AppError is an error a handler can return as is; ErrorHandler renders it
+// with the standard envelope. Err keeps the underlying cause for logging and
+// is never sent to the client. Details, if set, is sent as is.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+	Err     error
+}
+
+func NewAppError(status int, code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// On replaces the message when the error has the given code, so callers can
+// word the cases they expect for their own resource.
+func (e *AppError) On(code, message string) *AppError {
+	if e.Code == code {
+		e.Message = message
+	}
+	return e
+}
+
+// ErrorHandler is the fiber error handler. AppErrors keep their status and
+// code, fiber errors get the generic code for their status and anything else
+// becomes an internal error whose details stay in the log.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			appErr = NewAppError(fiberErr.Code, StatusErrorCode(fiberErr.Code), fiberErr.Message)
+		} else {
+			appErr = &AppError{Status: fiber.StatusInternalServerError, Code: ErrCodeInternal, Message: "Internal server error", Err: err}
+		}
+	}
+
+	if appErr.Status >= fiber.StatusInternalServerError && appErr.Err != nil {
+		log.Printf("❌ [%v] %s %s: %v", c.Locals("requestid"), c.Method(), c.Path(), appErr.Err)
+	}
+
+	return c.Status(appErr.Status).JSON(Response{
+		Success: false,
+		Message: appErr.Message,
+		Code:    appErr.Status,
+		Error:   appErr.Code,
+		Details: appErr.Details,
+	})
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag is the entity tag of a record at version.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ETagVersions returns the versions named by the strong entity tags of an
+// If-Match header. Weak and foreign tags never match and are skipped.
+func ETagVersions(header string) []int {
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if v, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// NoneMatch reports whether an If-None-Match header names etag, comparing
+// weakly, or is "*".
+func NoneMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,42 +1,144 @@
 package utils
 
 import (
+	"crypto"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeMFA marks the short-lived token handed out between the password
+// and the TOTP step of a two-factor login. It is never accepted as an access
+// token.
+const TokenPurposeMFA = "mfa"
+
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    int    `json:"user_id"`
+	SessionID int    `json:"sid,omitempty"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Purpose   string `json:"purpose,omitempty"`
+	// MFASetupRequired restricts the token to 2FA enrollment endpoints until
+	// the user enables TOTP on a role that mandates it.
+	MFASetupRequired bool `json:"mfa_setup_required,omitempty"`
+	// Act is set on impersonation tokens and names the administrator acting
+	// as UserID (the RFC 8693 "act" claim).
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, email, role, secret string, expiration int) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expiration))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	UserID  int    `json:"user_id"`
+	Email   string `json:"email,omitempty"`
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+type TokenManagerConfig struct {
+	// KeysDir holds one PEM file per key, named <kid>.pem. Private keys sign
+	// and verify; public-only keys are kept around to verify tokens signed
+	// by a retired key. When empty the manager falls back to HS256 with
+	// Secret and publishes no JWKS.
+	KeysDir      string
+	SigningKeyID string
+	Secret       string
+	Issuer       string
+	Audience     string
 }
 
-func ValidateToken(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// TokenManager signs and validates access tokens. With asymmetric keys the
+// public halves are published as a JWKS so other services can verify tokens
+// without being able to mint them.
+type TokenManager struct {
+	method     jwt.SigningMethod
+	signingKID string
+	signingKey interface{}
+	keys       map[string]*SigningKey
+	secret     []byte
+	issuer     string
+	audience   string
+}
+
+func NewTokenManager(cfg TokenManagerConfig) (*TokenManager, error) {
+	m := &TokenManager{issuer: cfg.Issuer, audience: cfg.Audience}
+
+	if cfg.KeysDir == "" {
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("either JWT_KEYS_DIR or JWT_SECRET must be set")
+		}
+		log.Println("⚠️  JWT_KEYS_DIR not set, signing tokens with HS256 shared secret")
+		m.method = jwt.SigningMethodHS256
+		m.secret = []byte(cfg.Secret)
+		return m, nil
+	}
+
+	keys, err := LoadSigningKeys(cfg.KeysDir)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := cfg.SigningKeyID
+	if kid == "" {
+		// Without an explicit choice only an unambiguous key may sign.
+		for id, k := range keys {
+			if k.Private != nil {
+				if kid != "" {
+					return nil, fmt.Errorf("multiple private keys in %s, set JWT_SIGNING_KEY_ID", cfg.KeysDir)
+				}
+				kid = id
+			}
 		}
-		return []byte(secret), nil
-	})
+	}
+
+	active, ok := keys[kid]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("signing key %q not found or has no private key in %s", kid, cfg.KeysDir)
+	}
+
+	m.keys = keys
+	m.signingKID = kid
+	m.signingKey = active.Private
+	m.method = active.Method
+	log.Printf("🔑 Loaded %d JWT key(s), signing with %s (%s)", len(keys), kid, active.Method.Alg())
+	return m, nil
+}
+
+func (m *TokenManager) Generate(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   strconv.Itoa(claims.UserID),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	if m.audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.audience}
+	}
+
+	token := jwt.NewWithClaims(m.method, claims)
+	if m.secret != nil {
+		return token.SignedString(m.secret)
+	}
+
+	token.Header["kid"] = m.signingKID
+	return token.SignedString(m.signingKey)
+}
+
+func (m *TokenManager) Validate(tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(m.validMethods()),
+		jwt.WithExpirationRequired(),
+	}
+	if m.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.issuer))
+	}
+	if m.audience != "" {
+		opts = append(opts, jwt.WithAudience(m.audience))
+	}
 
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.keyFunc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -47,3 +149,56 @@ func ValidateToken(tokenString, secret string) (*Claims, error) {
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+func (m *TokenManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if m.secret != nil {
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key.Public, nil
+}
+
+func (m *TokenManager) validMethods() []string {
+	if m.secret != nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	seen := map[string]bool{}
+	var methods []string
+	for _, k := range m.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public verification keys. It is empty in HS256 mode since
+// a shared secret must never be published.
+func (m *TokenManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for kid, k := range m.keys {
+		if jwk, ok := publicJWK(kid, k.Method, k.Public); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// publicKeyOf returns the public half of a private key.
+func publicKeyOf(priv interface{}) (crypto.PublicKey, bool) {
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, false
+	}
+	return signer.Public(), true
+}
@@ -3,10 +3,23 @@ package utils
 import "github.com/gofiber/fiber/v2"
 
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Code    int         `json:"code"`
-	Data    interface{} `json:"data,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	Code       int         `json:"code"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a list response. Page is only set when
+// the client paged by number; NextCursor is empty on the last page.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
 }
 
 func SuccessResponse(c *fiber.Ctx, message string, data interface{}) error {
@@ -18,11 +31,22 @@ func SuccessResponse(c *fiber.Ctx, message string, data interface{}) error {
 	})
 }
 
+func PaginatedResponse(c *fiber.Ctx, message string, data interface{}, pagination Pagination) error {
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Success:    true,
+		Message:    message,
+		Code:       fiber.StatusOK,
+		Data:       data,
+		Pagination: &pagination,
+	})
+}
+
 func ErrorResponse(c *fiber.Ctx, status int, message string) error {
 	return c.Status(status).JSON(Response{
 		Success: false,
 		Message: message,
 		Code:    status,
+		Error:   StatusErrorCode(status),
 	})
 }
 
@@ -44,49 +68,29 @@ func NoContentResponse(c *fiber.Ctx, message string) error {
 }
 
 func BadRequestResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusBadRequest).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusBadRequest,
-	})
+	return ErrorResponse(c, fiber.StatusBadRequest, message)
 }
 
 func UnauthorizedResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusUnauthorized).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusUnauthorized,
-	})
+	return ErrorResponse(c, fiber.StatusUnauthorized, message)
 }
 
 func ForbiddenResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusForbidden).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusForbidden,
-	})
+	return ErrorResponse(c, fiber.StatusForbidden, message)
 }
 
 func NotFoundResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusNotFound).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusNotFound,
-	})
+	return ErrorResponse(c, fiber.StatusNotFound, message)
 }
 
 func ConflictResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusConflict).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusConflict,
-	})
+	return ErrorResponse(c, fiber.StatusConflict, message)
+}
+
+func UnprocessableEntityResponse(c *fiber.Ctx, message string) error {
+	return ErrorResponse(c, fiber.StatusUnprocessableEntity, message)
 }
 
 func InternalServerErrorResponse(c *fiber.Ctx, message string) error {
-	return c.Status(fiber.StatusInternalServerError).JSON(Response{
-		Success: false,
-		Message: message,
-		Code:    fiber.StatusInternalServerError,
-	})
+	return ErrorResponse(c, fiber.StatusInternalServerError, message)
 }
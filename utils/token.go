@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// GenerateRandomToken returns a URL-safe random string built from n bytes of entropy.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 of an opaque token. Only the hash
+// is stored so a leaked table cannot be replayed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix marks strings that are API keys rather than JWTs.
+const APIKeyPrefix = "mbkm_"
+
+// GenerateAPIKey returns a key of the form mbkm_<prefix>_<secret>. The prefix
+// is stored in clear to look the key up and to recognise it in logs; only the
+// hash of the full key is stored.
+func GenerateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(b)
+
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return APIKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+// ParseAPIKeyPrefix extracts the lookup prefix from an API key.
+func ParseAPIKeyPrefix(key string) (string, bool) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || len(prefix) != 12 || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
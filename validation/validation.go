@@ -0,0 +1,339 @@
+// Package validation checks request DTOs against the rules in their
+// `validate` struct tags, so handlers reject bad input the same way
+// everywhere instead of each writing its own checks.
+//
+// Rules are comma separated and checked in order; the first one a field
+// breaks is reported for it:
+//
+//	required     non-blank string, non-zero number, non-nil pointer,
+//	             non-empty slice
+//	omitempty    skip the other rules when the field is empty
+//	notblank     a string that is not empty or only spaces
+//	min=N max=N  bounds of a number, or the length of a string or slice
+//	gt=N         number strictly greater than N
+//	oneof=a b c  one of the listed values
+//	email        a bare e-mail address such as user@example.com
+//	ltefield=F   number not greater than the sibling field F (Go name)
+//
+// Pointers are checked through when set. Fields are reported by their JSON
+// name. A tag Struct cannot apply is a programming error and panics; run
+// request types through CheckTags in tests to catch it before a request does.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is one rule a field broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors lists every field that failed, in field order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Optional is implemented by wrappers whose member may be absent, such as
+// merge patch fields. present is false when the member was not sent, and
+// value is nil when it was sent as null. Absent members are not checked and
+// null ones only fail required.
+type Optional interface {
+	ValidationValue() (value any, present bool)
+}
+
+// Struct checks the tagged fields of the struct v points to. It returns nil
+// or Errors.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		if fe, failed := checkField(rv, sf, tag); failed {
+			errs = append(errs, fe)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// CheckTags reports the tags of the struct v points to that Struct would
+// panic on: unknown rules, malformed parameters, ltefield targets that do
+// not exist and rules on fields they cannot measure.
+func CheckTags(v any) error {
+	rt := reflect.TypeOf(v)
+	for rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: %v is not a struct", rt)
+	}
+
+	var errs []error
+	zero := reflect.New(rt).Elem()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		ft := checkedType(zero.Field(i))
+		for _, rule := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			if err := checkRule(rt, ft, rule, param); err != nil {
+				errs = append(errs, fmt.Errorf("validation: %s.%s: %w", rt.Name(), sf.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkedType is the type the rules of field fv apply to: the member of an
+// Optional, through any pointers.
+func checkedType(fv reflect.Value) reflect.Type {
+	t := fv.Type()
+	if opt, ok := fv.Interface().(Optional); ok {
+		if value, _ := opt.ValidationValue(); value != nil {
+			t = reflect.TypeOf(value)
+		}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// checkRule is the static half of check: whether rule can apply to a field
+// of type ft in struct parent.
+func checkRule(parent, ft reflect.Type, rule, param string) error {
+	switch rule {
+	case "required", "omitempty", "notblank":
+	case "min", "max", "gt":
+		if _, err := strconv.ParseFloat(param, 64); err != nil {
+			return fmt.Errorf("bad %s parameter %q", rule, param)
+		}
+		if !isNumber(ft) && (rule == "gt" || !hasLength(ft)) {
+			return fmt.Errorf("%s does not apply to %s", rule, ft)
+		}
+	case "oneof":
+		if len(strings.Fields(param)) == 0 {
+			return errors.New("oneof lists no values")
+		}
+	case "email":
+		if ft.Kind() != reflect.String {
+			return fmt.Errorf("email does not apply to %s", ft)
+		}
+	case "ltefield":
+		other, ok := parent.FieldByName(param)
+		if !ok {
+			return fmt.Errorf("unknown field %s", param)
+		}
+		for _, t := range []reflect.Type{ft, other.Type} {
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if !isNumber(t) {
+				return fmt.Errorf("ltefield does not apply to %s", t)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown rule %s", rule)
+	}
+	return nil
+}
+
+func isNumber(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func hasLength(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func checkField(parent reflect.Value, sf reflect.StructField, tag string) (FieldError, bool) {
+	name := jsonName(sf)
+	fv := parent.FieldByIndex(sf.Index)
+
+	if opt, ok := fv.Interface().(Optional); ok {
+		value, present := opt.ValidationValue()
+		if !present {
+			return FieldError{}, false
+		}
+		if value == nil {
+			if slices.Contains(strings.Split(tag, ","), "required") {
+				return FieldError{Field: name, Rule: "required", Message: name + " cannot be null"}, true
+			}
+			return FieldError{}, false
+		}
+		fv = reflect.ValueOf(value)
+	}
+
+	for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+		fv = fv.Elem()
+	}
+
+	empty := isEmpty(fv)
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch {
+		case rule == "omitempty":
+			if empty {
+				return FieldError{}, false
+			}
+			continue
+		case rule == "required":
+			if empty {
+				return FieldError{Field: name, Rule: rule, Message: name + " is required"}, true
+			}
+			continue
+		case fv.Kind() == reflect.Pointer:
+			// A nil pointer that is not required has nothing to check.
+			return FieldError{}, false
+		}
+
+		if msg := check(parent, fv, rule, param); msg != "" {
+			return FieldError{Field: name, Rule: rule, Message: name + " " + msg}, true
+		}
+	}
+	return FieldError{}, false
+}
+
+// check applies one rule to fv and describes the failure, or returns "".
+func check(parent, fv reflect.Value, rule, param string) string {
+	switch rule {
+	case "min", "max", "gt":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validation: bad %s parameter %q", rule, param))
+		}
+		n, isLength := measure(fv)
+		switch {
+		case rule == "min" && n < limit && isLength:
+			return "must be at least " + param + " characters long"
+		case rule == "min" && n < limit:
+			return "must be at least " + param
+		case rule == "max" && n > limit && isLength:
+			return "must be at most " + param + " characters long"
+		case rule == "max" && n > limit:
+			return "must be at most " + param
+		case rule == "gt" && n <= limit:
+			return "must be greater than " + param
+		}
+	case "oneof":
+		allowed := strings.Fields(param)
+		if !slices.Contains(allowed, fmt.Sprint(fv.Interface())) {
+			return "must be one of: " + strings.Join(allowed, ", ")
+		}
+	case "notblank":
+		if isEmpty(fv) {
+			return "cannot be empty"
+		}
+	case "email":
+		s := fv.String()
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "must be a valid email address"
+		}
+	case "ltefield":
+		other := reflect.Indirect(parent.FieldByName(param))
+		if !other.IsValid() {
+			panic("validation: unknown field " + param)
+		}
+		if n, _ := measure(fv); n > number(other) {
+			return "must not be greater than " + jsonNameOf(parent.Type(), param)
+		}
+	default:
+		panic("validation: unknown rule " + rule)
+	}
+	return ""
+}
+
+// measure returns the number a min or max bound applies to: the value of a
+// number or the length of a string or slice.
+func measure(v reflect.Value) (n float64, isLength bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return number(v), false
+}
+
+func number(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	}
+	panic("validation: " + v.Type().String() + " is not a number")
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		// false is a value like true; use *bool to require the member.
+		return false
+	}
+	return v.IsZero()
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func jsonNameOf(t reflect.Type, field string) string {
+	if sf, ok := t.FieldByName(field); ok {
+		return jsonName(sf)
+	}
+	return field
+}
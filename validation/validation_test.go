@@ -0,0 +1,329 @@
+package validation_test
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"mbkm-api/models"
+	"mbkm-api/validation"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestStruct(t *testing.T) {
+	cases := []struct {
+		name string
+		v    any
+		// want is "field rule" of the only error, or "" when v is valid.
+		want string
+	}{
+		{name: "required string", v: struct {
+			Name string `json:"name" validate:"required"`
+		}{"Ani"}},
+		{name: "required blank string", v: struct {
+			Name string `json:"name" validate:"required"`
+		}{"  "}, want: "name required"},
+		{name: "required zero number", v: struct {
+			N int `validate:"required"`
+		}{0}, want: "N required"},
+		{name: "required nil pointer", v: struct {
+			Active *bool `json:"is_active" validate:"required"`
+		}{nil}, want: "is_active required"},
+		{name: "required false through pointer", v: struct {
+			Active *bool `json:"is_active" validate:"required"`
+		}{ptr(false)}},
+		{name: "required empty slice", v: struct {
+			Scopes []string `json:"scopes" validate:"required"`
+		}{[]string{}}, want: "scopes required"},
+		{name: "required slice", v: struct {
+			Scopes []string `json:"scopes" validate:"required"`
+		}{[]string{"programs:read"}}},
+		{name: "required before later rules", v: struct {
+			Code string `json:"code" validate:"required,min=3"`
+		}{""}, want: "code required"},
+
+		{name: "omitempty skips empty", v: struct {
+			Email string `json:"email" validate:"omitempty,email"`
+		}{""}},
+		{name: "omitempty checks set", v: struct {
+			Email string `json:"email" validate:"omitempty,email"`
+		}{"ani"}, want: "email email"},
+
+		{name: "notblank spaces", v: struct {
+			Name string `json:"name" validate:"notblank"`
+		}{" \t"}, want: "name notblank"},
+		{name: "notblank text", v: struct {
+			Name string `json:"name" validate:"notblank"`
+		}{"Ani"}},
+
+		{name: "min string length", v: struct {
+			Code string `json:"code" validate:"min=3"`
+		}{"ab"}, want: "code min"},
+		{name: "min counts runes", v: struct {
+			Code string `json:"code" validate:"min=3"`
+		}{"héé"}},
+		{name: "min number", v: struct {
+			Credits int `json:"credits" validate:"min=1"`
+		}{0}, want: "credits min"},
+		{name: "min slice length", v: struct {
+			Scopes []string `json:"scopes" validate:"min=2"`
+		}{[]string{"a"}}, want: "scopes min"},
+
+		{name: "max string length", v: struct {
+			NIDN string `json:"nidn" validate:"max=5"`
+		}{"123456"}, want: "nidn max"},
+		{name: "max fractional bound", v: struct {
+			MaxScore float64 `json:"max_score" validate:"max=999.99"`
+		}{1000}, want: "max_score max"},
+		{name: "max at bound", v: struct {
+			MaxScore float64 `json:"max_score" validate:"max=999.99"`
+		}{999.99}},
+		{name: "max through pointer", v: struct {
+			Weight *int `json:"weight" validate:"max=100"`
+		}{ptr(101)}, want: "weight max"},
+		{name: "nil pointer is not checked", v: struct {
+			Weight *int `json:"weight" validate:"min=1"`
+		}{nil}},
+
+		{name: "gt equal", v: struct {
+			MaxScore float64 `json:"max_score" validate:"gt=0"`
+		}{0}, want: "max_score gt"},
+		{name: "gt above", v: struct {
+			MaxScore float64 `json:"max_score" validate:"gt=0"`
+		}{0.5}},
+
+		{name: "oneof string", v: struct {
+			Status string `json:"status" validate:"oneof=active dropped"`
+		}{"dropped"}},
+		{name: "oneof unknown string", v: struct {
+			Status string `json:"status" validate:"oneof=active dropped"`
+		}{"graduated"}, want: "status oneof"},
+		{name: "oneof number", v: struct {
+			Semester int `json:"semester" validate:"oneof=1 2"`
+		}{3}, want: "semester oneof"},
+
+		{name: "email", v: struct {
+			Email string `json:"email" validate:"email"`
+		}{"ani@univ.ac.id"}},
+		{name: "email with display name", v: struct {
+			Email string `json:"email" validate:"email"`
+		}{"Ani <ani@univ.ac.id>"}, want: "email email"},
+
+		{name: "ltefield equal", v: struct {
+			Score    float64 `json:"score" validate:"ltefield=MaxScore"`
+			MaxScore float64 `json:"max_score"`
+		}{100, 100}},
+		{name: "ltefield above", v: struct {
+			Score    float64 `json:"score" validate:"ltefield=MaxScore"`
+			MaxScore float64 `json:"max_score"`
+		}{120, 100}, want: "score ltefield"},
+		{name: "ltefield pointer target", v: struct {
+			Score    int  `json:"score" validate:"ltefield=MaxScore"`
+			MaxScore *int `json:"max_score"`
+		}{11, ptr(10)}, want: "score ltefield"},
+
+		{name: "optional absent", v: struct {
+			Name models.Patch[string] `json:"name" validate:"required,max=3"`
+		}{}},
+		{name: "optional null and required", v: struct {
+			Name models.Patch[string] `json:"name" validate:"required,max=3"`
+		}{models.Patch[string]{Set: true, Null: true}}, want: "name required"},
+		{name: "optional null", v: struct {
+			Phone models.Patch[string] `json:"phone" validate:"max=3"`
+		}{models.Patch[string]{Set: true, Null: true}}},
+		{name: "optional value", v: struct {
+			Name models.Patch[string] `json:"name" validate:"required,max=3"`
+		}{models.Patch[string]{Set: true, Value: "Magang"}}, want: "name max"},
+		{name: "optional false", v: struct {
+			Active models.Patch[bool] `json:"is_active" validate:"required"`
+		}{models.Patch[bool]{Set: true}}},
+
+		{name: "untagged and unexported fields", v: struct {
+			Name  string
+			inner string `validate:"required"`
+		}{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validation.Struct(tc.v)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var errs validation.Errors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("err = %v, want one error for %q", err, tc.want)
+			}
+			if got := errs[0].Field + " " + errs[0].Rule; got != tc.want {
+				t.Errorf("got %q (%s), want %q", got, errs[0].Message, tc.want)
+			}
+			if !strings.HasPrefix(errs[0].Message, errs[0].Field+" ") {
+				t.Errorf("message %q does not name the field", errs[0].Message)
+			}
+		})
+	}
+}
+
+func TestStructMessages(t *testing.T) {
+	err := validation.Struct(&models.CreateAssessmentRequest{Category: "exam", Score: 120, MaxScore: 100})
+	want := "enrollment_id is required; category must be one of: assignment, quiz, midterm, final, practicum, project, presentation, report; score must not be greater than max_score"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v\nwant %s", err, want)
+	}
+
+	err = validation.Struct(&models.CreateProgramRequest{Code: "MBKM-01", Name: strings.Repeat("x", 101), Credits: 30, Semester: 5, LecturerID: 1})
+	want = "name must be at most 100 characters long; credits must be at most 24"
+	if err == nil || err.Error() != want {
+		t.Errorf("err = %v\nwant %s", err, want)
+	}
+}
+
+// TestBadTags covers the tags Struct panics on: CheckTags must report each.
+func TestBadTags(t *testing.T) {
+	cases := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{name: "bad parameter", v: &struct {
+			Code string `validate:"max=ten"`
+		}{"MBKM"}, want: `bad max parameter "ten"`},
+		{name: "unknown ltefield target", v: &struct {
+			Score float64 `validate:"ltefield=Max"`
+		}{1}, want: "unknown field Max"},
+		{name: "unknown rule", v: &struct {
+			Code string `validate:"required,uppercase"`
+		}{"MBKM"}, want: "unknown rule uppercase"},
+		{name: "non-numeric field", v: &struct {
+			Active bool `validate:"gt=0"`
+		}{true}, want: "gt does not apply to bool"},
+		{name: "non-numeric ltefield target", v: &struct {
+			Score float64 `validate:"ltefield=Name"`
+			Name  string
+		}{1, "x"}, want: "ltefield does not apply to string"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validation.CheckTags(tc.v)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("CheckTags = %v, want %q", err, tc.want)
+			}
+
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct did not panic")
+				}
+			}()
+			validation.Struct(tc.v)
+		})
+	}
+}
+
+// requestTypes are the request types of package models, one of each.
+var requestTypes = []any{
+	models.AcceptInvitationRequest{},
+	models.ChangePasswordRequest{},
+	models.CreateAPIKeyRequest{},
+	models.CreateAssessmentRequest{},
+	models.CreateEnrollmentRequest{},
+	models.CreateInvitationRequest{},
+	models.CreateLecturerRequest{},
+	models.CreateProgramRequest{},
+	models.CreateServiceAccountRequest{},
+	models.CreateUserRequest{},
+	models.DisableTwoFactorRequest{},
+	models.ForgotPasswordRequest{},
+	models.ImpersonateRequest{},
+	models.LecturerPatch{},
+	models.LoginRequest{},
+	models.LoginTwoFactorRequest{},
+	models.ProgramPatch{},
+	models.RefreshTokenRequest{},
+	models.RegisterRequest{},
+	models.ResetPasswordRequest{},
+	models.TwoFactorCodeRequest{},
+	models.UpdateAssessmentRequest{},
+	models.UpdateEnrollmentRequest{},
+	models.UpdateLecturerRequest{},
+	models.UpdateProfileRequest{},
+	models.UpdateProgramRequest{},
+	models.UpdateServiceAccountRequest{},
+	models.UpdateUserRequest{},
+	models.UpdateUserRoleRequest{},
+	models.UpdateUserStatusRequest{},
+}
+
+// TestModelTags checks the tags of every struct in package models that has
+// any, so a typo fails here instead of panicking on a request.
+func TestModelTags(t *testing.T) {
+	listed := map[string]bool{}
+	for _, v := range requestTypes {
+		listed[reflect.TypeOf(v).Name()] = true
+		if err := validation.CheckTags(v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, name := range taggedStructs(t, "../models") {
+		if !listed[name] {
+			t.Errorf("models.%s has validate tags but is not in requestTypes", name)
+		}
+	}
+}
+
+// taggedStructs parses the package in dir and names its struct types with
+// at least one validate tag.
+func taggedStructs(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err == nil && reflect.StructTag(tag).Get("validate") != "" {
+					names = append(names, spec.Name.Name)
+					break
+				}
+			}
+			return false
+		})
+	}
+	if len(names) == 0 {
+		t.Fatalf("no tagged structs found in %s", dir)
+	}
+	return names
+}